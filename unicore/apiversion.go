@@ -0,0 +1,145 @@
+package unicore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+const (
+	// XAPIVersionKey is the header carrying the semantic version of the API the client was built against
+	XAPIVersionKey = "x-api-version"
+	// ContextKeyAPIVersion is used to store the client's APIVersion in context.
+	ContextKeyAPIVersion = "APIVersionKey"
+)
+
+// APIVersion is a parsed semantic version (MAJOR.MINOR.PATCH[-PRERELEASE]). Build metadata is
+// ignored.
+type APIVersion struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseAPIVersion parses a semantic version such as "1.4.2", "v2.0.0-beta.1" or "1.4". Missing
+// minor and patch numbers default to zero.
+func ParseAPIVersion(value string) (APIVersion, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	raw, _, _ = strings.Cut(raw, "+")
+	raw, prerelease, _ := strings.Cut(raw, "-")
+
+	parts := strings.Split(raw, ".")
+	if raw == "" || len(parts) > 3 {
+		return APIVersion{}, fmt.Errorf("invalid API version %q", value)
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return APIVersion{}, fmt.Errorf("invalid API version %q", value)
+		}
+		numbers[i] = number
+	}
+
+	return APIVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], Prerelease: prerelease}, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether version is lower, equal or higher than other.
+// A prerelease is lower than its release; prereleases are compared lexically.
+func (version APIVersion) Compare(other APIVersion) int {
+	if c := cmp.Compare(version.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(version.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(version.Patch, other.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case version.Prerelease == other.Prerelease:
+		return 0
+	case version.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return cmp.Compare(version.Prerelease, other.Prerelease)
+}
+
+// AtLeast reports whether version is equal to or higher than other
+func (version APIVersion) AtLeast(other APIVersion) bool {
+	return version.Compare(other) >= 0
+}
+
+func (version APIVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+	if version.Prerelease != "" {
+		s += "-" + version.Prerelease
+	}
+	return s
+}
+
+// APIVersionFromContext returns the client version stored by APIVersionInterceptor
+func APIVersionFromContext(ctx context.Context) (APIVersion, bool) {
+	version, ok := ctx.Value(ContextKeyAPIVersion).(APIVersion)
+	return version, ok
+}
+
+// WithRejectMissingAPIVersion makes APIVersionInterceptor reject calls without the x-api-version
+// header with CodeInvalidArgument, instead of treating them as the minimum supported version
+func WithRejectMissingAPIVersion() MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.rejectMissingVersion = true
+	}
+}
+
+// APIVersionInterceptor reads the client version from the x-api-version header and rejects
+// clients below minVersion with CodeFailedPrecondition, asking them to upgrade. Calls without the
+// header are treated as minVersion unless WithRejectMissingAPIVersion is set. The parsed version
+// is stored in context, see APIVersionFromContext. It panics when minVersion is not a valid
+// version, as that is a programming error.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(middleware.APIVersionInterceptor("2.0.0"))
+//
+//	version, _ := APIVersionFromContext(ctx)
+//	if version.AtLeast(APIVersion{Major: 2, Minor: 3}) {
+//	    resp.Msg.Discounts = discounts
+//	}
+func (middleware *grpcAuthMiddleware) APIVersionInterceptor(minVersion string) connect.UnaryInterceptorFunc {
+	minimum, err := ParseAPIVersion(minVersion)
+	if err != nil {
+		panic(fmt.Sprintf("unicore: APIVersionInterceptor: %v", err))
+	}
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			header := req.Header().Get(XAPIVersionKey)
+			if header == "" {
+				if middleware.rejectMissingVersion {
+					return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("missing %s header", XAPIVersionKey))
+				}
+				return next(context.WithValue(ctx, ContextKeyAPIVersion, minimum), req)
+			}
+
+			version, err := ParseAPIVersion(header)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInvalidArgument, err)
+			}
+			if !version.AtLeast(minimum) {
+				return nil, connect.NewError(connect.CodeFailedPrecondition,
+					fmt.Errorf("API version %s is no longer supported, upgrade the client to %s or later", version, minimum))
+			}
+
+			return next(context.WithValue(ctx, ContextKeyAPIVersion, version), req)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package unicore
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Names of the model fields stamped by RegisterAuditCallbacks
+const (
+	createdByField = "CreatedBy"
+	updatedByField = "UpdatedBy"
+	deletedByField = "DeletedBy"
+)
+
+// RegisterAuditCallbacks registers GORM callbacks stamping the user id (sub claim) from the
+// statement context into the audit fields of a model:
+//   - CreatedBy and UpdatedBy on create (an explicitly set CreatedBy is kept)
+//   - UpdatedBy on update, except for UpdateColumn(s) which skip hooks
+//   - DeletedBy on soft delete, written in the same UPDATE that sets deleted_at
+//
+// Fields missing from a model are skipped, and nothing is stamped when the context carries no
+// user, so system jobs keep whatever values they set themselves. Queries must use WithContext for
+// the user to be found.
+//
+// Example Usage:
+//
+//	if err := RegisterAuditCallbacks(db, NewContextHelper(authenticator)); err != nil {
+//	    log.Fatal(err)
+//	}
+func RegisterAuditCallbacks(db *gorm.DB, helper ContextHelper) error {
+	userOf := func(ctx context.Context) string {
+		if claims := helper.GetUserClaims(ctx); claims != nil {
+			return claims.Id
+		}
+		return ""
+	}
+
+	if err := db.Callback().Create().Before("gorm:create").Register("unicore:audit_create", func(tx *gorm.DB) {
+		stampCreate(tx, userOf(tx.Statement.Context))
+	}); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("unicore:audit_update", func(tx *gorm.DB) {
+		stampUpdate(tx, userOf(tx.Statement.Context))
+	}); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("unicore:audit_delete", func(tx *gorm.DB) {
+		stampSoftDelete(tx, userOf(tx.Statement.Context))
+	})
+}
+
+// stampCreate sets CreatedBy, unless already set, and UpdatedBy on every record being inserted
+func stampCreate(tx *gorm.DB, userID string) {
+	stmt := tx.Statement
+	if tx.Error != nil || stmt.Schema == nil || userID == "" {
+		return
+	}
+
+	createdBy := stmt.Schema.LookUpField(createdByField)
+	updatedBy := stmt.Schema.LookUpField(updatedByField)
+	if createdBy == nil && updatedBy == nil {
+		return
+	}
+
+	stamp := func(record reflect.Value) {
+		if createdBy != nil {
+			if _, zero := createdBy.ValueOf(stmt.Context, record); zero {
+				tx.AddError(createdBy.Set(stmt.Context, record, userID))
+			}
+		}
+		if updatedBy != nil {
+			tx.AddError(updatedBy.Set(stmt.Context, record, userID))
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if record := reflect.Indirect(stmt.ReflectValue.Index(i)); record.Kind() == reflect.Struct {
+				stamp(record)
+			}
+		}
+	case reflect.Struct:
+		stamp(stmt.ReflectValue)
+	}
+}
+
+// stampUpdate adds UpdatedBy to the columns being updated
+func stampUpdate(tx *gorm.DB, userID string) {
+	stmt := tx.Statement
+	if tx.Error != nil || stmt.Schema == nil || stmt.SkipHooks || userID == "" {
+		return
+	}
+
+	if field := stmt.Schema.LookUpField(updatedByField); field != nil {
+		stmt.SetColumn(field.DBName, userID, true)
+	}
+}
+
+// stampSoftDelete builds the soft delete UPDATE itself so it can set DeletedBy next to deleted_at.
+// GORM's soft delete clause only writes deleted_at and skips building once the SQL exists, so this
+// mirrors gorm.SoftDeleteDeleteClause with the extra assignment.
+func stampSoftDelete(tx *gorm.DB, userID string) {
+	stmt := tx.Statement
+	if tx.Error != nil || stmt.Schema == nil || stmt.Unscoped || stmt.SQL.Len() > 0 || userID == "" {
+		return
+	}
+
+	deletedBy := stmt.Schema.LookUpField(deletedByField)
+	if deletedBy == nil {
+		return
+	}
+
+	var softDelete *gorm.SoftDeleteDeleteClause
+	for _, c := range stmt.Schema.DeleteClauses {
+		if sd, ok := c.(gorm.SoftDeleteDeleteClause); ok {
+			softDelete = &sd
+			break
+		}
+	}
+	if softDelete == nil {
+		return
+	}
+
+	now := tx.NowFunc()
+	stmt.AddClause(clause.Set{
+		{Column: clause.Column{Name: softDelete.Field.DBName}, Value: now},
+		{Column: clause.Column{Name: deletedBy.DBName}, Value: userID},
+	})
+	stmt.SetColumn(softDelete.Field.DBName, now, true)
+	stmt.SetColumn(deletedBy.DBName, userID, true)
+
+	addPrimaryKeyConditions(stmt, stmt.ReflectValue)
+	if stmt.ReflectValue.CanAddr() && stmt.Dest != stmt.Model && stmt.Model != nil {
+		addPrimaryKeyConditions(stmt, reflect.ValueOf(stmt.Model))
+	}
+
+	gorm.SoftDeleteQueryClause(*softDelete).ModifyStatement(stmt)
+	stmt.AddClauseIfNotExists(clause.Update{})
+	stmt.Build(tx.Callback().Update().Clauses...)
+}
+
+// addPrimaryKeyConditions restricts the statement to the primary keys set on value
+func addPrimaryKeyConditions(stmt *gorm.Statement, value reflect.Value) {
+	_, queryValues := schema.GetIdentityFieldValuesMap(stmt.Context, value, stmt.Schema.PrimaryFields)
+	column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+	if len(values) > 0 {
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+	}
+}
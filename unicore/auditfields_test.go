@@ -0,0 +1,117 @@
+package unicore
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type auditedRow struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	CreatedBy string
+	UpdatedBy string
+	DeletedBy string
+	DeletedAt gorm.DeletedAt
+}
+
+type unauditedRow struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	DeletedAt gorm.DeletedAt
+}
+
+func newAuditTestDB(t *testing.T) (*gorm.DB, ContextHelper) {
+	t.Helper()
+
+	db := openTestDB(t, &auditedRow{}, &unauditedRow{})
+	helper := NewContextHelper(nil)
+	if err := RegisterAuditCallbacks(db, helper); err != nil {
+		t.Fatal(err)
+	}
+	return db, helper
+}
+
+func findAudited(t *testing.T, db *gorm.DB, id string) auditedRow {
+	t.Helper()
+
+	var row auditedRow
+	if err := db.Unscoped().First(&row, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestRegisterAuditCallbacksStampsUsers(t *testing.T) {
+	db, helper := newAuditTestDB(t)
+	alice := helper.WithUserClaims(context.Background(), &UserAuthClaims{Id: "alice"})
+	bob := helper.WithUserClaims(context.Background(), &UserAuthClaims{Id: "bob"})
+
+	if err := db.WithContext(alice).Create(&auditedRow{ID: "1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(alice).Create(&[]auditedRow{{ID: "2"}, {ID: "3", CreatedBy: "importer"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row := findAudited(t, db, "1"); row.CreatedBy != "alice" || row.UpdatedBy != "alice" {
+		t.Fatalf("expected alice to be stamped on create, got %+v", row)
+	}
+	if row := findAudited(t, db, "3"); row.CreatedBy != "importer" || row.UpdatedBy != "alice" {
+		t.Fatalf("expected an explicit CreatedBy to be kept, got %+v", row)
+	}
+
+	if err := db.WithContext(bob).Model(&auditedRow{ID: "1"}).Update("name", "renamed").Error; err != nil {
+		t.Fatal(err)
+	}
+	if row := findAudited(t, db, "1"); row.CreatedBy != "alice" || row.UpdatedBy != "bob" {
+		t.Fatalf("expected bob to be stamped on update, got %+v", row)
+	}
+
+	if err := db.WithContext(bob).Delete(&auditedRow{ID: "2"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row := findAudited(t, db, "2"); row.DeletedBy != "bob" || !row.DeletedAt.Valid {
+		t.Fatalf("expected bob to be stamped on soft delete, got %+v", row)
+	}
+	if row := findAudited(t, db, "1"); row.DeletedBy != "" || row.DeletedAt.Valid {
+		t.Fatalf("expected the other rows to be left alone, got %+v", row)
+	}
+}
+
+func TestRegisterAuditCallbacksWithoutUser(t *testing.T) {
+	db, _ := newAuditTestDB(t)
+
+	if err := db.WithContext(context.Background()).Create(&auditedRow{ID: "1", CreatedBy: "job"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(context.Background()).Delete(&auditedRow{ID: "1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row := findAudited(t, db, "1"); row.CreatedBy != "job" || row.UpdatedBy != "" || row.DeletedBy != "" || !row.DeletedAt.Valid {
+		t.Fatalf("expected nothing to be stamped without a user, got %+v", row)
+	}
+}
+
+func TestRegisterAuditCallbacksSkipsModelsWithoutAuditFields(t *testing.T) {
+	db, helper := newAuditTestDB(t)
+	ctx := helper.WithUserClaims(context.Background(), &UserAuthClaims{Id: "alice"})
+
+	row := unauditedRow{ID: "1"}
+	if err := db.WithContext(ctx).Create(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Model(&row).Update("name", "renamed").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Delete(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var visible, all int64
+	db.Model(&unauditedRow{}).Count(&visible)
+	db.Unscoped().Model(&unauditedRow{}).Count(&all)
+	if visible != 0 || all != 1 {
+		t.Fatalf("expected a plain soft delete, got %d visible of %d rows", visible, all)
+	}
+}
@@ -3,11 +3,14 @@ package unicore
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
+	"unicode"
 
 	"connectrpc.com/connect"
 	"github.com/coreos/go-oidc"
@@ -18,30 +21,134 @@ import (
 )
 
 type keycloakAuthenticator struct {
-	verifier *oidc.IDTokenVerifier
+	verifier         *oidc.IDTokenVerifier
+	allowedAudiences []string
+	signingAlgs      []string
+	jwksMinRefresh   time.Duration
+	jwksRefresh      time.Duration
+	clock            Clock
+}
+
+// AuthenticatorOption customizes the authenticator returned by NewAuthenticator
+type AuthenticatorOption func(*keycloakAuthenticator)
+
+// WithAllowedAudiences accepts tokens whose aud claim contains at least one of the given
+// audiences, such as the audiences of other services, instead of requiring KC.CLIENT_ID. When
+// unset, the aud claim must contain KC.CLIENT_ID.
+func WithAllowedAudiences(audiences ...string) AuthenticatorOption {
+	return func(authenticator *keycloakAuthenticator) {
+		authenticator.allowedAudiences = audiences
+	}
+}
+
+// WithSigningAlgorithms restricts the algorithms accepted for token signatures (default RS256).
+// The "none" algorithm is always rejected.
+func WithSigningAlgorithms(algs ...string) AuthenticatorOption {
+	return func(authenticator *keycloakAuthenticator) {
+		authenticator.signingAlgs = algs
+	}
+}
+
+// WithJWKSMinRefreshInterval sets how often, at most, a token failing signature verification may
+// trigger a re-fetch of the issuer's keys (default 10s). This is what picks up rotated keys before
+// the IdP's cache-control expiry.
+func WithJWKSMinRefreshInterval(interval time.Duration) AuthenticatorOption {
+	return func(authenticator *keycloakAuthenticator) {
+		authenticator.jwksMinRefresh = interval
+	}
+}
+
+// WithJWKSRefreshInterval additionally drops the cached keys on a fixed interval, so keys retired by
+// the IdP stop being accepted. Disabled by default; the refresh stops when the context passed to
+// NewAuthenticator is done.
+func WithJWKSRefreshInterval(interval time.Duration) AuthenticatorOption {
+	return func(authenticator *keycloakAuthenticator) {
+		authenticator.jwksRefresh = interval
+	}
+}
+
+// WithClock sets the clock used to check token expiry and to rate limit JWKS refreshes (default
+// the system clock)
+func WithClock(clock Clock) AuthenticatorOption {
+	return func(authenticator *keycloakAuthenticator) {
+		authenticator.clock = clock
+	}
 }
 
 func (authenticator *keycloakAuthenticator) ExtractHeaderToken(request connect.AnyRequest) (string, error) {
 	// Look for the authorization header.
-	authHeader := request.Header().Get("Authorization")
-	if authHeader == "" {
-		return "", status.Error(codes.Unauthenticated, "missing authorization header")
+	return ParseBearerToken(request.Header().Get("Authorization"))
+}
+
+// ParseBearerToken extracts the token from an Authorization header of the form "Bearer <token>".
+// Surrounding whitespace is ignored and the scheme is matched case-insensitively, but empty tokens
+// and tokens containing whitespace are rejected with ErrMissingOrInvalidToken.
+func ParseBearerToken(header string) (string, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", fmt.Errorf("%w: missing authorization header", ErrMissingOrInvalidToken)
 	}
 
-	// The authorization header should be in the form "Bearer <token>".
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return "", status.Error(codes.Unauthenticated, "invalid authorization header")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("%w: authorization header must use the Bearer scheme", ErrMissingOrInvalidToken)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("%w: bearer token is empty", ErrMissingOrInvalidToken)
+	}
+	if strings.ContainsFunc(token, unicode.IsSpace) {
+		return "", fmt.Errorf("%w: bearer token must not contain whitespace", ErrMissingOrInvalidToken)
 	}
 
-	return parts[1], nil
+	return token, nil
 }
 
 func (authenticator *keycloakAuthenticator) GetVerifier() *oidc.IDTokenVerifier {
 	return authenticator.verifier
 }
 
-func NewAuthenticator(ctx context.Context) (Authenticator, error) {
+// audienceVerifier is implemented by authenticators checking the aud claim themselves after the
+// OIDC verifier, see WithAllowedAudiences
+type audienceVerifier interface {
+	VerifyAudience(*oidc.IDToken) error
+}
+
+// VerifyAudience checks that the verified token was minted for one of the allowed audiences
+func (authenticator *keycloakAuthenticator) VerifyAudience(idToken *oidc.IDToken) error {
+	if len(authenticator.allowedAudiences) == 0 {
+		return nil
+	}
+
+	for _, audience := range idToken.Audience {
+		if slices.Contains(authenticator.allowedAudiences, audience) {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
+}
+
+func NewAuthenticator(ctx context.Context, opts ...AuthenticatorOption) (Authenticator, error) {
+	authenticator := &keycloakAuthenticator{
+		signingAlgs:    []string{oidc.RS256},
+		jwksMinRefresh: defaultJWKSMinRefreshInterval,
+		clock:          systemClock{},
+	}
+	for _, opt := range opts {
+		opt(authenticator)
+	}
+
+	if len(authenticator.signingAlgs) == 0 {
+		return nil, errors.New("at least one signing algorithm must be accepted")
+	}
+	for _, alg := range authenticator.signingAlgs {
+		if strings.EqualFold(alg, "none") {
+			return nil, errors.New(`signing algorithm "none" is not allowed`)
+		}
+	}
+
 	clientId := os.Getenv("KC.CLIENT_ID")
 	issuerUrl := os.Getenv("KC.BASE_URL")
 	url := fmt.Sprintf("%s/realms/%s", issuerUrl, os.Getenv("KC.REALM"))
@@ -63,15 +170,26 @@ func NewAuthenticator(ctx context.Context) (Authenticator, error) {
 		return nil, err
 	}
 
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, err
+	}
+
+	// With allowed audiences, VerifyAudience checks the aud claim, which need not contain the client id.
 	oidcConfig := &oidc.Config{
-		ClientID: clientId,
+		ClientID:             clientId,
+		SkipClientIDCheck:    len(authenticator.allowedAudiences) > 0,
+		SupportedSigningAlgs: authenticator.signingAlgs,
+		Now:                  authenticator.clock.Now,
 	}
 
-	verifier := provider.Verifier(oidcConfig)
+	keySet := newRotatingKeySet(c, discovery.JWKSURL, authenticator.jwksMinRefresh, authenticator.jwksRefresh, authenticator.clock)
+	authenticator.verifier = oidc.NewVerifier(discovery.Issuer, keySet, oidcConfig)
 
-	return &keycloakAuthenticator{
-		verifier: verifier,
-	}, nil
+	return authenticator, nil
 }
 
 // ExtractToken extracts the bearer token from the gRPC metadata (authorization header).
@@ -88,12 +206,12 @@ func (authenticator *keycloakAuthenticator) ExtractToken(ctx context.Context) (s
 	}
 
 	// The authorization header should be in the form "Bearer <token>".
-	parts := strings.SplitN(authHeader[0], " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return "", status.Error(codes.Unauthenticated, "invalid authorization header")
+	token, err := ParseBearerToken(authHeader[0])
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
 	}
 
-	return parts[1], nil
+	return token, nil
 }
 
 // ValidateTokenMiddleware validates the JWT token in the authorization header.
@@ -110,6 +228,10 @@ func (authenticator *keycloakAuthenticator) ValidateTokenMiddleware(ctx context.
 		return nil, status.Error(codes.Unauthenticated, fmt.Sprintf("failed to verify token: %v", err))
 	}
 
+	if err := authenticator.VerifyAudience(idToken); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
 	// Get the claims from the token.
 	claims := new(UserAuthClaims)
 	if err := idToken.Claims(claims); err != nil {
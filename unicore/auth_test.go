@@ -0,0 +1,198 @@
+package unicore_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/unidropofficial/unicore-go/unicore"
+)
+
+const (
+	idpRealm    = "test"
+	idpClientID = "unicore-test"
+)
+
+// testIdP serves the discovery document and JWKS of a Keycloak realm
+type testIdP struct {
+	server *httptest.Server
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+}
+
+// newTestIdP starts an IdP publishing a single RSA key with the id "k1" and points the KC.*
+// environment variables read by NewAuthenticator at it
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	idp := &testIdP{keys: map[string]*rsa.PrivateKey{"k1": newRSAKey(t)}}
+	idp.server = httptest.NewServer(http.HandlerFunc(idp.serveHTTP))
+	t.Cleanup(idp.server.Close)
+
+	t.Setenv("KC.BASE_URL", idp.server.URL)
+	t.Setenv("KC.REALM", idpRealm)
+	t.Setenv("KC.CLIENT_ID", idpClientID)
+	return idp
+}
+
+func (idp *testIdP) issuer() string {
+	return idp.server.URL + "/realms/" + idpRealm
+}
+
+func (idp *testIdP) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/realms/" + idpRealm + "/.well-known/openid-configuration":
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.issuer(),
+			"jwks_uri":               idp.issuer() + "/certs",
+			"authorization_endpoint": idp.issuer() + "/auth",
+			"token_endpoint":         idp.issuer() + "/token",
+		})
+	case "/realms/" + idpRealm + "/certs":
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		keys := make([]map[string]string, 0, len(idp.keys))
+		for kid, key := range idp.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   encode(key.N.Bytes()),
+				"e":   encode(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		// Like Keycloak, let clients cache the keys for a long time.
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// setKeys replaces the published keys, as a key rotation does
+func (idp *testIdP) setKeys(keys map[string]*rsa.PrivateKey) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys = keys
+}
+
+// key returns the published key with the given id
+func (idp *testIdP) key(kid string) *rsa.PrivateKey {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.keys[kid]
+}
+
+// claims returns valid claims for a token of the realm
+func (idp *testIdP) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": idp.issuer(),
+		"aud": idpClientID,
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthenticatorAcceptsRS256(t *testing.T) {
+	idp := newTestIdP(t)
+	authenticator, err := unicore.NewAuthenticator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signToken(t, jwt.SigningMethodRS256, "k1", idp.key("k1"), idp.claims())
+	if _, err := authenticator.GetVerifier().Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected an RS256 token to verify: %v", err)
+	}
+}
+
+func TestAuthenticatorRejectsUnexpectedAlgorithms(t *testing.T) {
+	idp := newTestIdP(t)
+	authenticator, err := unicore.NewAuthenticator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyBytes := idp.key("k1").PublicKey.N.Bytes()
+
+	tokens := map[string]string{
+		"ES256": signToken(t, jwt.SigningMethodES256, "k1", ecKey, idp.claims()),
+		// HS256 keyed with the public RSA key is the classic algorithm confusion attack.
+		"HS256": signToken(t, jwt.SigningMethodHS256, "k1", publicKeyBytes, idp.claims()),
+		"none":  signToken(t, jwt.SigningMethodNone, "k1", jwt.UnsafeAllowNoneSignatureType, idp.claims()),
+	}
+	for alg, token := range tokens {
+		t.Run(alg, func(t *testing.T) {
+			if _, err := authenticator.GetVerifier().Verify(context.Background(), token); err == nil {
+				t.Fatalf("expected a token signed with %s to be rejected", alg)
+			}
+		})
+	}
+}
+
+func TestWithSigningAlgorithmsRestrictsAlgorithms(t *testing.T) {
+	idp := newTestIdP(t)
+	authenticator, err := unicore.NewAuthenticator(context.Background(), unicore.WithSigningAlgorithms("ES256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signToken(t, jwt.SigningMethodRS256, "k1", idp.key("k1"), idp.claims())
+	if _, err := authenticator.GetVerifier().Verify(context.Background(), token); err == nil {
+		t.Fatal("expected RS256 to be rejected when only ES256 is accepted")
+	}
+}
+
+func TestWithSigningAlgorithmsRejectsNone(t *testing.T) {
+	newTestIdP(t)
+
+	for name, algs := range map[string][]string{"none": {"RS256", "none"}, "NONE": {"NONE"}, "empty": {}} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := unicore.NewAuthenticator(context.Background(), unicore.WithSigningAlgorithms(algs...)); err == nil {
+				t.Fatalf("expected NewAuthenticator to refuse %v", algs)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultBatchWait is how long a BatchLoader collects keys before querying
+	defaultBatchWait = 2 * time.Millisecond
+	// defaultMaxBatchSize caps the number of keys of one batch query
+	defaultMaxBatchSize = 500
+)
+
+// BatchFunc loads the values of keys in one query. db is bound to the loader's context and scoped
+// to its tenant; keys missing from the returned map are reported as CodeNotFound.
+type BatchFunc[K comparable, V any] func(ctx context.Context, db *gorm.DB, keys []K) (map[K]V, error)
+
+type batchLoaderOptions struct {
+	wait     time.Duration
+	maxBatch int
+}
+
+// BatchLoaderOption customizes the loader returned by NewBatchLoader
+type BatchLoaderOption func(*batchLoaderOptions)
+
+// WithBatchWait sets how long keys are collected before the batch query runs (default 2ms)
+func WithBatchWait(wait time.Duration) BatchLoaderOption {
+	return func(options *batchLoaderOptions) {
+		options.wait = wait
+	}
+}
+
+// WithMaxBatchSize sets the number of keys that triggers the batch query immediately (default 500)
+func WithMaxBatchSize(maxBatch int) BatchLoaderOption {
+	return func(options *batchLoaderOptions) {
+		options.maxBatch = maxBatch
+	}
+}
+
+type pendingBatch[K comparable, V any] struct {
+	keys    []K
+	timer   *time.Timer
+	once    sync.Once
+	done    chan struct{}
+	results map[K]V
+	err     error
+}
+
+type gormBatchLoader[K comparable, V any] struct {
+	ctx     context.Context
+	db      *gorm.DB
+	fn      BatchFunc[K, V]
+	options batchLoaderOptions
+
+	mu      sync.Mutex
+	current *pendingBatch[K, V]
+}
+
+// Load queues key for the next batch and waits for its value. It returns early with the context
+// error when ctx is done; the batch itself runs with the loader's context.
+func (loader *gormBatchLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	loader.mu.Lock()
+	batch := loader.current
+	if batch == nil {
+		batch = &pendingBatch[K, V]{done: make(chan struct{})}
+		batch.timer = time.AfterFunc(loader.options.wait, func() { loader.dispatch(batch) })
+		loader.current = batch
+	}
+	if !slices.Contains(batch.keys, key) {
+		batch.keys = append(batch.keys, key)
+	}
+	full := len(batch.keys) >= loader.options.maxBatch
+	loader.mu.Unlock()
+
+	if full {
+		batch.timer.Stop()
+		go loader.dispatch(batch)
+	}
+
+	var zero V
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-batch.done:
+	}
+
+	if batch.err != nil {
+		return zero, batch.err
+	}
+	value, ok := batch.results[key]
+	if !ok {
+		return zero, connect.NewError(connect.CodeNotFound, fmt.Errorf("%T %v not found", zero, key))
+	}
+	return value, nil
+}
+
+// dispatch runs the batch query once and wakes up every Load waiting on the batch
+func (loader *gormBatchLoader[K, V]) dispatch(batch *pendingBatch[K, V]) {
+	batch.once.Do(func() {
+		loader.mu.Lock()
+		if loader.current == batch {
+			loader.current = nil
+		}
+		loader.mu.Unlock()
+
+		db := loader.db.WithContext(loader.ctx).Scopes(WithTenantScope(loader.ctx))
+		batch.results, batch.err = loader.fn(loader.ctx, db, batch.keys)
+		if batch.err != nil {
+			batch.err = MapGormError(batch.err)
+		}
+		close(batch.done)
+	})
+}
+
+// NewBatchLoader returns a BatchLoader coalescing the keys requested within a short window into a
+// single call of fn, avoiding N+1 queries while building nested responses. Create one loader per
+// request with the request context: its tenant scopes every batch, so loaders must never be
+// shared between requests. Load is safe for concurrent use.
+//
+// Example Usage:
+//
+//	authors := NewBatchLoader(ctx, db, func(ctx context.Context, db *gorm.DB, ids []string) (map[string]Author, error) {
+//	    var rows []Author
+//	    if err := db.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+//	        return nil, err
+//	    }
+//	    byID := make(map[string]Author, len(rows))
+//	    for _, row := range rows {
+//	        byID[row.ID] = row
+//	    }
+//	    return byID, nil
+//	})
+//	author, err := authors.Load(ctx, post.AuthorID)
+func NewBatchLoader[K comparable, V any](ctx context.Context, db *gorm.DB, fn BatchFunc[K, V], opts ...BatchLoaderOption) BatchLoader[K, V] {
+	options := batchLoaderOptions{
+		wait:     defaultBatchWait,
+		maxBatch: defaultMaxBatchSize,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &gormBatchLoader[K, V]{
+		ctx:     ctx,
+		db:      db,
+		fn:      fn,
+		options: options,
+	}
+}
@@ -0,0 +1,147 @@
+package unicore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// redisBlacklistPrefix namespaces revoked token ids in Redis
+	redisBlacklistPrefix = "unicore:revoked:"
+	// defaultBlacklistLookupTimeout bounds a Redis revocation lookup
+	defaultBlacklistLookupTimeout = 200 * time.Millisecond
+)
+
+type blacklistOptions struct {
+	clock         Clock
+	logger        *zap.Logger
+	lookupTimeout time.Duration
+}
+
+// BlacklistOption customizes the blacklists returned by NewMemoryTokenBlacklist and
+// NewRedisTokenBlacklist
+type BlacklistOption func(*blacklistOptions)
+
+// WithBlacklistClock sets the clock used to expire revocations (default the system clock)
+func WithBlacklistClock(clock Clock) BlacklistOption {
+	return func(options *blacklistOptions) {
+		options.clock = clock
+	}
+}
+
+// WithBlacklistLogger logs failed Redis lookups, which are treated as not revoked (default no logging)
+func WithBlacklistLogger(logger *zap.Logger) BlacklistOption {
+	return func(options *blacklistOptions) {
+		options.logger = logger
+	}
+}
+
+// WithBlacklistLookupTimeout bounds each Redis lookup made on an authenticated request (default 200ms)
+func WithBlacklistLookupTimeout(timeout time.Duration) BlacklistOption {
+	return func(options *blacklistOptions) {
+		options.lookupTimeout = timeout
+	}
+}
+
+// newBlacklistOptions applies opts over the defaults
+func newBlacklistOptions(opts []BlacklistOption) blacklistOptions {
+	options := blacklistOptions{
+		clock:         systemClock{},
+		logger:        zap.NewNop(),
+		lookupTimeout: defaultBlacklistLookupTimeout,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+type memoryTokenBlacklist struct {
+	clock   Clock
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// IsRevoked reports whether the token id was revoked and has not expired yet
+func (blacklist *memoryTokenBlacklist) IsRevoked(_ context.Context, jti string) bool {
+	blacklist.mu.Lock()
+	defer blacklist.mu.Unlock()
+
+	expiresAt, ok := blacklist.revoked[jti]
+	if !ok {
+		return false
+	}
+	if blacklist.clock.Now().After(expiresAt) {
+		delete(blacklist.revoked, jti)
+		return false
+	}
+	return true
+}
+
+// Revoke blacklists the token id until the token expires
+func (blacklist *memoryTokenBlacklist) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	blacklist.mu.Lock()
+	defer blacklist.mu.Unlock()
+
+	now := blacklist.clock.Now()
+	for id, expiry := range blacklist.revoked {
+		if now.After(expiry) {
+			delete(blacklist.revoked, id)
+		}
+	}
+
+	blacklist.revoked[jti] = expiresAt
+	return nil
+}
+
+// NewMemoryTokenBlacklist returns a TokenBlacklist kept in process memory. Revocations are not
+// shared between replicas, so use it for single-instance services and tests.
+func NewMemoryTokenBlacklist(opts ...BlacklistOption) TokenBlacklist {
+	return &memoryTokenBlacklist{
+		clock:   newBlacklistOptions(opts).clock,
+		revoked: map[string]time.Time{},
+	}
+}
+
+type redisTokenBlacklist struct {
+	client  redis.UniversalClient
+	options blacklistOptions
+}
+
+// IsRevoked reports whether the token id is blacklisted in Redis. The lookup is bounded by the
+// request context and the lookup timeout. Redis errors are logged and treated as not revoked, so
+// an unavailable Redis does not reject every request.
+func (blacklist *redisTokenBlacklist) IsRevoked(ctx context.Context, jti string) bool {
+	ctx, cancel := context.WithTimeout(ctx, blacklist.options.lookupTimeout)
+	defer cancel()
+
+	count, err := blacklist.client.Exists(ctx, redisBlacklistPrefix+jti).Result()
+	if err != nil {
+		blacklist.options.logger.Warn("token revocation lookup failed, accepting the token",
+			zap.String("jti", jti),
+			zap.Error(err),
+		)
+		return false
+	}
+	return count > 0
+}
+
+// Revoke blacklists the token id with a TTL ending when the token expires
+func (blacklist *redisTokenBlacklist) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := expiresAt.Sub(blacklist.options.clock.Now())
+	if ttl <= 0 {
+		return nil
+	}
+	return blacklist.client.Set(ctx, redisBlacklistPrefix+jti, 1, ttl).Err()
+}
+
+// NewRedisTokenBlacklist returns a TokenBlacklist shared through Redis, so a revocation applies to
+// every service immediately. The clock only sets the TTL of new revocations; Redis expires them on
+// its own clock.
+func NewRedisTokenBlacklist(client redis.UniversalClient, opts ...BlacklistOption) TokenBlacklist {
+	return &redisTokenBlacklist{client: client, options: newBlacklistOptions(opts)}
+}
@@ -0,0 +1,191 @@
+package unicore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type cacheEntry[T any] struct {
+	tenantID  string
+	key       string
+	value     T
+	expiresAt time.Time
+}
+
+type tenantCacheOptions struct {
+	onHit  func(tenantID, key string)
+	onMiss func(tenantID, key string)
+	clock  Clock
+}
+
+// TenantCacheOption customizes the cache returned by NewTenantCache
+type TenantCacheOption func(*tenantCacheOptions)
+
+// WithCacheMetrics registers hooks called on every cache hit and miss, e.g. to increment
+// Prometheus counters. The hooks run synchronously and must be cheap.
+func WithCacheMetrics(onHit, onMiss func(tenantID, key string)) TenantCacheOption {
+	return func(options *tenantCacheOptions) {
+		options.onHit = onHit
+		options.onMiss = onMiss
+	}
+}
+
+// WithCacheClock sets the clock used to expire entries (default the system clock)
+func WithCacheClock(clock Clock) TenantCacheOption {
+	return func(options *tenantCacheOptions) {
+		options.clock = clock
+	}
+}
+
+type lruTenantCache[T any] struct {
+	ttl     time.Duration
+	maxSize int
+	options tenantCacheOptions
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]map[string]*list.Element
+}
+
+// GetOrLoad returns the cached value of key for the tenant in ctx, calling loader and caching its
+// result on a miss. Loader errors are returned and not cached. Concurrent misses on the same key
+// may each call loader.
+func (cache *lruTenantCache[T]) GetOrLoad(ctx context.Context, key string, loader func() (T, error)) (T, error) {
+	var zero T
+
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return zero, ErrMissingTenant
+	}
+
+	if value, ok := cache.get(tenantID, key); ok {
+		if cache.options.onHit != nil {
+			cache.options.onHit(tenantID, key)
+		}
+		return value, nil
+	}
+	if cache.options.onMiss != nil {
+		cache.options.onMiss(tenantID, key)
+	}
+
+	value, err := loader()
+	if err != nil {
+		return zero, err
+	}
+
+	cache.set(tenantID, key, value)
+	return value, nil
+}
+
+// Invalidate drops every entry of the tenant
+func (cache *lruTenantCache[T]) Invalidate(tenantID string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, element := range cache.entries[tenantID] {
+		cache.order.Remove(element)
+	}
+	delete(cache.entries, tenantID)
+}
+
+// InvalidateKey drops the entry of key for the tenant in ctx
+func (cache *lruTenantCache[T]) InvalidateKey(ctx context.Context, key string) {
+	tenantID := tenantFromContext(ctx)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[tenantID][key]; ok {
+		cache.remove(element)
+	}
+}
+
+// get returns the unexpired value of key, marking it as recently used
+func (cache *lruTenantCache[T]) get(tenantID, key string) (T, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[tenantID][key]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	entry := element.Value.(*cacheEntry[T])
+	if cache.options.clock.Now().After(entry.expiresAt) {
+		cache.remove(element)
+		var zero T
+		return zero, false
+	}
+
+	cache.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// set stores the value, evicting the least recently used entries beyond maxSize
+func (cache *lruTenantCache[T]) set(tenantID, key string, value T) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	expiresAt := cache.options.clock.Now().Add(cache.ttl)
+	if element, ok := cache.entries[tenantID][key]; ok {
+		entry := element.Value.(*cacheEntry[T])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	if cache.entries[tenantID] == nil {
+		cache.entries[tenantID] = map[string]*list.Element{}
+	}
+	cache.entries[tenantID][key] = cache.order.PushFront(&cacheEntry[T]{
+		tenantID:  tenantID,
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+
+	for cache.maxSize > 0 && cache.order.Len() > cache.maxSize {
+		cache.remove(cache.order.Back())
+	}
+}
+
+// remove drops the element from the list and its tenant map; the caller must hold mu
+func (cache *lruTenantCache[T]) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry[T])
+	cache.order.Remove(element)
+
+	tenantEntries := cache.entries[entry.tenantID]
+	delete(tenantEntries, entry.key)
+	if len(tenantEntries) == 0 {
+		delete(cache.entries, entry.tenantID)
+	}
+}
+
+// NewTenantCache returns an in-memory TenantCache whose entries live for ttl. Entries are keyed by
+// the tenant in the context as well as the key, so one tenant can never read another's values.
+// Once maxSize entries are stored across all tenants, the least recently used are evicted; zero
+// or less means unbounded.
+//
+// Example Usage:
+//
+//	currencies := NewTenantCache[[]Currency](5*time.Minute, 10000)
+//	list, err := currencies.GetOrLoad(ctx, "all", func() ([]Currency, error) {
+//	    return loadCurrencies(ctx)
+//	})
+func NewTenantCache[T any](ttl time.Duration, maxSize int, opts ...TenantCacheOption) TenantCache[T] {
+	cache := &lruTenantCache[T]{
+		ttl:     ttl,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: map[string]map[string]*list.Element{},
+		options: tenantCacheOptions{clock: systemClock{}},
+	}
+	for _, opt := range opts {
+		opt(&cache.options)
+	}
+	return cache
+}
@@ -0,0 +1,124 @@
+package unicore
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// ClientConstructor is the signature of the New<Service>Client functions generated by
+// protoc-gen-connect-go
+type ClientConstructor[T any] func(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) T
+
+type clientOptions struct {
+	httpClient   connect.HTTPClient
+	retry        RetryPolicy
+	noRetry      bool
+	interceptors []connect.Interceptor
+	connectOpts  []connect.ClientOption
+}
+
+// ClientOption customizes the client returned by NewClient
+type ClientOption func(*clientOptions)
+
+// WithHTTPClient sends the calls with the given HTTP client instead of http.DefaultClient
+func WithHTTPClient(httpClient connect.HTTPClient) ClientOption {
+	return func(options *clientOptions) {
+		options.httpClient = httpClient
+	}
+}
+
+// WithClientRetryPolicy replaces the default RetryPolicy of the client
+func WithClientRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(options *clientOptions) {
+		options.retry = policy
+		options.noRetry = false
+	}
+}
+
+// WithoutClientRetries disables the retry interceptor of the client
+func WithoutClientRetries() ClientOption {
+	return func(options *clientOptions) {
+		options.noRetry = true
+	}
+}
+
+// WithClientInterceptors appends interceptors, such as OpenTelemetry's, after the standard ones
+func WithClientInterceptors(interceptors ...connect.Interceptor) ClientOption {
+	return func(options *clientOptions) {
+		options.interceptors = append(options.interceptors, interceptors...)
+	}
+}
+
+// WithConnectClientOptions passes options such as connect.WithGRPC() to the generated constructor
+func WithConnectClientOptions(opts ...connect.ClientOption) ClientOption {
+	return func(options *clientOptions) {
+		options.connectOpts = append(options.connectOpts, opts...)
+	}
+}
+
+// NewClient creates a connect client for calls between services with the standard client-side
+// interceptors, in this order:
+//   - ForwardCallerInterceptor, forwarding the caller's token, tenant and request id
+//   - OutgoingMetadataInterceptor, so WithServiceAccountContext overrides the forwarded token
+//   - RetryInterceptor with the default RetryPolicy, unless configured otherwise
+//   - TimeBudgetClientInterceptor, sending the time left on every attempt
+//
+// Go cannot construct a generated client from its type alone, so pass its New<Service>Client
+// function. Create clients once at startup and share them; within a handler, pass the handler's
+// context to the call and the caller's identity is propagated automatically.
+//
+// Example Usage:
+//
+//	notifications := NewClient(notificationv1connect.NewNotificationServiceClient, "http://notification:8080")
+//
+//	func (s *server) CreateOrder(ctx context.Context, req *connect.Request[orderv1.CreateOrderRequest]) (*connect.Response[orderv1.CreateOrderResponse], error) {
+//	    // Sent with the caller's Authorization, x-tenant-id and X-Request-ID headers.
+//	    _, err := s.notifications.Notify(ctx, connect.NewRequest(&notificationv1.NotifyRequest{}))
+//	    ...
+//	}
+func NewClient[T any](newClient ClientConstructor[T], baseURL string, opts ...ClientOption) T {
+	options := &clientOptions{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	interceptors := []connect.Interceptor{ForwardCallerInterceptor(), OutgoingMetadataInterceptor()}
+	if !options.noRetry {
+		interceptors = append(interceptors, RetryInterceptor(options.retry))
+	}
+	interceptors = append(interceptors, TimeBudgetClientInterceptor())
+	interceptors = append(interceptors, options.interceptors...)
+
+	connectOpts := append([]connect.ClientOption{connect.WithInterceptors(interceptors...)}, options.connectOpts...)
+	return newClient(options.httpClient, baseURL, connectOpts...)
+}
+
+// ForwardCallerInterceptor is a client-side interceptor that sends the verified token, tenant and
+// request id of the incoming request held by the context in the Authorization, x-tenant-id and
+// X-Request-ID headers. Headers already set on the request are kept.
+//
+// Example Usage:
+//
+//	client := NewServiceClient(http.DefaultClient, url, connect.WithInterceptors(ForwardCallerInterceptor()))
+func ForwardCallerInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if !req.Spec().IsClient {
+				return next(ctx, req)
+			}
+
+			if token, _ := ctx.Value(ContextKeyAccessToken).(string); token != "" && req.Header().Get("Authorization") == "" {
+				req.Header().Set("Authorization", "Bearer "+token)
+			}
+			if tenantID := tenantFromContext(ctx); tenantID != "" && req.Header().Get(XTenantKey) == "" {
+				req.Header().Set(XTenantKey, tenantID)
+			}
+			if requestID, _ := ctx.Value(ContextKeyRequestID).(string); requestID != "" && req.Header().Get(XRequestIDKey) == "" {
+				req.Header().Set(XRequestIDKey, requestID)
+			}
+			return next(ctx, req)
+		}
+	}
+}
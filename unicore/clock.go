@@ -0,0 +1,11 @@
+package unicore
+
+import "time"
+
+// systemClock is the Clock used unless another one is injected
+type systemClock struct{}
+
+// Now returns the current wall clock time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,199 @@
+package unicore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"gorm.io/gorm"
+)
+
+// Cursor is the position of the last row returned by a keyset paginated query. The sort field and
+// direction of the query are part of the cursor, so it cannot be replayed against another ordering.
+type Cursor struct {
+	Sort      string                 `json:"s"`
+	Direction commonv1.SortDirection `json:"d"`
+	// Value is the sort column value of the last row
+	Value string `json:"v"`
+	// ID is the primary key of the last row, breaking ties between equal sort values
+	ID string `json:"i"`
+}
+
+// EncodeCursor serializes the cursor and signs it with HMAC-SHA256 so clients cannot forge or alter
+// it. The secret normally comes from Config.GetCursorSecret and must be shared by every replica.
+//
+// Example Usage:
+//
+//	next, err := EncodeCursor(cfg.GetCursorSecret(), Cursor{Sort: "created_at", Direction: dir, Value: last.CreatedAt.Format(time.RFC3339Nano), ID: last.ID})
+func EncodeCursor(secret []byte, cursor Cursor) (string, error) {
+	if len(secret) == 0 {
+		return "", errors.New("cursor secret must not be empty")
+	}
+
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signCursor(secret, payload)), nil
+}
+
+// DecodeCursor verifies the signature of an encoded cursor and returns it. ErrInvalidCursor is
+// returned when the cursor is malformed, was signed with another secret, was altered, or was
+// issued for a different sort field or direction than the current query.
+//
+// Example Usage:
+//
+//	cursor, err := DecodeCursor(cfg.GetCursorSecret(), req.Msg.GetCursor(), "created_at", dir)
+func DecodeCursor(secret []byte, encoded, sort string, direction commonv1.SortDirection) (*Cursor, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("cursor secret must not be empty")
+	}
+
+	encodedPayload, encodedSignature, found := strings.Cut(encoded, ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !hmac.Equal(signature, signCursor(secret, payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := new(Cursor)
+	if err := json.Unmarshal(payload, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Direction != direction {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// signCursor returns the HMAC-SHA256 of the cursor payload
+func signCursor(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// timeCursorSort is the sort field recorded in time cursors
+const timeCursorSort = "created_at"
+
+// TimeCursor is the position of the last row of a page of a feed ordered by (created_at, id)
+type TimeCursor struct {
+	CreatedAt time.Time
+	ID        string
+	// Backward is set for cursors walking from newer to older rows, see WithTimeCursorBeforeScope
+	Backward bool
+}
+
+// EncodeTimeCursor signs the cursor like EncodeCursor. The timestamp keeps nanosecond precision,
+// so rows sharing a second are not skipped.
+//
+// Example Usage:
+//
+//	next, err := EncodeTimeCursor(cfg.GetCursorSecret(), TimeCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+func EncodeTimeCursor(secret []byte, cursor TimeCursor) (string, error) {
+	return EncodeCursor(secret, Cursor{
+		Sort:      timeCursorSort,
+		Direction: timeCursorDirection(cursor.Backward),
+		Value:     cursor.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ID:        cursor.ID,
+	})
+}
+
+// DecodeTimeCursor verifies and decodes a cursor returned by EncodeTimeCursor. ErrInvalidCursor
+// is returned for malformed or altered cursors, and for cursors of the other direction.
+//
+// Example Usage:
+//
+//	cursor, err := DecodeTimeCursor(cfg.GetCursorSecret(), req.Msg.GetCursor(), false)
+func DecodeTimeCursor(secret []byte, encoded string, backward bool) (*TimeCursor, error) {
+	cursor, err := DecodeCursor(secret, encoded, timeCursorSort, timeCursorDirection(backward))
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &TimeCursor{CreatedAt: createdAt, ID: cursor.ID, Backward: backward}, nil
+}
+
+// timeCursorDirection returns the sort direction of a time cursor walking forward or backward
+func timeCursorDirection(backward bool) commonv1.SortDirection {
+	if backward {
+		return commonv1.SortDirection_SORT_DIRECTION_DESC
+	}
+	return commonv1.SortDirection_SORT_DIRECTION_ASC
+}
+
+// WithTimeCursorScope loads the page of rows following (after, afterID) in (created_at, id)
+// order, oldest first. Rows created at the same instant as the cursor are ordered by id, so none
+// is skipped or repeated across pages. A zero after loads the first page. The limit defaults to
+// DefaultPageLimit and is capped at DefaultMaxPageLimit.
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithTimeCursorScope(cursor.CreatedAt, cursor.ID, req.Msg.GetLimit())).Find(&events)
+func WithTimeCursorScope(after time.Time, afterID string, limit int32) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !after.IsZero() {
+			db = db.Where("(created_at > ? OR (created_at = ? AND id > ?))", after, after, afterID)
+		}
+		return db.Order("created_at ASC").Order("id ASC").Limit(timeCursorLimit(limit))
+	}
+}
+
+// WithTimeCursorBeforeScope loads the page of rows preceding (before, beforeID) in
+// (created_at, id) order, newest first, for walking a feed backward. Reverse the rows to display
+// them chronologically. A zero before loads the newest rows.
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithTimeCursorBeforeScope(cursor.CreatedAt, cursor.ID, req.Msg.GetLimit())).Find(&events)
+func WithTimeCursorBeforeScope(before time.Time, beforeID string, limit int32) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !before.IsZero() {
+			db = db.Where("(created_at < ? OR (created_at = ? AND id < ?))", before, before, beforeID)
+		}
+		return db.Order("created_at DESC").Order("id DESC").Limit(timeCursorLimit(limit))
+	}
+}
+
+// timeCursorLimit applies the default and maximum page limits
+func timeCursorLimit(limit int32) int {
+	if limit <= 0 {
+		return int(DefaultPageLimit)
+	}
+	return int(min(limit, DefaultMaxPageLimit))
+}
+
+// NextTimeCursor returns the cursor of the page following rows, read from its last row with
+// position, or an empty string when rows is shorter than limit and no page follows.
+//
+// Example Usage:
+//
+//	next, err := NextTimeCursor(cfg.GetCursorSecret(), events, req.Msg.GetLimit(), false,
+//	    func(event Event) (time.Time, string) { return event.CreatedAt, event.ID })
+func NextTimeCursor[T any](secret []byte, rows []T, limit int32, backward bool, position func(T) (time.Time, string)) (string, error) {
+	if len(rows) == 0 || len(rows) < timeCursorLimit(limit) {
+		return "", nil
+	}
+	createdAt, id := position(rows[len(rows)-1])
+	return EncodeTimeCursor(secret, TimeCursor{CreatedAt: createdAt, ID: id, Backward: backward})
+}
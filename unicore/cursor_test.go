@@ -0,0 +1,212 @@
+package unicore
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+var testCursorSecret = []byte("cursor-secret")
+
+const (
+	ascending  = commonv1.SortDirection_SORT_DIRECTION_ASC
+	descending = commonv1.SortDirection_SORT_DIRECTION_DESC
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{Sort: "name", Direction: ascending, Value: "bob", ID: "42"}
+	encoded, err := EncodeCursor(testCursorSecret, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeCursor(testCursorSecret, encoded, "name", ascending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != cursor {
+		t.Fatalf("got %+v, want %+v", decoded, cursor)
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	encoded, err := EncodeCursor(testCursorSecret, Cursor{Sort: "name", Direction: ascending, Value: "bob", ID: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, signature, _ := strings.Cut(encoded, ".")
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"name","d":1,"v":"bob","i":"1"}`))
+
+	tests := map[string]string{
+		"altered payload":   forgedPayload + "." + signature,
+		"altered signature": strings.TrimSuffix(encoded, signature) + base64.RawURLEncoding.EncodeToString([]byte("forged")),
+		"unsigned":          strings.TrimSuffix(encoded, "."+signature),
+		"not base64":        "!!!." + signature,
+		"empty":             "",
+	}
+	for name, cursor := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeCursor(testCursorSecret, cursor, "name", ascending)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+			assertCode(t, err, connect.CodeInvalidArgument)
+		})
+	}
+}
+
+func TestDecodeCursorRejectsOtherSecret(t *testing.T) {
+	encoded, err := EncodeCursor([]byte("another-secret"), Cursor{Sort: "name", Direction: ascending, ID: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeCursor(testCursorSecret, encoded, "name", ascending); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestDecodeCursorRejectsCrossQueryReuse(t *testing.T) {
+	encoded, err := EncodeCursor(testCursorSecret, Cursor{Sort: "name", Direction: ascending, Value: "bob", ID: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecodeCursor(testCursorSecret, encoded, "email", ascending); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a cursor of another sort field to be rejected, got %v", err)
+	}
+	if _, err := DecodeCursor(testCursorSecret, encoded, "name", descending); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a cursor of another direction to be rejected, got %v", err)
+	}
+}
+
+func TestCursorRequiresSecret(t *testing.T) {
+	if _, err := EncodeCursor(nil, Cursor{}); err == nil {
+		t.Fatal("expected EncodeCursor to refuse an empty secret")
+	}
+	if _, err := DecodeCursor(nil, "a.b", "name", ascending); err == nil {
+		t.Fatal("expected DecodeCursor to refuse an empty secret")
+	}
+}
+
+type feedEvent struct {
+	ID        string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// seedFeedEvents creates events sharing timestamps: a1..a3 at the first instant, b1 and b2 one
+// nanosecond later and c1 and c2 a second later, inserted out of order
+func seedFeedEvents(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := openTestDB(t, &feedEvent{})
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []feedEvent{
+		{ID: "c2", CreatedAt: first.Add(time.Second)},
+		{ID: "a2", CreatedAt: first},
+		{ID: "b1", CreatedAt: first.Add(time.Nanosecond)},
+		{ID: "a3", CreatedAt: first},
+		{ID: "c1", CreatedAt: first.Add(time.Second)},
+		{ID: "a1", CreatedAt: first},
+		{ID: "b2", CreatedAt: first.Add(time.Nanosecond)},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func feedPosition(event feedEvent) (time.Time, string) {
+	return event.CreatedAt, event.ID
+}
+
+// walkFeed reads every page of the feed, passing the cursor of each page through its encoded form
+func walkFeed(t *testing.T, db *gorm.DB, backward bool, limit int32) []string {
+	t.Helper()
+
+	var ids []string
+	encoded := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("feed did not end, read %v", ids)
+		}
+
+		cursor := &TimeCursor{}
+		if encoded != "" {
+			var err error
+			if cursor, err = DecodeTimeCursor(testCursorSecret, encoded, backward); err != nil {
+				t.Fatal(err)
+			}
+		}
+		scope := WithTimeCursorScope(cursor.CreatedAt, cursor.ID, limit)
+		if backward {
+			scope = WithTimeCursorBeforeScope(cursor.CreatedAt, cursor.ID, limit)
+		}
+
+		var events []feedEvent
+		if err := db.Scopes(scope).Find(&events).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		next, err := NextTimeCursor(testCursorSecret, events, limit, backward, feedPosition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next == "" {
+			return ids
+		}
+		encoded = next
+	}
+}
+
+func TestTimeCursorWalksDuplicateTimestamps(t *testing.T) {
+	db := seedFeedEvents(t)
+
+	for _, limit := range []int32{1, 2, 3, 7} {
+		if got, want := walkFeed(t, db, false, limit), []string{"a1", "a2", "a3", "b1", "b2", "c1", "c2"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("forward with limit %d: got %v, want %v", limit, got, want)
+		}
+		if got, want := walkFeed(t, db, true, limit), []string{"c2", "c1", "b2", "b1", "a3", "a2", "a1"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("backward with limit %d: got %v, want %v", limit, got, want)
+		}
+	}
+}
+
+func TestTimeCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.FixedZone("CET", 3600))
+	encoded, err := EncodeTimeCursor(testCursorSecret, TimeCursor{CreatedAt: createdAt, ID: "a1", Backward: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeTimeCursor(testCursorSecret, encoded, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedAt.Equal(createdAt) || decoded.ID != "a1" || !decoded.Backward {
+		t.Fatalf("unexpected cursor %+v", decoded)
+	}
+	if _, err := DecodeTimeCursor(testCursorSecret, encoded, false); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a backward cursor to be rejected going forward, got %v", err)
+	}
+}
+
+func TestNextTimeCursorLastPage(t *testing.T) {
+	events := []feedEvent{{ID: "a1", CreatedAt: time.Now()}}
+
+	if next, err := NextTimeCursor(testCursorSecret, events, 2, false, feedPosition); err != nil || next != "" {
+		t.Fatalf("expected no cursor after a short page, got %q, %v", next, err)
+	}
+	if next, err := NextTimeCursor(testCursorSecret, nil, 2, false, feedPosition); err != nil || next != "" {
+		t.Fatalf("expected no cursor after an empty page, got %q, %v", next, err)
+	}
+}
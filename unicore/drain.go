@@ -0,0 +1,73 @@
+package unicore
+
+import (
+	"context"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+)
+
+// ShutdownState tells interceptors and health checks whether the service is draining. The zero
+// value is a service that is not draining; get the one flipped on shutdown from
+// LifecycleManager.ShutdownState.
+type ShutdownState struct {
+	draining atomic.Bool
+}
+
+// StartDraining marks the service as draining. It is called by the LifecycleManager before its
+// hooks run, and may be called directly by services managing shutdown themselves.
+func (state *ShutdownState) StartDraining() {
+	state.draining.Store(true)
+}
+
+// Draining reports whether shutdown has started
+func (state *ShutdownState) Draining() bool {
+	return state != nil && state.draining.Load()
+}
+
+// DrainingInterceptor rejects new requests with ErrServerDraining once shutdown has started, so
+// clients retry them on another instance. Requests already past the interceptor are not affected
+// and finish while the HTTP server shuts down. Register it first so rejected requests do no work.
+//
+// Example Usage:
+//
+//	lifecycle := NewLifecycleManager(cfg.Logger(), 20*time.Second)
+//	connect.WithInterceptors(
+//	    middleware.DrainingInterceptor(lifecycle.ShutdownState()),
+//	    middleware.RequestIDUnaryInterceptor(),
+//	)
+func (middleware *grpcAuthMiddleware) DrainingInterceptor(state *ShutdownState) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if state.Draining() {
+				return nil, ErrServerDraining
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+type drainingHealthChecker struct {
+	base  grpchealth.Checker
+	state *ShutdownState
+}
+
+// Check reports NOT_SERVING once draining has started, and the base status otherwise
+func (checker *drainingHealthChecker) Check(ctx context.Context, req *grpchealth.CheckRequest) (*grpchealth.CheckResponse, error) {
+	if checker.state.Draining() {
+		return &grpchealth.CheckResponse{Status: grpchealth.StatusNotServing}, nil
+	}
+	return checker.base.Check(ctx, req)
+}
+
+// NewDrainingHealthChecker wraps a health checker, such as the one returned by HealthChecker, and
+// reports NOT_SERVING once shutdown has started so load balancers stop routing to the instance.
+//
+// Example Usage:
+//
+//	checker := NewDrainingHealthChecker(middleware.HealthChecker("orders.v1.OrderService"), lifecycle.ShutdownState())
+//	mux.Handle(grpchealth.NewHandler(checker))
+func NewDrainingHealthChecker(base grpchealth.Checker, state *ShutdownState) grpchealth.Checker {
+	return &drainingHealthChecker{base: base, state: state}
+}
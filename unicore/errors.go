@@ -0,0 +1,309 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+)
+
+const (
+	// sqlStateUniqueViolation is the Postgres SQLSTATE for unique constraint violations
+	sqlStateUniqueViolation = "23505"
+	// sqlStateSerializationFailure is the Postgres SQLSTATE for serialization failures
+	sqlStateSerializationFailure = "40001"
+	// mysqlDuplicateEntry is the MySQL error number for duplicate key violations
+	mysqlDuplicateEntry = 1062
+)
+
+// HTTPStatusForCode maps a connect code to the HTTP status used by the Connect protocol, so plain
+// HTTP handlers wrapping unicore errors respond consistently with Connect handlers.
+//
+// Example Usage:
+//
+//	http.Error(w, err.Error(), HTTPStatusForCode(connect.CodeOf(err)))
+func HTTPStatusForCode(code connect.Code) int {
+	switch code {
+	case 0:
+		return http.StatusOK
+	case connect.CodeCanceled:
+		return 499
+	case connect.CodeInvalidArgument, connect.CodeFailedPrecondition, connect.CodeOutOfRange:
+		return http.StatusBadRequest
+	case connect.CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case connect.CodeNotFound:
+		return http.StatusNotFound
+	case connect.CodeAlreadyExists, connect.CodeAborted:
+		return http.StatusConflict
+	case connect.CodePermissionDenied:
+		return http.StatusForbidden
+	case connect.CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case connect.CodeUnimplemented:
+		return http.StatusNotImplemented
+	case connect.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case connect.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// MapGormError translates database errors into connect errors with consistent codes:
+//   - gorm.ErrRecordNotFound becomes CodeNotFound
+//   - unique constraint violations (gorm.ErrDuplicatedKey, Postgres 23505, MySQL 1062) become CodeAlreadyExists
+//   - serialization failures (Postgres 40001) become CodeAborted
+//   - context deadline and cancellation become CodeDeadlineExceeded and CodeCanceled
+//
+// Errors that are already connect errors are returned unchanged. Anything else is logged and
+// becomes a CodeInternal error with the generic message "internal error", so driver messages
+// naming tables, columns or queries never reach clients; errors.Is and errors.As still see the
+// original error. A nil err returns nil, so check err before returning the result as an error.
+//
+// Example Usage:
+//
+//	if err := db.First(&record, "id = ?", id).Error; err != nil {
+//	    return nil, MapGormError(err)
+//	}
+func MapGormError(err error) *connect.Error {
+	if err == nil {
+		return nil
+	}
+
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return connect.NewError(connect.CodeNotFound, err)
+	case errors.Is(err, gorm.ErrDuplicatedKey), isUniqueViolation(err):
+		return connect.NewError(connect.CodeAlreadyExists, err)
+	case sqlStateOf(err) == sqlStateSerializationFailure:
+		return connect.NewError(connect.CodeAborted, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return connect.NewError(connect.CodeDeadlineExceeded, err)
+	case errors.Is(err, context.Canceled):
+		return connect.NewError(connect.CodeCanceled, err)
+	default:
+		log.Printf("[MapGormError]: %v", err)
+		return connect.NewError(connect.CodeInternal, &internalError{cause: err})
+	}
+}
+
+// internalError hides the message of its cause from clients while keeping it reachable for
+// errors.Is and errors.As
+type internalError struct {
+	cause error
+}
+
+func (err *internalError) Error() string { return "internal error" }
+func (err *internalError) Unwrap() error { return err.cause }
+
+// isUniqueViolation reports whether err is a Postgres or MySQL duplicate key error
+func isUniqueViolation(err error) bool {
+	if sqlStateOf(err) == sqlStateUniqueViolation {
+		return true
+	}
+	number, ok := mysqlErrorNumber(err)
+	return ok && number == mysqlDuplicateEntry
+}
+
+// sqlStateOf returns the SQLSTATE of Postgres driver errors (pgx and lib/pq), or an empty string
+func sqlStateOf(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
+// mysqlErrorNumber extracts the Number field of a go-sql-driver MySQLError without importing the driver
+func mysqlErrorNumber(err error) (uint16, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		value := reflect.ValueOf(err)
+		if value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct || value.Type().Name() != "MySQLError" {
+			continue
+		}
+		if number := value.FieldByName("Number"); number.IsValid() && number.Kind() == reflect.Uint16 {
+			return uint16(number.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// NewCodedError returns a connect error carrying a stable, machine-readable application error code
+// (e.g. "ERR_TENANT_SUSPENDED") as a google.rpc.ErrorInfo detail, so clients can switch on the
+// reason rather than parsing messages.
+//
+// Example Usage:
+//
+//	return nil, NewCodedError(connect.CodeFailedPrecondition, "ERR_TENANT_SUSPENDED", "tenant is suspended")
+func NewCodedError(code connect.Code, appCode string, msg string) *connect.Error {
+	connectErr := connect.NewError(code, errors.New(msg))
+	addErrorDetail(connectErr, &errdetails.ErrorInfo{Reason: appCode})
+	return connectErr
+}
+
+// AppErrorCode returns the application error code attached by NewCodedError, or an empty string
+// when err carries none
+func AppErrorCode(err error) string {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return ""
+	}
+
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		if err != nil {
+			continue
+		}
+		if info, ok := value.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// NewNotFoundError returns a CodeNotFound error carrying a ResourceInfo detail naming the resource
+// type and id
+//
+// Example Usage:
+//
+//	return nil, NewNotFoundError("product", req.Msg.GetId())
+func NewNotFoundError(resource, id string) *connect.Error {
+	connectErr := connect.NewError(connect.CodeNotFound, fmt.Errorf("%s %s not found", resource, id))
+	addErrorDetail(connectErr, &errdetails.ResourceInfo{
+		ResourceType: resource,
+		ResourceName: id,
+		Description:  "resource not found",
+	})
+	return connectErr
+}
+
+// NewValidationError returns a CodeInvalidArgument error carrying a BadRequest detail with a single
+// field violation, so clients can show the reason next to the offending field
+//
+// Example Usage:
+//
+//	return nil, NewValidationError("email", "must be a valid email address")
+func NewValidationError(field, reason string) *connect.Error {
+	connectErr := connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid %s: %s", field, reason))
+	addErrorDetail(connectErr, &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: reason}},
+	})
+	return connectErr
+}
+
+// NewAlreadyExistsError returns a CodeAlreadyExists error carrying a ResourceInfo detail naming the
+// resource type
+//
+// Example Usage:
+//
+//	return nil, NewAlreadyExistsError("product")
+func NewAlreadyExistsError(resource string) *connect.Error {
+	connectErr := connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("%s already exists", resource))
+	addErrorDetail(connectErr, &errdetails.ResourceInfo{
+		ResourceType: resource,
+		Description:  "resource already exists",
+	})
+	return connectErr
+}
+
+// NewPermissionError returns a CodePermissionDenied error carrying an ErrorInfo detail with reason
+// "PERMISSION_DENIED" and the denied action in its metadata
+//
+// Example Usage:
+//
+//	return nil, NewPermissionError("products.delete")
+func NewPermissionError(action string) *connect.Error {
+	connectErr := connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not allowed to %s", action))
+	addErrorDetail(connectErr, &errdetails.ErrorInfo{
+		Reason:   "PERMISSION_DENIED",
+		Metadata: map[string]string{"action": action},
+	})
+	return connectErr
+}
+
+// addErrorDetail attaches msg to connectErr, dropping it if it cannot be marshaled
+func addErrorDetail(connectErr *connect.Error, msg proto.Message) {
+	if detail, err := connect.NewErrorDetail(msg); err == nil {
+		connectErr.AddDetail(detail)
+	}
+}
+
+// cloneError returns a copy of connectErr with its own metadata, so interceptors can add or remove
+// headers on errors they did not create, such as the package-level Err values shared by every
+// request. The copy keeps the code, message and details but is no longer errors.Is the original.
+// Its metadata is created right away, so copying the copy concurrently is safe.
+func cloneError(connectErr *connect.Error) *connect.Error {
+	cloned := connect.NewError(connectErr.Code(), connectErr.Unwrap())
+	for _, detail := range connectErr.Details() {
+		cloned.AddDetail(detail)
+	}
+	meta := cloned.Meta()
+	for key, values := range connectErr.Meta() {
+		meta[key] = slices.Clone(values)
+	}
+	return cloned
+}
+
+// errorMapping maps errors matching target (errors.Is) to a connect code
+type errorMapping struct {
+	target error
+	code   connect.Code
+}
+
+// WithErrorMapping makes ConnectErrorInterceptor return code for handler errors matching target
+// with errors.Is. Mappings are checked in registration order, before MapGormError.
+//
+// Example Usage:
+//
+//	middleware := NewMiddleware(authenticator, logger, helper,
+//	    WithErrorMapping(domain.ErrInsufficientStock, connect.CodeFailedPrecondition))
+func WithErrorMapping(target error, code connect.Code) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.errorMappings = append(middleware.errorMappings, errorMapping{target: target, code: code})
+	}
+}
+
+// ConnectErrorInterceptor converts handler errors that are not connect errors, which Connect would
+// otherwise surface as CodeUnknown. Errors matching a WithErrorMapping target get its code, the
+// rest go through MapGormError, which defaults to CodeInternal. Connect errors, including wrapped
+// ones, are returned untouched.
+func (middleware *grpcAuthMiddleware) ConnectErrorInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			resp, err := next(ctx, req)
+			if err == nil {
+				return resp, nil
+			}
+
+			var connectErr *connect.Error
+			if errors.As(err, &connectErr) {
+				return resp, err
+			}
+
+			for _, mapping := range middleware.errorMappings {
+				if errors.Is(err, mapping.target) {
+					return resp, connect.NewError(mapping.code, err)
+				}
+			}
+			return resp, MapGormError(err)
+		}
+	}
+}
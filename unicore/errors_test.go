@@ -0,0 +1,163 @@
+package unicore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"gorm.io/gorm"
+)
+
+func TestHTTPStatusForCode(t *testing.T) {
+	tests := map[connect.Code]int{
+		0:                              http.StatusOK,
+		connect.CodeCanceled:           499,
+		connect.CodeUnknown:            http.StatusInternalServerError,
+		connect.CodeInvalidArgument:    http.StatusBadRequest,
+		connect.CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+		connect.CodeNotFound:           http.StatusNotFound,
+		connect.CodeAlreadyExists:      http.StatusConflict,
+		connect.CodePermissionDenied:   http.StatusForbidden,
+		connect.CodeResourceExhausted:  http.StatusTooManyRequests,
+		connect.CodeFailedPrecondition: http.StatusBadRequest,
+		connect.CodeAborted:            http.StatusConflict,
+		connect.CodeOutOfRange:         http.StatusBadRequest,
+		connect.CodeUnimplemented:      http.StatusNotImplemented,
+		connect.CodeInternal:           http.StatusInternalServerError,
+		connect.CodeUnavailable:        http.StatusServiceUnavailable,
+		connect.CodeDataLoss:           http.StatusInternalServerError,
+		connect.CodeUnauthenticated:    http.StatusUnauthorized,
+		connect.Code(99):               http.StatusInternalServerError,
+	}
+
+	for code, want := range tests {
+		if got := HTTPStatusForCode(code); got != want {
+			t.Errorf("HTTPStatusForCode(%v) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestHTTPStatusForWrappedError(t *testing.T) {
+	err := errors.Join(errors.New("loading order"), NewNotFoundError("order", "42"))
+	if got := HTTPStatusForCode(connect.CodeOf(err)); got != http.StatusNotFound {
+		t.Fatalf("expected 404 for a wrapped not found error, got %d", got)
+	}
+}
+
+func TestNewCodedError(t *testing.T) {
+	err := NewCodedError(connect.CodeFailedPrecondition, "ERR_TENANT_SUSPENDED", "tenant is suspended")
+
+	if err.Code() != connect.CodeFailedPrecondition || err.Message() != "tenant is suspended" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := AppErrorCode(err); got != "ERR_TENANT_SUSPENDED" {
+		t.Fatalf("expected ERR_TENANT_SUSPENDED, got %q", got)
+	}
+	if got := AppErrorCode(fmt.Errorf("suspending: %w", err)); got != "ERR_TENANT_SUSPENDED" {
+		t.Fatalf("expected the code of a wrapped error, got %q", got)
+	}
+}
+
+func TestAppErrorCodeWithoutCode(t *testing.T) {
+	for _, err := range []error{nil, errors.New("plain"), connect.NewError(connect.CodeInternal, errors.New("no detail"))} {
+		if got := AppErrorCode(err); got != "" {
+			t.Errorf("AppErrorCode(%v) = %q, want empty", err, got)
+		}
+	}
+}
+
+func TestAppErrorCodeReachesClients(t *testing.T) {
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return nil, NewCodedError(connect.CodeFailedPrecondition, "ERR_TENANT_SUSPENDED", "tenant is suspended")
+	})
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	assertCode(t, err, connect.CodeFailedPrecondition)
+	if got := AppErrorCode(err); got != "ERR_TENANT_SUSPENDED" {
+		t.Fatalf("expected the client to read ERR_TENANT_SUSPENDED, got %q", got)
+	}
+}
+
+func TestMapGormError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want connect.Code
+	}{
+		"not found":             {err: gorm.ErrRecordNotFound, want: connect.CodeNotFound},
+		"duplicated key":        {err: gorm.ErrDuplicatedKey, want: connect.CodeAlreadyExists},
+		"unique violation":      {err: &sqlStateError{state: sqlStateUniqueViolation}, want: connect.CodeAlreadyExists},
+		"serialization failure": {err: &sqlStateError{state: sqlStateSerializationFailure}, want: connect.CodeAborted},
+		"deadline":              {err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: connect.CodeDeadlineExceeded},
+		"canceled":              {err: context.Canceled, want: connect.CodeCanceled},
+		"connect error":         {err: ErrConcurrentModification, want: connect.CodeAborted},
+		"other":                 {err: errors.New("driver: bad connection"), want: connect.CodeInternal},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assertCode(t, MapGormError(tt.err), tt.want)
+		})
+	}
+	if MapGormError(nil) != nil {
+		t.Fatal("expected nil for a nil error")
+	}
+}
+
+func TestMapGormErrorHidesInternalErrors(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	driverErr := errors.New(`pq: relation "tenant_secrets" does not exist`)
+	mapped := MapGormError(fmt.Errorf("loading secrets: %w", driverErr))
+	if mapped.Message() != "internal error" {
+		t.Fatalf("expected a generic message, got %q", mapped.Message())
+	}
+	if !errors.Is(mapped, driverErr) {
+		t.Fatal("expected the original error to stay reachable with errors.Is")
+	}
+	if !strings.Contains(logged.String(), "tenant_secrets") {
+		t.Fatalf("expected the original error to be logged, got %q", logged.String())
+	}
+
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return nil, mapped
+	})
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	assertCode(t, err, connect.CodeInternal)
+	if strings.Contains(err.Error(), "tenant_secrets") {
+		t.Fatalf("expected the driver message to stay on the server, got %v", err)
+	}
+}
+
+func TestCloneErrorOfSharedErrorsIsRaceFree(t *testing.T) {
+	shared := []*connect.Error{ErrServerDraining, ErrInvalidCursor, ErrFullScanNotAllowed}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		for _, err := range shared {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cloned := cloneError(err)
+				cloned.Meta().Set("X-Request-Id", "copy")
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, err := range shared {
+		if len(err.Meta()) != 0 {
+			t.Fatalf("expected %v to keep empty metadata, got %v", err, err.Meta())
+		}
+	}
+}
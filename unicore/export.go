@@ -0,0 +1,102 @@
+package unicore
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"gorm.io/gorm"
+)
+
+// defaultExportBatchSize is the number of rows fetched per page when exporting
+const defaultExportBatchSize int32 = 500
+
+// StreamCSV reads the tenant-scoped rows of T in batches with FindInBatches and writes them to w as
+// CSV, flushing after every batch so large exports never have to be held in memory. Batches are
+// read in primary key order with keyset pagination, so exports of any size neither hit the offset
+// cap of WithPaginationScope nor skip or repeat rows when the table changes mid-export.
+//
+// Parameters:
+//   - req: Batch size (limit) used while reading; page, sort and direction are ignored
+//   - headers: Optional header row written before any data
+//   - rowFn: Converts a single row into its CSV record
+//
+// The export stops with the context error as soon as ctx is cancelled.
+//
+// Example Usage:
+//
+//	err := StreamCSV(ctx, w, db, req, []string{"id", "name"}, func(u User) []string {
+//	    return []string{u.ID, u.Name}
+//	})
+func StreamCSV[T any](ctx context.Context, w io.Writer, db *gorm.DB, req *commonv1.PageRequest, headers []string, rowFn func(T) []string) error {
+	writer := csv.NewWriter(w)
+
+	if len(headers) > 0 {
+		if err := writer.Write(headers); err != nil {
+			return fmt.Errorf("failed to write csv header: %w", err)
+		}
+	}
+
+	limit := req.GetLimit()
+	if limit <= 0 {
+		limit = defaultExportBatchSize
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var rows []T
+	result := db.WithContext(ctx).Scopes(WithTenantScope(ctx)).FindInBatches(&rows, int(limit), func(tx *gorm.DB, _ int) error {
+		for _, row := range rows {
+			if err := writer.Write(rowFn(row)); err != nil {
+				return fmt.Errorf("failed to write csv record: %w", err)
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to flush csv: %w", err)
+		}
+		return ctx.Err()
+	})
+	if result.Error != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("failed to export rows: %w", result.Error)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// StreamRows loads the tenant-scoped rows of T in batches of batchSize with FindInBatches, calling
+// fn for every row, so handlers can write large results to a server stream without holding them
+// in memory. Batches are read in primary key order; conditions already set on db are kept. It
+// stops with the context error when ctx is cancelled between batches, and with the error of fn as
+// soon as it fails. A batchSize of zero or less uses the export default of 500.
+//
+// Example Usage:
+//
+//	err := StreamRows(ctx, db.Where("status = ?", "paid"), 1000, func(order Order) error {
+//	    return stream.Send(toProto(order))
+//	})
+func StreamRows[T any](ctx context.Context, db *gorm.DB, batchSize int, fn func(T) error) error {
+	if batchSize <= 0 {
+		batchSize = int(defaultExportBatchSize)
+	}
+
+	var batch []T
+	result := db.WithContext(ctx).Scopes(WithTenantScope(ctx)).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for _, row := range batch {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	})
+	return result.Error
+}
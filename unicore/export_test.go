@@ -0,0 +1,203 @@
+package unicore
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"gorm.io/gorm"
+)
+
+type exportRow struct {
+	ID        uint
+	TenantID  string
+	Name      string
+	CreatedAt int64
+}
+
+func seedExportRows(t *testing.T, db *gorm.DB, tenantID string, count int) {
+	t.Helper()
+
+	rows := make([]exportRow, 0, count)
+	for i := 1; i <= count; i++ {
+		rows = append(rows, exportRow{TenantID: tenantID, Name: fmt.Sprintf("%s-%d", tenantID, i), CreatedAt: int64(i)})
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamCSV(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 5)
+	seedExportRows(t, db, "other", 3)
+
+	var buf bytes.Buffer
+	ctx := withTenant(context.Background(), "acme")
+	// A limit of 2 reads the five rows in three batches.
+	err := StreamCSV(ctx, &buf, db, &commonv1.PageRequest{Limit: 2}, []string{"name"}, func(row exportRow) []string {
+		return []string{row.Name}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"name"}, {"acme-1"}, {"acme-2"}, {"acme-3"}, {"acme-4"}, {"acme-5"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("got %v, want %v", records, want)
+	}
+}
+
+func TestStreamCSVWithoutHeaders(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 1)
+
+	var buf bytes.Buffer
+	err := StreamCSV(withTenant(context.Background(), "acme"), &buf, db, nil, nil, func(row exportRow) []string {
+		return []string{row.Name, "with,comma"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "acme-1,\"with,comma\"\n" {
+		t.Fatalf("unexpected csv %q", got)
+	}
+}
+
+func TestStreamCSVStopsOnCancel(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 5)
+
+	ctx, cancel := context.WithCancel(withTenant(context.Background(), "acme"))
+	var buf bytes.Buffer
+	written := 0
+	err := StreamCSV(ctx, &buf, db, &commonv1.PageRequest{Limit: 2}, nil, func(row exportRow) []string {
+		written++
+		cancel()
+		return []string{row.Name}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if written != 2 {
+		t.Fatalf("expected the export to stop after the first batch, wrote %d rows", written)
+	}
+}
+
+func TestStreamRows(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 5000)
+	seedExportRows(t, db, "other", 100)
+
+	var names []string
+	err := StreamRows(withTenant(context.Background(), "acme"), db, 300, func(row exportRow) error {
+		if row.TenantID != "acme" {
+			return fmt.Errorf("row %d of tenant %s streamed", row.ID, row.TenantID)
+		}
+		names = append(names, row.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 5000 {
+		t.Fatalf("expected 5000 rows, got %d", len(names))
+	}
+	for i, name := range names {
+		if want := fmt.Sprintf("acme-%d", i+1); name != want {
+			t.Fatalf("expected row %d to be %s, got %s", i, want, name)
+		}
+	}
+}
+
+func TestStreamRowsKeepsConditions(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 10)
+
+	count := 0
+	err := StreamRows(withTenant(context.Background(), "acme"), db.Where("created_at > ?", 7), 0, func(exportRow) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected the 3 matching rows, got %d", count)
+	}
+}
+
+func TestStreamRowsStopsOnError(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 10)
+
+	errStreamClosed := errors.New("stream closed")
+	sent := 0
+	err := StreamRows(withTenant(context.Background(), "acme"), db, 4, func(exportRow) error {
+		sent++
+		if sent == 6 {
+			return errStreamClosed
+		}
+		return nil
+	})
+	if !errors.Is(err, errStreamClosed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if sent != 6 {
+		t.Fatalf("expected streaming to stop at the failing row, sent %d rows", sent)
+	}
+}
+
+func TestStreamRowsStopsOnCancel(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 10)
+
+	ctx, cancel := context.WithCancel(withTenant(context.Background(), "acme"))
+	sent := 0
+	err := StreamRows(ctx, db, 4, func(exportRow) error {
+		sent++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if sent != 4 {
+		t.Fatalf("expected streaming to stop after the first batch, sent %d rows", sent)
+	}
+}
+
+func TestStreamCSVPastOffsetCap(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	rows := int(DefaultMaxPageOffset) + 500
+	seedExportRows(t, db, "acme", rows)
+
+	var buf bytes.Buffer
+	err := StreamCSV(withTenant(context.Background(), "acme"), &buf, db, &commonv1.PageRequest{Limit: 1000}, nil, func(row exportRow) []string {
+		return []string{row.Name}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != rows {
+		t.Fatalf("expected all %d rows, got %d", rows, len(records))
+	}
+	if last := records[len(records)-1][0]; last != fmt.Sprintf("acme-%d", rows) {
+		t.Fatalf("expected the export to end with the last row, got %s", last)
+	}
+}
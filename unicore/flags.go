@@ -0,0 +1,117 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"connectrpc.com/connect"
+)
+
+// ContextKeyFeatureFlags is used to store the feature flags of the request's tenant in context.
+const ContextKeyFeatureFlags = "FeatureFlagsKey"
+
+type memoryFlagStore struct {
+	flags map[string][]string
+}
+
+// Enabled reports whether the feature is in the tenant's list
+func (store *memoryFlagStore) Enabled(tenantID, feature string) bool {
+	return slices.Contains(store.flags[tenantID], feature)
+}
+
+// NewMemoryFlagStore returns a FlagStore serving a fixed map of tenants to their enabled features,
+// e.g. loaded from configuration at startup
+//
+// Example Usage:
+//
+//	flags := NewMemoryFlagStore(map[string][]string{"acme": {"invoicing", "exports"}})
+func NewMemoryFlagStore(flags map[string][]string) FlagStore {
+	copied := make(map[string][]string, len(flags))
+	for tenantID, features := range flags {
+		copied[tenantID] = slices.Clone(features)
+	}
+	return &memoryFlagStore{flags: copied}
+}
+
+// requestFlags caches the flag lookups of one request
+type requestFlags struct {
+	store    FlagStore
+	tenantID string
+
+	mu      sync.Mutex
+	results map[string]bool
+}
+
+// enabled looks the feature up once per request
+func (flags *requestFlags) enabled(feature string) bool {
+	flags.mu.Lock()
+	defer flags.mu.Unlock()
+
+	if enabled, ok := flags.results[feature]; ok {
+		return enabled
+	}
+	enabled := flags.tenantID != "" && flags.store.Enabled(flags.tenantID, feature)
+	flags.results[feature] = enabled
+	return enabled
+}
+
+// IsFeatureEnabled reports whether the feature is enabled for the tenant of the request, for
+// branching inside handlers. Lookups are cached for the rest of the request. It returns false when
+// FeatureFlagInterceptor did not run.
+//
+// Example Usage:
+//
+//	if IsFeatureEnabled(ctx, "new-pricing") {
+//	    return newPricing(ctx, req)
+//	}
+func IsFeatureEnabled(ctx context.Context, feature string) bool {
+	flags, ok := ctx.Value(ContextKeyFeatureFlags).(*requestFlags)
+	return ok && flags.enabled(feature)
+}
+
+// WithFeatureDisabledCode sets the code returned by FeatureFlagInterceptor when the tenant lacks a
+// required feature (default CodePermissionDenied), e.g. CodeUnimplemented to hide the procedure
+func WithFeatureDisabledCode(code connect.Code) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.featureDisabledCode = code
+	}
+}
+
+// FeatureFlagInterceptor rejects calls to procedures listed in RouteConfig.FeaturesRequired when
+// the tenant of the request lacks one of their features, with CodePermissionDenied unless
+// WithFeatureDisabledCode says otherwise. It also makes the flags available to
+// IsFeatureEnabled. Register it after UnaryTenantInterceptor; calls without a tenant have no
+// feature enabled.
+//
+// Example Usage:
+//
+//	middleware := NewMiddleware(authenticator, logger, helper, WithRouteConfig(RouteConfig{
+//	    FeaturesRequired: map[string][]string{"/billing.v1.InvoiceService/Create": {"invoicing"}},
+//	}))
+//	connect.WithInterceptors(
+//	    middleware.UnaryTenantInterceptor(),
+//	    middleware.FeatureFlagInterceptor(flags),
+//	)
+func (middleware *grpcAuthMiddleware) FeatureFlagInterceptor(flags FlagStore) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			requested := &requestFlags{
+				store:    flags,
+				tenantID: tenantFromContext(ctx),
+				results:  map[string]bool{},
+			}
+			ctx = context.WithValue(ctx, ContextKeyFeatureFlags, requested)
+
+			for _, feature := range middleware.routes.FeaturesRequired[req.Spec().Procedure] {
+				if !requested.enabled(feature) {
+					return nil, connect.NewError(middleware.featureDisabledCode,
+						fmt.Errorf("feature %q is not enabled for this tenant", feature))
+				}
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
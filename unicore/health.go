@@ -0,0 +1,101 @@
+package unicore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"connectrpc.com/grpchealth"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// defaultStreamProbeTTL is how long a stream probe result is reused
+	defaultStreamProbeTTL = 5 * time.Second
+	// defaultStreamProbeTimeout bounds a single stream lookup
+	defaultStreamProbeTimeout = 2 * time.Second
+)
+
+type streamHealthChecker struct {
+	base    grpchealth.Checker
+	js      jetstream.JetStream
+	stream  string
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu        sync.Mutex
+	healthy   bool
+	checkedAt time.Time
+}
+
+// StreamHealthOption customizes the checker returned by NewStreamHealthChecker
+type StreamHealthOption func(*streamHealthChecker)
+
+// WithStreamProbeTTL sets how long a probe result is reused before NATS is asked again (default 5s)
+func WithStreamProbeTTL(ttl time.Duration) StreamHealthOption {
+	return func(checker *streamHealthChecker) {
+		checker.ttl = ttl
+	}
+}
+
+// WithStreamProbeTimeout bounds the stream lookup so a hung connection cannot block health
+// responses (default 2s)
+func WithStreamProbeTimeout(timeout time.Duration) StreamHealthOption {
+	return func(checker *streamHealthChecker) {
+		checker.timeout = timeout
+	}
+}
+
+// Check reports NOT_SERVING while the stream cannot be found, and the base status otherwise
+func (checker *streamHealthChecker) Check(ctx context.Context, req *grpchealth.CheckRequest) (*grpchealth.CheckResponse, error) {
+	resp, err := checker.base.Check(ctx, req)
+	if err != nil || resp.Status != grpchealth.StatusServing {
+		return resp, err
+	}
+
+	if !checker.streamHealthy(ctx) {
+		return &grpchealth.CheckResponse{Status: grpchealth.StatusNotServing}, nil
+	}
+	return resp, nil
+}
+
+// streamHealthy returns the cached probe result, probing the stream again once it is stale
+func (checker *streamHealthChecker) streamHealthy(ctx context.Context) bool {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	if !checker.checkedAt.IsZero() && time.Since(checker.checkedAt) < checker.ttl {
+		return checker.healthy
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, checker.timeout)
+	defer cancel()
+
+	_, err := checker.js.Stream(probeCtx, checker.stream)
+	checker.healthy = err == nil
+	checker.checkedAt = time.Now()
+	return checker.healthy
+}
+
+// NewStreamHealthChecker wraps a health checker, such as the one returned by HealthChecker, and
+// reports NOT_SERVING whenever the JetStream stream cannot be looked up. Probe results are cached
+// and each lookup has a timeout, so health checks stay fast even when NATS hangs; concurrent
+// checks wait for the running probe instead of starting their own.
+//
+// Example Usage:
+//
+//	checker := NewStreamHealthChecker(middleware.HealthChecker("orders.v1.OrderService"), js, cfg.JetStream().Name)
+//	mux.Handle(grpchealth.NewHandler(checker))
+func NewStreamHealthChecker(base grpchealth.Checker, js jetstream.JetStream, stream string, opts ...StreamHealthOption) grpchealth.Checker {
+	checker := &streamHealthChecker{
+		base:    base,
+		js:      js,
+		stream:  stream,
+		ttl:     defaultStreamProbeTTL,
+		timeout: defaultStreamProbeTimeout,
+	}
+	for _, opt := range opts {
+		opt(checker)
+	}
+	return checker
+}
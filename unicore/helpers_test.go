@@ -0,0 +1,143 @@
+package unicore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/coreos/go-oidc"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testIssuer    = "https://auth.example.com/realms/test"
+	testClientID  = "unicore-test"
+	testProcedure = "/test.v1.TestService/Call"
+)
+
+// unverifiedKeySet trusts every signature, letting tests mint tokens without a signing key. The
+// OIDC verifier still checks the algorithm, issuer, audience and expiry of the tokens.
+type unverifiedKeySet struct{}
+
+func (unverifiedKeySet) VerifySignature(_ context.Context, jwt string) ([]byte, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+	return base64.RawURLEncoding.DecodeString(parts[1])
+}
+
+// newTestToken returns a token for testIssuer and testClientID, valid for an hour, with the given
+// claims added or overriding the defaults
+func newTestToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	payload := map[string]any{
+		"iss": testIssuer,
+		"aud": testClientID,
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for key, value := range claims {
+		payload[key] = value
+	}
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	return encode(map[string]string{"alg": oidc.RS256, "typ": "JWT"}) + "." + encode(payload) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("signature"))
+}
+
+// newTestAuthenticator returns an authenticator verifying tokens minted by newTestToken
+func newTestAuthenticator(opts ...AuthenticatorOption) *keycloakAuthenticator {
+	authenticator := &keycloakAuthenticator{signingAlgs: []string{oidc.RS256}, clock: systemClock{}}
+	for _, opt := range opts {
+		opt(authenticator)
+	}
+	authenticator.verifier = oidc.NewVerifier(testIssuer, unverifiedKeySet{}, &oidc.Config{
+		ClientID:             testClientID,
+		SkipClientIDCheck:    len(authenticator.allowedAudiences) > 0,
+		SupportedSigningAlgs: authenticator.signingAlgs,
+		Now:                  authenticator.clock.Now,
+	})
+	return authenticator
+}
+
+// newTestMiddleware returns a middleware using newTestAuthenticator and a no-op logger
+func newTestMiddleware(opts ...MiddlewareOption) *grpcAuthMiddleware {
+	authenticator := newTestAuthenticator()
+	return NewMiddleware(authenticator, zap.NewNop(), NewContextHelper(authenticator), opts...).(*grpcAuthMiddleware)
+}
+
+// newTestServer serves handler on testProcedure behind the interceptors
+func newTestServer[Req, Res any](t *testing.T, handler func(context.Context, *connect.Request[Req]) (*connect.Response[Res], error), interceptors ...connect.Interceptor) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle(testProcedure, connect.NewUnaryHandler(testProcedure, handler, connect.WithInterceptors(interceptors...)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestClient serves handler on testProcedure behind the interceptors and returns a client for it
+func newTestClient[Req, Res any](t *testing.T, handler func(context.Context, *connect.Request[Req]) (*connect.Response[Res], error), interceptors ...connect.Interceptor) *connect.Client[Req, Res] {
+	t.Helper()
+
+	server := newTestServer(t, handler, interceptors...)
+	return connect.NewClient[Req, Res](server.Client(), server.URL+testProcedure)
+}
+
+// assertCode fails the test unless err is a connect error with the given code
+func assertCode(t *testing.T, err error, code connect.Code) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected %v error, got nil", code)
+	}
+	if got := connect.CodeOf(err); got != code {
+		t.Fatalf("expected %v error, got %v: %v", code, got, err)
+	}
+}
+
+// openTestDB opens an in-memory SQLite database migrated for the given models
+func openTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a new database, so keep a single one.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// withTenant returns a context carrying the tenant like UnaryTenantInterceptor stores it
+func withTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, XTenantKey, tenantID)
+}
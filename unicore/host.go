@@ -0,0 +1,117 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// ContextKeyHost is used to store the host the request was sent to in context.
+const ContextKeyHost = "HostKey"
+
+// DomainResolver returns the tenant owning a custom domain (e.g. "shop.acme.com"), or an empty
+// string when the domain is unknown
+type DomainResolver func(ctx context.Context, host string) (string, error)
+
+// WithDomainResolver resolves hosts outside the base domain of TenantFromHostInterceptor to
+// tenants, for tenants serving the app on their own domain
+func WithDomainResolver(resolver DomainResolver) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.domainResolver = resolver
+	}
+}
+
+// HostHandler stores the host of the request (the Host header, or :authority over HTTP/2) in the
+// request context. Go moves it out of the request headers, so wrap the handler mux with it for
+// TenantFromHostInterceptor to see the host.
+//
+// Example Usage:
+//
+//	server := &http.Server{Handler: HostHandler(mux)}
+func HostHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), ContextKeyHost, r.Host))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// normalizeHost lowercases the host and strips its port and trailing dot
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// TenantFromHostInterceptor derives the tenant from the host of the request when the x-tenant-id
+// header is absent: "acme.app.com" is tenant "acme" for base domain "app.com". The apex domain and
+// its www subdomain carry no tenant, nor do deeper subdomains such as "api.acme.app.com". Other
+// hosts are looked up with the WithDomainResolver resolver, when set. The tenant is stored in the
+// context and in the x-tenant-id header, so the interceptors reading the header see it too.
+//
+// The header always takes precedence over the host. Register it before UnaryTenantInterceptor,
+// which then treats the host tenant like a header; with TenantFromClaim configured, a host tenant
+// the claim does not contain is rejected like a conflicting header. The host is read from the
+// context stored by HostHandler, falling back to the Host request header.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(
+//	    middleware.UnaryTokenInterceptor(publicRoutes...),
+//	    middleware.TenantFromHostInterceptor("app.com"),
+//	    middleware.UnaryTenantInterceptor(),
+//	)
+func (middleware *grpcAuthMiddleware) TenantFromHostInterceptor(baseDomain string) connect.UnaryInterceptorFunc {
+	baseDomain = normalizeHost(baseDomain)
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Header().Get(XTenantKey) != "" {
+				return next(ctx, req)
+			}
+
+			host, _ := ctx.Value(ContextKeyHost).(string)
+			if host == "" {
+				host = req.Header().Get("Host")
+			}
+
+			tenantID, err := middleware.tenantFromHost(ctx, normalizeHost(host), baseDomain)
+			if err != nil {
+				return nil, err
+			}
+			if tenantID == "" {
+				return next(ctx, req)
+			}
+
+			req.Header().Set(XTenantKey, tenantID)
+			return next(context.WithValue(ctx, XTenantKey, tenantID), req)
+		}
+	}
+}
+
+// tenantFromHost returns the tenant encoded in the host, or an empty string when there is none
+func (middleware *grpcAuthMiddleware) tenantFromHost(ctx context.Context, host, baseDomain string) (string, error) {
+	if host == "" || host == baseDomain {
+		return "", nil
+	}
+
+	if subdomain, ok := strings.CutSuffix(host, "."+baseDomain); ok {
+		if subdomain == "www" || strings.Contains(subdomain, ".") {
+			return "", nil
+		}
+		return subdomain, nil
+	}
+
+	if middleware.domainResolver == nil {
+		return "", nil
+	}
+	tenantID, err := middleware.domainResolver(ctx, host)
+	if err != nil {
+		return "", connect.NewError(connect.CodeUnavailable, fmt.Errorf("resolving tenant of %s: %w", host, err))
+	}
+	return tenantID, nil
+}
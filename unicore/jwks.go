@@ -0,0 +1,111 @@
+package unicore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc"
+)
+
+// defaultJWKSMinRefreshInterval rate limits key set refreshes triggered by unverifiable tokens
+const defaultJWKSMinRefreshInterval = 10 * time.Second
+
+// rotatingKeySet wraps the remote JWKS of the issuer. The remote key set caches keys for as long as
+// the IdP's cache-control headers allow, so after a key rotation tokens signed with the new key
+// would be rejected until that cache expires. When verification fails, the cache is dropped and
+// the keys are fetched again, at most once per minRefresh, so rotations are picked up without a
+// restart while forged tokens cannot be used to hammer the IdP.
+//
+// The remote key sets fetch lazily, long after the authenticator was created, so they get fetchCtx:
+// the context given to newRotatingKeySet without its cancellation. It keeps the HTTP client set
+// with oidc.ClientContext, whose timeout bounds every single fetch.
+type rotatingKeySet struct {
+	fetchCtx   context.Context
+	jwksURL    string
+	minRefresh time.Duration
+	clock      Clock
+
+	mu          sync.Mutex
+	current     oidc.KeySet
+	lastRefresh time.Time
+}
+
+// VerifySignature verifies the token against the cached keys, refreshing them once on failure
+func (keySet *rotatingKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	current := keySet.keySet()
+
+	payload, err := current.VerifySignature(ctx, jwt)
+	if err == nil {
+		return payload, nil
+	}
+
+	refreshed, ok := keySet.refresh(current)
+	if !ok {
+		return nil, err
+	}
+	return refreshed.VerifySignature(ctx, jwt)
+}
+
+// keySet returns the key set currently in use
+func (keySet *rotatingKeySet) keySet() oidc.KeySet {
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+	return keySet.current
+}
+
+// refresh replaces stale with a fresh remote key set. It reports false when the keys were refreshed
+// less than minRefresh ago and stale is still the current key set.
+func (keySet *rotatingKeySet) refresh(stale oidc.KeySet) (oidc.KeySet, bool) {
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+
+	if keySet.current != stale {
+		// Another request refreshed the keys while this one was verifying
+		return keySet.current, true
+	}
+	if keySet.clock.Now().Sub(keySet.lastRefresh) < keySet.minRefresh {
+		return nil, false
+	}
+
+	keySet.current = oidc.NewRemoteKeySet(keySet.fetchCtx, keySet.jwksURL)
+	keySet.lastRefresh = keySet.clock.Now()
+	return keySet.current, true
+}
+
+// refreshEvery drops the cached keys on every tick until ctx is done, so keys removed by the IdP
+// stop being accepted even when no token fails verification
+func (keySet *rotatingKeySet) refreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			keySet.mu.Lock()
+			keySet.current = oidc.NewRemoteKeySet(keySet.fetchCtx, keySet.jwksURL)
+			keySet.lastRefresh = keySet.clock.Now()
+			keySet.mu.Unlock()
+		}
+	}
+}
+
+// newRotatingKeySet returns a key set for jwksURL. When refreshInterval is positive the cached keys
+// are also dropped periodically until ctx is done; fetching keys keeps working after that. clock
+// times the minimum refresh interval.
+func newRotatingKeySet(ctx context.Context, jwksURL string, minRefresh, refreshInterval time.Duration, clock Clock) *rotatingKeySet {
+	fetchCtx := context.WithoutCancel(ctx)
+	keySet := &rotatingKeySet{
+		fetchCtx:   fetchCtx,
+		jwksURL:    jwksURL,
+		minRefresh: minRefresh,
+		clock:      clock,
+		current:    oidc.NewRemoteKeySet(fetchCtx, jwksURL),
+	}
+	if refreshInterval > 0 {
+		go keySet.refreshEvery(ctx, refreshInterval)
+	}
+	return keySet
+}
@@ -0,0 +1,103 @@
+package unicore_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/unidropofficial/unicore-go/unicore"
+	"github.com/unidropofficial/unicore-go/unicore/unicoretest"
+)
+
+func TestAuthenticatorPicksUpRotatedKeys(t *testing.T) {
+	idp := newTestIdP(t)
+	clock := unicoretest.NewFakeClock(time.Now())
+	authenticator, err := unicore.NewAuthenticator(context.Background(),
+		unicore.WithJWKSMinRefreshInterval(time.Minute), unicore.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(kid string, key *rsa.PrivateKey) error {
+		_, err := authenticator.GetVerifier().Verify(context.Background(), signToken(t, jwt.SigningMethodRS256, kid, key, idp.claims()))
+		return err
+	}
+
+	oldKey := idp.key("k1")
+	if err := verify("k1", oldKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The IdP rotates its key while the old keys are still cached for an hour.
+	clock.Advance(time.Minute)
+	newKey := newRSAKey(t)
+	idp.setKeys(map[string]*rsa.PrivateKey{"k2": newKey})
+
+	if err := verify("k2", newKey); err != nil {
+		t.Fatalf("expected the rotated key to verify without a restart: %v", err)
+	}
+	if err := verify("k1", oldKey); err == nil {
+		t.Fatal("expected the retired key to be rejected once the keys were refreshed")
+	}
+}
+
+func TestAuthenticatorRateLimitsKeyRefreshes(t *testing.T) {
+	idp := newTestIdP(t)
+	clock := unicoretest.NewFakeClock(time.Now())
+	authenticator, err := unicore.NewAuthenticator(context.Background(),
+		unicore.WithJWKSMinRefreshInterval(time.Minute), unicore.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(kid string, key *rsa.PrivateKey) error {
+		_, err := authenticator.GetVerifier().Verify(context.Background(), signToken(t, jwt.SigningMethodRS256, kid, key, idp.claims()))
+		return err
+	}
+
+	// An unknown key triggers the one refresh allowed in the interval.
+	clock.Advance(time.Minute)
+	if err := verify("forged", newRSAKey(t)); err == nil {
+		t.Fatal("expected a token signed by an unknown key to be rejected")
+	}
+
+	newKey := newRSAKey(t)
+	idp.setKeys(map[string]*rsa.PrivateKey{"k1": idp.key("k1"), "k2": newKey})
+	if err := verify("k2", newKey); err == nil {
+		t.Fatal("expected the keys not to be refreshed again within the minimum interval")
+	}
+
+	clock.Advance(time.Minute)
+	if err := verify("k2", newKey); err != nil {
+		t.Fatalf("expected the new key to verify after the minimum interval: %v", err)
+	}
+}
+
+func TestAuthenticatorFetchesKeysAfterStartupContextEnds(t *testing.T) {
+	idp := newTestIdP(t)
+	clock := unicoretest.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	authenticator, err := unicore.NewAuthenticator(ctx,
+		unicore.WithJWKSMinRefreshInterval(time.Minute), unicore.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Services commonly create the authenticator with a startup context that ends once it is up.
+	cancel()
+
+	verify := func(kid string, key *rsa.PrivateKey) error {
+		_, err := authenticator.GetVerifier().Verify(context.Background(), signToken(t, jwt.SigningMethodRS256, kid, key, idp.claims()))
+		return err
+	}
+	if err := verify("k1", idp.key("k1")); err != nil {
+		t.Fatalf("expected the first fetch to work after the startup context ended: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	newKey := newRSAKey(t)
+	idp.setKeys(map[string]*rsa.PrivateKey{"k2": newKey})
+	if err := verify("k2", newKey); err != nil {
+		t.Fatalf("expected refreshes to work after the startup context ended: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultShutdownTimeout bounds the total time spent running shutdown hooks
+const defaultShutdownTimeout = 30 * time.Second
+
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+type lifecycleManager struct {
+	loggR   *zap.Logger
+	timeout time.Duration
+	state   ShutdownState
+	mu      sync.Mutex
+	hooks   []shutdownHook
+}
+
+// ShutdownState returns the state flipped to draining when shutdown starts, for
+// DrainingInterceptor and NewDrainingHealthChecker
+func (manager *lifecycleManager) ShutdownState() *ShutdownState {
+	return &manager.state
+}
+
+// Register adds a shutdown hook. Hooks run in reverse registration order, so register resources
+// in the order they are started (e.g. DB, then JetStream consumers, then the HTTP server).
+func (manager *lifecycleManager) Register(name string, fn func(ctx context.Context) error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.hooks = append(manager.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Run blocks until SIGINT/SIGTERM is received or ctx is cancelled, then marks the service as
+// draining, runs every hook within the shutdown timeout and returns the joined hook errors.
+func (manager *lifecycleManager) Run(ctx context.Context) error {
+	signalCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	<-signalCtx.Done()
+	manager.state.StartDraining()
+	manager.loggR.Info("shutdown started", zap.Duration("timeout", manager.timeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), manager.timeout)
+	defer cancel()
+
+	return manager.shutdown(shutdownCtx)
+}
+
+// shutdown runs the hooks in reverse registration order until they all finish or ctx expires
+func (manager *lifecycleManager) shutdown(ctx context.Context) error {
+	manager.mu.Lock()
+	hooks := make([]shutdownHook, len(manager.hooks))
+	copy(hooks, manager.hooks)
+	manager.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		start := time.Now()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- hook.fn(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				manager.loggR.Error("shutdown hook failed",
+					zap.String("hook", hook.name),
+					zap.Error(err),
+					zap.Duration("duration", time.Since(start)),
+				)
+				errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+				continue
+			}
+			manager.loggR.Info("shutdown hook completed",
+				zap.String("hook", hook.name),
+				zap.Duration("duration", time.Since(start)),
+			)
+		case <-ctx.Done():
+			manager.loggR.Error("shutdown hook timed out",
+				zap.String("hook", hook.name),
+				zap.Duration("duration", time.Since(start)),
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, ctx.Err()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewLifecycleManager returns a LifecycleManager whose hooks must all finish within timeout
+// (30s when zero)
+//
+// Example Usage:
+//
+//	lifecycle := NewLifecycleManager(cfg.Logger(), 20*time.Second)
+//	lifecycle.Register("database", func(ctx context.Context) error { return sqlDB.Close() })
+//	lifecycle.Register("http", server.Shutdown)
+//	go server.ListenAndServe()
+//	return lifecycle.Run(context.Background())
+func NewLifecycleManager(logger *zap.Logger, timeout time.Duration) LifecycleManager {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	return &lifecycleManager{
+		loggR:   logger,
+		timeout: timeout,
+	}
+}
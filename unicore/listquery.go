@@ -0,0 +1,111 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+const (
+	// XSearchKey is the header carrying a free-text search term for list requests
+	XSearchKey = "x-search"
+	// XFilterPrefix prefixes headers carrying equality filters, e.g. "x-filter-status: active"
+	XFilterPrefix = "x-filter-"
+)
+
+// ListQuery gathers the pagination, search term and equality filters of a list request
+type ListQuery struct {
+	Page    *commonv1.PageRequest
+	Search  string
+	Filters map[string]string
+}
+
+// ParseListQuery extracts a ListQuery from a request. Pagination comes from the PageRequest in the
+// message body. The PageRequest filter is read as comma separated "field:value" equality filters,
+// with any bare terms forming the search text. The x-search and x-filter-<field> headers are
+// applied on top and take precedence.
+func ParseListQuery(req connect.AnyRequest) (*ListQuery, error) {
+	query := &ListQuery{
+		Page:    pageRequestOf(req.Any()),
+		Filters: map[string]string{},
+	}
+
+	var terms []string
+	for _, part := range strings.Split(query.Page.GetFilter(), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field, value, isFilter := strings.Cut(part, ":")
+		if !isFilter {
+			terms = append(terms, part)
+			continue
+		}
+
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid filter %q", part))
+		}
+		query.Filters[field] = strings.TrimSpace(value)
+	}
+	query.Search = strings.Join(terms, " ")
+
+	for key, values := range req.Header() {
+		key = strings.ToLower(key)
+		if field, ok := strings.CutPrefix(key, XFilterPrefix); ok && field != "" && len(values) > 0 {
+			query.Filters[field] = values[0]
+		}
+	}
+	if search := req.Header().Get(XSearchKey); search != "" {
+		query.Search = search
+	}
+
+	return query, nil
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself in search terms
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Scopes returns the tenant, filter, search and pagination scopes of the query. Every filter field
+// must be in allowed, otherwise CodeInvalidArgument is returned. The search term is matched
+// case-insensitively and literally, "%" and "_" included, against searchColumns and ignored when
+// none are given.
+//
+// Example Usage:
+//
+//	query, err := ParseListQuery(req)
+//	scopes, err := query.Scopes(ctx, map[string]bool{"status": true}, "name", "email")
+//	db.Scopes(scopes...).Find(&records)
+func (q *ListQuery) Scopes(ctx context.Context, allowed map[string]bool, searchColumns ...string) ([]func(*gorm.DB) *gorm.DB, error) {
+	scopes := []func(*gorm.DB) *gorm.DB{WithTenantScope(ctx)}
+
+	for field, value := range q.Filters {
+		if !allowed[field] {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("filtering by %q is not allowed", field))
+		}
+		column, value := field, value
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			return db.Where(fmt.Sprintf("%s = ?", column), value)
+		})
+	}
+
+	if q.Search != "" && len(searchColumns) > 0 {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q.Search)) + "%"
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			conditions := make([]string, len(searchColumns))
+			args := make([]interface{}, len(searchColumns))
+			for i, column := range searchColumns {
+				conditions[i] = fmt.Sprintf(`LOWER(%s) LIKE ? ESCAPE '\'`, column)
+				args[i] = pattern
+			}
+			return db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		})
+	}
+
+	return append(scopes, WithPaginationScope(q.Page)), nil
+}
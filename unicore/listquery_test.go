@@ -0,0 +1,177 @@
+package unicore
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+type listRow struct {
+	ID        uint
+	TenantID  string
+	Name      string
+	Email     string
+	Status    string
+	CreatedAt int64
+}
+
+func seedListRows(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := openTestDB(t, &listRow{})
+	rows := []listRow{
+		{TenantID: "acme", Name: "Alice", Email: "alice@example.org", Status: "active", CreatedAt: 1},
+		{TenantID: "acme", Name: "Bob", Email: "bob@example.com", Status: "active", CreatedAt: 2},
+		{TenantID: "acme", Name: "carol_admin", Email: "carol@example.com", Status: "suspended", CreatedAt: 3},
+		{TenantID: "acme", Name: "Dave", Email: "dave@example.org", Status: "active", CreatedAt: 4},
+		{TenantID: "acme", Name: "100% Eve", Email: "eve@example.com", Status: "active", CreatedAt: 5},
+		{TenantID: "other", Name: "Bobby", Email: "bobby@example.org", Status: "active", CreatedAt: 6},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// listRequest builds a sample list request carrying page in its body and the given headers
+func listRequest(page *commonv1.PageRequest, headers map[string]string) *connect.Request[commonv1.PageRequest] {
+	req := connect.NewRequest(page)
+	for key, value := range headers {
+		req.Header().Set(key, value)
+	}
+	return req
+}
+
+func TestListQueryScopes(t *testing.T) {
+	db := seedListRows(t)
+	ctx := withTenant(context.Background(), "acme")
+	allowed := map[string]bool{"status": true}
+	byName := func(filter string) *commonv1.PageRequest {
+		return &commonv1.PageRequest{Filter: filter, Sort: "name", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+	}
+
+	tests := []struct {
+		name     string
+		req      *connect.Request[commonv1.PageRequest]
+		want     []string
+		wantCode connect.Code
+	}{
+		{name: "no filter", req: listRequest(byName(""), nil), want: []string{"100% Eve", "Alice", "Bob", "Dave", "carol_admin"}},
+		{name: "allowed filter", req: listRequest(byName("status:suspended"), nil), want: []string{"carol_admin"}},
+		{name: "filter header", req: listRequest(byName("status:suspended"), map[string]string{"X-Filter-Status": "active"}), want: []string{"100% Eve", "Alice", "Bob", "Dave"}},
+		{name: "rejected filter", req: listRequest(byName("tenant_id:other"), nil), wantCode: connect.CodeInvalidArgument},
+		{name: "rejected filter header", req: listRequest(byName(""), map[string]string{"X-Filter-Email": "bob@example.com"}), wantCode: connect.CodeInvalidArgument},
+		{name: "search name", req: listRequest(byName("BOB"), nil), want: []string{"Bob"}},
+		{name: "search email", req: listRequest(byName("example.org"), nil), want: []string{"Alice", "Dave"}},
+		{name: "search and filter", req: listRequest(byName("status:active, example.org"), nil), want: []string{"Alice", "Dave"}},
+		{name: "search header", req: listRequest(byName("example.org"), map[string]string{XSearchKey: "carol"}), want: []string{"carol_admin"}},
+		{name: "underscore is literal", req: listRequest(byName("_"), nil), want: []string{"carol_admin"}},
+		{name: "percent is literal", req: listRequest(byName("%"), nil), want: []string{"100% Eve"}},
+		{name: "backslash is literal", req: listRequest(byName(`\`), nil), want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scopes, err := query.Scopes(ctx, allowed, "name", "email")
+			if tt.wantCode != 0 {
+				assertCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rows []listRow
+			if err := db.Scopes(scopes...).Find(&rows).Error; err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, row := range rows {
+				names = append(names, row.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestListQueryScopesNormalizesPagination(t *testing.T) {
+	db := openTestDB(t, &listRow{})
+	ctx := withTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name string
+		page *commonv1.PageRequest
+		want []string
+	}{
+		{name: "defaults", page: nil, want: []string{"ORDER BY created_at desc", "LIMIT 20"}},
+		{name: "capped limit", page: &commonv1.PageRequest{Limit: 1000, Sort: "name", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}, want: []string{"ORDER BY name asc", "LIMIT 100"}},
+		{name: "second page", page: &commonv1.PageRequest{Page: 2, Limit: 10}, want: []string{"LIMIT 10 OFFSET 10"}},
+		{name: "invalid sort", page: &commonv1.PageRequest{Sort: "name; DROP TABLE list_rows"}, want: []string{"ORDER BY created_at desc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(listRequest(tt.page, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			scopes, err := query.Scopes(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return tx.Scopes(scopes...).Find(&[]listRow{})
+			})
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Fatalf("expected %q in %s", want, sql)
+				}
+			}
+			if !strings.Contains(sql, "tenant_id") {
+				t.Fatalf("expected a tenant scoped query, got %s", sql)
+			}
+		})
+	}
+}
+
+func TestParseListQuery(t *testing.T) {
+	req := listRequest(&commonv1.PageRequest{Filter: "status:active, red shoes ,size: 42"}, map[string]string{"X-Filter-Color": "red"})
+
+	query, err := ParseListQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Search != "red shoes" {
+		t.Fatalf("expected the bare terms as search, got %q", query.Search)
+	}
+	want := map[string]string{"status": "active", "size": "42", "color": "red"}
+	if !reflect.DeepEqual(query.Filters, want) {
+		t.Fatalf("got filters %v, want %v", query.Filters, want)
+	}
+	if query.Page != req.Msg {
+		t.Fatal("expected the page request of the body")
+	}
+
+	_, err = ParseListQuery(listRequest(&commonv1.PageRequest{Filter: ":active"}, nil))
+	assertCode(t, err, connect.CodeInvalidArgument)
+}
+
+func TestLikeEscaper(t *testing.T) {
+	for term, want := range map[string]string{"50%": `50\%`, "a_b": `a\_b`, `c:\temp`: `c:\\temp`, "plain": "plain"} {
+		if got := likeEscaper.Replace(term); got != want {
+			t.Errorf("escaping %q: got %q, want %q", term, got, want)
+		}
+	}
+}
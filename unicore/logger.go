@@ -0,0 +1,150 @@
+package unicore
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// LogFormatConsole writes human readable, colored log lines
+	LogFormatConsole = "console"
+	// LogFormatJSON writes one JSON object per log entry
+	LogFormatJSON = "json"
+)
+
+// BuildLogger constructs the service logger from the Config: the level comes from GetLogLevel and
+// the format from GetLogFormat. When no format is set, development uses the colored console
+// encoder and every other environment JSON, so logs stay machine readable in production.
+//
+// Within each second, the first GetLogSamplingInitial entries with the same message (and, for
+// request logs, the same method) are written, then only every GetLogSamplingThereafter-th one.
+// Error and higher levels are never sampled. When the initial count is 0, JSON logs are sampled
+// 100/100 and console logs are not sampled; a negative count disables sampling.
+//
+// Example Usage:
+//
+//	logger, err := BuildLogger(cfg)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer logger.Sync()
+func BuildLogger(cfg Config) (*zap.Logger, error) {
+	format := strings.ToLower(strings.TrimSpace(cfg.GetLogFormat()))
+	if format == "" {
+		format = LogFormatJSON
+		if cfg.IsDevelopment() {
+			format = LogFormatConsole
+		}
+	}
+
+	var zapConfig zap.Config
+	switch format {
+	case LogFormatConsole:
+		zapConfig = zap.NewDevelopmentConfig()
+		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	case LogFormatJSON:
+		zapConfig = zap.NewProductionConfig()
+	default:
+		return nil, fmt.Errorf("unsupported log format %q, use %q or %q", format, LogFormatConsole, LogFormatJSON)
+	}
+
+	initial, thereafter := cfg.GetLogSamplingInitial(), cfg.GetLogSamplingThereafter()
+	if initial == 0 && zapConfig.Sampling != nil {
+		initial, thereafter = zapConfig.Sampling.Initial, zapConfig.Sampling.Thereafter
+	}
+	zapConfig.Sampling = nil
+
+	zapConfig.Level = zap.NewAtomicLevelAt(cfg.GetLogLevel())
+	var opts []zap.Option
+	if initial > 0 {
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newSamplingCore(core, time.Second, initial, thereafter)
+		}))
+	}
+	return zapConfig.Build(opts...)
+}
+
+// samplerBuckets bounds the memory of a logSampler; keys sharing a bucket share their counter
+const samplerBuckets = 4096
+
+// logSampler counts entries per key within each tick
+type logSampler struct {
+	tick       time.Duration
+	initial    uint64
+	thereafter uint64
+
+	mu     sync.Mutex
+	counts [samplerBuckets]sampleCounter
+}
+
+type sampleCounter struct {
+	resetAt int64
+	n       uint64
+}
+
+// allow reports whether the entry with the given key should be written
+func (sampler *logSampler) allow(key string, now time.Time) bool {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+
+	counter := &sampler.counts[hash.Sum32()%samplerBuckets]
+	if t := now.UnixNano(); t >= counter.resetAt {
+		counter.resetAt = t + int64(sampler.tick)
+		counter.n = 0
+	}
+	counter.n++
+	if counter.n <= sampler.initial {
+		return true
+	}
+	return sampler.thereafter > 0 && (counter.n-sampler.initial)%sampler.thereafter == 0
+}
+
+// samplingCore samples entries below Error level like zap's sampler, but keys them by the "method"
+// field added with With as well as the message, so a busy procedure does not crowd the request
+// logs of the others out
+type samplingCore struct {
+	zapcore.Core
+	sampler *logSampler
+	method  string
+}
+
+func newSamplingCore(core zapcore.Core, tick time.Duration, initial, thereafter int) zapcore.Core {
+	return &samplingCore{
+		Core: core,
+		sampler: &logSampler{
+			tick:       tick,
+			initial:    uint64(initial),
+			thereafter: uint64(max(thereafter, 0)),
+		},
+	}
+}
+
+func (core *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	method := core.method
+	for _, field := range fields {
+		if field.Key == "method" && field.Type == zapcore.StringType {
+			method = field.String
+		}
+	}
+	return &samplingCore{Core: core.Core.With(fields), sampler: core.sampler, method: method}
+}
+
+func (core *samplingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !core.Enabled(entry.Level) {
+		return checked
+	}
+	if entry.Level < zapcore.ErrorLevel &&
+		!core.sampler.allow(core.method+"\x00"+entry.Level.String()+"\x00"+entry.Message, entry.Time) {
+		return checked
+	}
+	return core.Core.Check(entry, checked)
+}
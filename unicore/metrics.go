@@ -0,0 +1,151 @@
+package unicore
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+const (
+	// metricsStartKey stores the start time of a statement on the GORM instance
+	metricsStartKey = "unicore:metrics_start"
+	// otherTableLabel replaces the names of tables outside the WithMetricsTables allowlist
+	otherTableLabel = "other"
+)
+
+type metricsPlugin struct {
+	registry *prometheus.Registry
+	buckets  []float64
+	tables   []string
+	duration *prometheus.HistogramVec
+	failures *prometheus.CounterVec
+}
+
+// MetricsPluginOption customizes the plugin returned by NewMetricsPlugin
+type MetricsPluginOption func(*metricsPlugin)
+
+// WithMetricsTables adds a table label to the metrics. Only the given tables get their own label
+// value, every other table is reported as "other", so dynamic table names cannot blow up the
+// number of series.
+func WithMetricsTables(tables ...string) MetricsPluginOption {
+	return func(plugin *metricsPlugin) {
+		plugin.tables = tables
+	}
+}
+
+// WithMetricsBuckets sets the histogram buckets of the query durations in seconds (default
+// prometheus.DefBuckets)
+func WithMetricsBuckets(buckets ...float64) MetricsPluginOption {
+	return func(plugin *metricsPlugin) {
+		plugin.buckets = buckets
+	}
+}
+
+// Name returns the name the plugin is registered under
+func (plugin *metricsPlugin) Name() string {
+	return "unicore:metrics"
+}
+
+// Initialize registers the collectors in the registry and the timing callbacks on db
+func (plugin *metricsPlugin) Initialize(db *gorm.DB) error {
+	labels := []string{"operation"}
+	if len(plugin.tables) > 0 {
+		labels = append(labels, "table")
+	}
+
+	plugin.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gorm_query_duration_seconds",
+		Help:    "Duration of database statements executed through GORM.",
+		Buckets: plugin.buckets,
+	}, labels)
+	plugin.failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gorm_query_errors_total",
+		Help: "Database statements executed through GORM that failed, not counting record not found.",
+	}, labels)
+	if err := plugin.registry.Register(plugin.duration); err != nil {
+		return err
+	}
+	if err := plugin.registry.Register(plugin.failures); err != nil {
+		return err
+	}
+
+	callback := db.Callback()
+	errs := []error{
+		callback.Create().Before("gorm:create").Register("unicore:metrics_before_create", plugin.start),
+		callback.Create().After("gorm:create").Register("unicore:metrics_after_create", plugin.observe("create")),
+		callback.Query().Before("gorm:query").Register("unicore:metrics_before_query", plugin.start),
+		callback.Query().After("gorm:query").Register("unicore:metrics_after_query", plugin.observe("query")),
+		callback.Update().Before("gorm:update").Register("unicore:metrics_before_update", plugin.start),
+		callback.Update().After("gorm:update").Register("unicore:metrics_after_update", plugin.observe("update")),
+		callback.Delete().Before("gorm:delete").Register("unicore:metrics_before_delete", plugin.start),
+		callback.Delete().After("gorm:delete").Register("unicore:metrics_after_delete", plugin.observe("delete")),
+		callback.Row().Before("gorm:row").Register("unicore:metrics_before_row", plugin.start),
+		callback.Row().After("gorm:row").Register("unicore:metrics_after_row", plugin.observe("row")),
+		callback.Raw().Before("gorm:raw").Register("unicore:metrics_before_raw", plugin.start),
+		callback.Raw().After("gorm:raw").Register("unicore:metrics_after_raw", plugin.observe("raw")),
+	}
+	return errors.Join(errs...)
+}
+
+// start records the start time of the statement
+func (plugin *metricsPlugin) start(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+// observe returns the callback recording the duration and outcome of an operation
+func (plugin *metricsPlugin) observe(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		labels := prometheus.Labels{"operation": operation}
+		if len(plugin.tables) > 0 {
+			labels["table"] = plugin.tableLabel(db.Statement.Table)
+		}
+
+		plugin.duration.With(labels).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			plugin.failures.With(labels).Inc()
+		}
+	}
+}
+
+// tableLabel returns the table when it is allowlisted, "other" otherwise
+func (plugin *metricsPlugin) tableLabel(table string) string {
+	if slices.Contains(plugin.tables, table) {
+		return table
+	}
+	return otherTableLabel
+}
+
+// NewMetricsPlugin returns a GORM plugin recording the duration of every statement in the
+// gorm_query_duration_seconds histogram and failures in the gorm_query_errors_total counter, both
+// labeled by operation (create, query, update, delete, row or raw). Pass WithMetricsTables to add
+// a table label for an allowlist of tables. The collectors are registered in registry when the
+// plugin is installed, which fails if it is installed twice on the same registry.
+//
+// Example Usage:
+//
+//	registry := prometheus.NewRegistry()
+//	if err := db.Use(NewMetricsPlugin(registry, WithMetricsTables("orders", "products"))); err != nil {
+//	    log.Fatal(err)
+//	}
+func NewMetricsPlugin(registry *prometheus.Registry, opts ...MetricsPluginOption) gorm.Plugin {
+	plugin := &metricsPlugin{
+		registry: registry,
+		buckets:  prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(plugin)
+	}
+	return plugin
+}
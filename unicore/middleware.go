@@ -2,30 +2,232 @@ package unicore
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"connectrpc.com/connect"
 	connectcors "connectrpc.com/cors"
 	"connectrpc.com/grpchealth"
+	"connectrpc.com/grpcreflect"
+	"github.com/coreos/go-oidc"
 	"github.com/rs/cors"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
+// HealthCheckProcedure is the procedure served by the gRPC health checker
+const HealthCheckProcedure = "/" + grpchealth.HealthV1ServiceName + "/Check"
+
 type grpcAuthMiddleware struct {
-	loggR         *zap.Logger
-	authenticator Authenticator
-	contextHelper ContextHelper
+	loggR                *zap.Logger
+	authenticator        Authenticator
+	contextHelper        ContextHelper
+	loggingOptions       LoggingOptions
+	sampleCounters       sync.Map
+	tenantClaim          TenantClaimFunc
+	claimMapper          ClaimMapper
+	blacklist            TokenBlacklist
+	claimsKey            any
+	headerSkip           []string
+	errorMappings        []errorMapping
+	routes               RouteConfig
+	config               Config
+	rejectMissingVersion bool
+	tenantSources        []TenantSource
+	domainResolver       DomainResolver
+	redaction            RedactionStrategy
+	fieldRedaction       map[string]RedactionStrategy
+	featureDisabledCode  connect.Code
+	clock                Clock
+}
+
+// ClaimMapper converts a verified ID token into UserAuthClaims, letting tokens issued by other
+// identity providers (Auth0, Cognito, ...) be adapted to the Keycloak claim shape
+type ClaimMapper func(idToken *oidc.IDToken) (*UserAuthClaims, error)
+
+// WithClaimMapper replaces the default claim parsing of the token interceptors, which decodes the
+// token payload directly into UserAuthClaims
+func WithClaimMapper(mapper ClaimMapper) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.claimMapper = mapper
+	}
+}
+
+// WithMiddlewareClock sets the clock used by interceptors caching results for a while, such as
+// TenantValidationInterceptor (default the system clock)
+func WithMiddlewareClock(clock Clock) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.clock = clock
+	}
+}
+
+// WithTokenBlacklist rejects tokens whose jti has been revoked in the blacklist
+func WithTokenBlacklist(blacklist TokenBlacklist) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.blacklist = blacklist
+	}
+}
+
+// WithClaimsContextKey stores verified claims under a private key derived from name instead of
+// ContextKeyUser, so nested auth layers (e.g. an edge token and a service token) do not overwrite
+// each other's claims. Pass the same name to WithHelperClaimsContextKey, otherwise the
+// ContextHelper given to NewMiddleware, and any other helper, will not see the claims.
+func WithClaimsContextKey(name string) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.claimsKey = claimsContextKey(name)
+	}
+}
+
+// WithHeaderCheckSkipRoutes sets the procedures exempt from RequireHeadersInterceptor
+// (default HealthCheckProcedure)
+func WithHeaderCheckSkipRoutes(routes ...string) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.headerSkip = routes
+	}
+}
+
+// WithRouteConfig sets the access rules read by the token, email verification and feature flag
+// interceptors. Routes passed directly to those interceptors are still honoured in addition to
+// the config. RolesRequired is enforced after token verification, so it does not apply to public
+// routes or to services authenticated by MTLSInterceptor.
+func WithRouteConfig(routes RouteConfig) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.routes = routes
+	}
 }
 
+// PublicRoutes returns the procedures declared public in the RouteConfig
+func (middleware *grpcAuthMiddleware) PublicRoutes() []string {
+	return slices.Clone(middleware.routes.Public)
+}
+
+// checkRoles returns ErrMissingRequiredRole when the procedure requires realm roles the caller
+// holds none of
+func (middleware *grpcAuthMiddleware) checkRoles(ctx context.Context, procedure string) error {
+	required, ok := middleware.routes.RolesRequired[procedure]
+	if !ok || len(required) == 0 {
+		return nil
+	}
+
+	claims := middleware.claims(ctx)
+	if claims == nil {
+		return ErrMissingRequiredRole
+	}
+	for _, role := range required {
+		if slices.Contains(claims.RealmAccess.Roles, role) {
+			return nil
+		}
+	}
+	return ErrMissingRequiredRole
+}
+
+// claims returns the claims stored by the token interceptors under the configured key, or nil
+func (middleware *grpcAuthMiddleware) claims(ctx context.Context) *UserAuthClaims {
+	return claimsFromContextKey(ctx, middleware.claimsKey)
+}
+
+// defaultClaimMapper decodes the token payload into UserAuthClaims
+func defaultClaimMapper(idToken *oidc.IDToken) (*UserAuthClaims, error) {
+	claims := new(UserAuthClaims)
+	if err := idToken.Claims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// TenantClaimFunc returns the tenants a user belongs to according to their token claims
+type TenantClaimFunc func(*UserAuthClaims) []string
+
+// MiddlewareOption customizes the middleware returned by NewMiddleware
+type MiddlewareOption func(*grpcAuthMiddleware)
+
+// WithLoggingOptions configures skipping, sampling and response size limits of LoggingUnaryInterceptor
+func WithLoggingOptions(options LoggingOptions) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.loggingOptions = options
+	}
+}
+
+// TenantSource is a place UnaryTenantInterceptor reads the tenant from
+type TenantSource int
+
+const (
+	// TenantFromHeader reads the tenant from the x-tenant-id header
+	TenantFromHeader TenantSource = iota
+	// TenantFromClaim reads the tenant from the token claims, see WithTenantClaim. Only a claim
+	// naming exactly one tenant is used.
+	TenantFromClaim
+)
+
+// WithTenantSources sets where UnaryTenantInterceptor looks for the tenant, in order (default the
+// header only). Adding TenantFromClaim also rejects requests whose header names a tenant missing
+// from a non-empty claim with ErrTenantMismatch.
+//
+// Example Usage:
+//
+//	middleware := NewMiddleware(authenticator, logger, helper, WithTenantSources(TenantFromHeader, TenantFromClaim))
+func WithTenantSources(sources ...TenantSource) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.tenantSources = sources
+	}
+}
+
+// UnaryTenantInterceptor stores the tenant of the request in the context, reading it from the
+// sources set with WithTenantSources: by default the x-tenant-id header only. With TenantFromClaim
+// configured, the organization claim of the token is used when the header is absent, which
+// requires the token interceptor to run first, and a header that is not one of the claimed tenants
+// is rejected with ErrTenantMismatch. Requests without a tenant are rejected with
+// ErrMissingTenantHeader.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(
+//	    middleware.UnaryTokenInterceptor(publicRoutes...),
+//	    middleware.UnaryTenantInterceptor(),
+//	)
 func (middleware *grpcAuthMiddleware) UnaryTenantInterceptor() connect.UnaryInterceptorFunc {
 	return func(next connect.UnaryFunc) connect.UnaryFunc {
 		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
-			tenantID := req.Header().Get(XTenantKey)
+			header := req.Header().Get(XTenantKey)
+
+			var claimed []string
+			if slices.Contains(middleware.tenantSources, TenantFromClaim) {
+				if claims := middleware.claims(ctx); claims != nil {
+					claimed = middleware.tenantClaim(claims)
+				}
+				if header != "" && len(claimed) > 0 && !slices.Contains(claimed, header) {
+					return nil, ErrTenantMismatch
+				}
+			}
+
+			tenantID := ""
+			for _, source := range middleware.tenantSources {
+				switch {
+				case source == TenantFromHeader && header != "":
+					tenantID = header
+				case source == TenantFromClaim && len(claimed) == 1:
+					tenantID = claimed[0]
+				}
+				if tenantID != "" {
+					break
+				}
+			}
 			if tenantID == "" {
 				return nil, ErrMissingTenantHeader
 			}
@@ -36,11 +238,377 @@ func (middleware *grpcAuthMiddleware) UnaryTenantInterceptor() connect.UnaryInte
 	}
 }
 
+// maxRequestIDLength caps the length of client supplied request ids
+const maxRequestIDLength = 128
+
+// RequestIDUnaryInterceptor reads the X-Request-ID header, generating a new id when absent, stores
+// it in the context and echoes it back in the response headers. Client supplied ids longer than
+// 128 characters or with characters other than letters, digits and "-_.:" are replaced by a
+// generated one, as they end up in logs and outgoing calls. Register it before the logging
+// interceptor so log entries carry the id.
+func (middleware *grpcAuthMiddleware) RequestIDUnaryInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			requestID := req.Header().Get(XRequestIDKey)
+			if !isValidRequestID(requestID) {
+				requestID = newRequestID()
+			}
+
+			newCtx := context.WithValue(ctx, ContextKeyRequestID, requestID)
+			resp, err := next(newCtx, req)
+			if err != nil {
+				var connectErr *connect.Error
+				if errors.As(err, &connectErr) {
+					// The error may be shared with other requests, so set the header on a copy
+					connectErr = cloneError(connectErr)
+					connectErr.Meta().Set(XRequestIDKey, requestID)
+					return resp, connectErr
+				}
+				return resp, err
+			}
+
+			if resp != nil {
+				resp.Header().Set(XRequestIDKey, requestID)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// isValidRequestID reports whether a client supplied request id is short and made of letters,
+// digits and "-_.:" only
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random (version 4) UUID
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// MaxPageInterceptor rejects requests whose PageRequest asks for a page beyond maxPage with
+// CodeInvalidArgument. Requests without a PageRequest are passed through untouched.
+func (middleware *grpcAuthMiddleware) MaxPageInterceptor(maxPage int32) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if pagination := pageRequestOf(req.Any()); pagination != nil {
+				if err := ValidatePageRequest(pagination, maxPage); err != nil {
+					return nil, err
+				}
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// WithTenantClaim configures which claim TenantConsistencyInterceptor compares the tenant header
+// against. Defaults to the organization claim.
+func WithTenantClaim(claim TenantClaimFunc) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.tenantClaim = claim
+	}
+}
+
+// TenantConsistencyInterceptor rejects requests whose tenant is not one of the tenants in the
+// caller's token with CodePermissionDenied, so a user of one tenant cannot read another tenant's
+// data by changing the header. The tenant resolved by UnaryTenantInterceptor is checked, whether it
+// came from the header, the host or the claim; without it the x-tenant-id header is. Service
+// accounts whose azp claim is listed in serviceAccounts may act on any tenant. Register it after
+// the tenant and token interceptors; requests without claims (public routes) are passed through.
+func (middleware *grpcAuthMiddleware) TenantConsistencyInterceptor(serviceAccounts ...string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			claims := middleware.claims(ctx)
+			if claims == nil || slices.Contains(serviceAccounts, claims.Azp) {
+				return next(ctx, req)
+			}
+
+			tenantID := tenantFromContext(ctx)
+			if tenantID == "" {
+				tenantID = req.Header().Get(XTenantKey)
+			}
+			if !slices.Contains(middleware.tenantClaim(claims), tenantID) {
+				return nil, ErrTenantMismatch
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// TimeBudgetInterceptor enforces the total time budget a client has left across its retries, sent in
+// the x-total-budget-ms header. The request deadline is capped to the remaining budget and requests
+// arriving with an exhausted budget are rejected with CodeDeadlineExceeded. Requests without the
+// header are unaffected.
+func (middleware *grpcAuthMiddleware) TimeBudgetInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			header := req.Header().Get(XTotalBudgetKey)
+			if header == "" {
+				return next(ctx, req)
+			}
+
+			budgetMs, err := strconv.ParseInt(header, 10, 64)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid %s header: %v", XTotalBudgetKey, err))
+			}
+
+			if budgetMs <= 0 {
+				middleware.loggR.Warn("request time budget exhausted",
+					zap.String("method", req.Spec().Procedure),
+					zap.Int64("budget_ms", budgetMs),
+				)
+				return nil, ErrTimeBudgetExhausted
+			}
+
+			budgetCtx, cancel := context.WithTimeout(ctx, time.Duration(budgetMs)*time.Millisecond)
+			defer cancel()
+			return next(budgetCtx, req)
+		}
+	}
+}
+
+// TimeBudgetClientInterceptor is a client-side interceptor that sends the time left before the
+// context deadline in the x-total-budget-ms header, so retries of a call share one budget. Calls
+// whose budget is already spent fail with CodeDeadlineExceeded without being sent.
+//
+// Example Usage:
+//
+//	client := NewServiceClient(http.DefaultClient, url, connect.WithInterceptors(TimeBudgetClientInterceptor()))
+func TimeBudgetClientInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok || !req.Spec().IsClient {
+				return next(ctx, req)
+			}
+
+			remaining := time.Until(deadline).Milliseconds()
+			if remaining <= 0 {
+				return nil, ErrTimeBudgetExhausted
+			}
+
+			req.Header().Set(XTotalBudgetKey, strconv.FormatInt(remaining, 10))
+			return next(ctx, req)
+		}
+	}
+}
+
+// DeprecationInterceptor flags deprecated procedures while still serving them. The map values are
+// either a sunset date (RFC 3339 or YYYY-MM-DD), sent as the Sunset header, or a free-form message,
+// sent as a Warning header. Deprecated procedures always receive "Deprecation: true".
+func (middleware *grpcAuthMiddleware) DeprecationInterceptor(deprecated map[string]string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			notice, ok := deprecated[req.Spec().Procedure]
+			if !ok {
+				return next(ctx, req)
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil {
+				var connectErr *connect.Error
+				if errors.As(err, &connectErr) {
+					// The error may be shared with other requests, so set the headers on a copy
+					connectErr = cloneError(connectErr)
+					setDeprecationHeaders(connectErr.Meta(), notice)
+					return resp, connectErr
+				}
+				return resp, err
+			}
+
+			if resp != nil {
+				setDeprecationHeaders(resp.Header(), notice)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// setDeprecationHeaders writes the Deprecation header and either Sunset or Warning for the notice
+func setDeprecationHeaders(header http.Header, notice string) {
+	header.Set("Deprecation", "true")
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if sunset, err := time.Parse(layout, notice); err == nil {
+			header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			return
+		}
+	}
+
+	if notice != "" {
+		header.Set("Warning", fmt.Sprintf("299 - %q", notice))
+	}
+}
+
+// ResponseHeaderFilterInterceptor removes the blocklisted headers (matched case-insensitively) from
+// response headers, trailers and error metadata, so internal headers such as upstream tokens or
+// debug information never reach clients.
+func (middleware *grpcAuthMiddleware) ResponseHeaderFilterInterceptor(blocklist []string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			resp, err := next(ctx, req)
+			if err != nil {
+				var connectErr *connect.Error
+				if errors.As(err, &connectErr) && hasHeader(connectErr.Meta(), blocklist) {
+					// The error may be shared with other requests, so filter a copy
+					connectErr = cloneError(connectErr)
+					removeHeaders(connectErr.Meta(), blocklist)
+					return resp, connectErr
+				}
+				return resp, err
+			}
+
+			// Failed handlers return a typed nil response, so only successful ones are filtered
+			if resp != nil {
+				removeHeaders(resp.Header(), blocklist)
+				removeHeaders(resp.Trailer(), blocklist)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// hasHeader reports whether any header name matches the blocklist case-insensitively
+func hasHeader(header http.Header, blocklist []string) bool {
+	for key := range header {
+		for _, blocked := range blocklist {
+			if strings.EqualFold(key, blocked) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeHeaders deletes every header whose name matches the blocklist case-insensitively
+func removeHeaders(header http.Header, blocklist []string) {
+	for key := range header {
+		for _, blocked := range blocklist {
+			if strings.EqualFold(key, blocked) {
+				delete(header, key)
+				break
+			}
+		}
+	}
+}
+
+// RequireHeadersInterceptor rejects requests missing any of the required headers with
+// CodeInvalidArgument, listing every missing header at once. Headers are matched
+// case-insensitively and an empty value counts as missing. Procedures set with
+// WithHeaderCheckSkipRoutes (by default the health check) are not checked.
+//
+// Example Usage:
+//
+//	interceptor := middleware.RequireHeadersInterceptor("x-client-version", "x-correlation-id")
+func (middleware *grpcAuthMiddleware) RequireHeadersInterceptor(required ...string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if slices.Contains(middleware.headerSkip, req.Spec().Procedure) {
+				return next(ctx, req)
+			}
+
+			var missing []string
+			for _, header := range required {
+				if req.Header().Get(header) == "" {
+					missing = append(missing, header)
+				}
+			}
+			if len(missing) > 0 {
+				return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("missing required headers: %s", strings.Join(missing, ", ")))
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// UnaryTimezoneInterceptor stores the caller's timezone and locale in the context. The timezone is
+// read from the x-timezone header, falling back to the zoneinfo claim, and defaults to UTC when
+// missing or invalid. Register it after the token interceptor so the claims are available.
+func (middleware *grpcAuthMiddleware) UnaryTimezoneInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			zone := req.Header().Get(XTimezoneKey)
+			locale := ""
+			if claims := middleware.claims(ctx); claims != nil {
+				if zone == "" {
+					zone = claims.Zoneinfo
+				}
+				locale = claims.Locale
+			}
+
+			location, err := time.LoadLocation(zone)
+			if zone == "" || err != nil {
+				location = time.UTC
+			}
+
+			newCtx := context.WithValue(ctx, ContextKeyTimezone, location)
+			newCtx = context.WithValue(newCtx, ContextKeyLocale, locale)
+			return next(newCtx, req)
+		}
+	}
+}
+
+// SignedTenantInterceptor rejects requests whose x-tenant-id header is not accompanied by a valid
+// x-tenant-signature, the hex encoded HMAC-SHA256 of the tenant id under the shared secret (see
+// SignTenant). It is opt-in and meant for deployments where a gateway signs the tenant header.
+func (middleware *grpcAuthMiddleware) SignedTenantInterceptor(secret []byte) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			tenantID := req.Header().Get(XTenantKey)
+			if tenantID == "" {
+				return nil, ErrMissingTenantHeader
+			}
+
+			signature, err := hex.DecodeString(req.Header().Get(XTenantSignatureKey))
+			if err != nil || len(signature) == 0 {
+				return nil, ErrInvalidTenantSignature
+			}
+
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(tenantID))
+			if !hmac.Equal(signature, mac.Sum(nil)) {
+				return nil, ErrInvalidTenantSignature
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// SignTenant returns the x-tenant-signature value for a tenant id under the shared secret
+func SignTenant(secret []byte, tenantID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(tenantID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func (middleware *grpcAuthMiddleware) UnaryTokenInterceptor(routes ...string) connect.UnaryInterceptorFunc {
 	return func(next connect.UnaryFunc) connect.UnaryFunc {
 		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
 			fullMethod := req.Spec().Procedure
-			if slices.Contains(routes, fullMethod) {
+			if slices.Contains(routes, fullMethod) || slices.Contains(middleware.routes.Public, fullMethod) {
+				return next(ctx, req)
+			}
+			if _, ok := ServicePrincipalFromContext(ctx); ok {
 				return next(ctx, req)
 			}
 
@@ -49,50 +617,150 @@ func (middleware *grpcAuthMiddleware) UnaryTokenInterceptor(routes ...string) co
 				return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("missing or invalid token: %v", err))
 			}
 
-			idToken, err := middleware.authenticator.GetVerifier().Verify(ctx, token)
+			newCtx, err := middleware.authenticate(ctx, token)
 			if err != nil {
-				return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("invalid token: %v", err))
+				return nil, err
+			}
+			if err := middleware.checkRoles(newCtx, fullMethod); err != nil {
+				return nil, err
+			}
+			return next(newCtx, req)
+		}
+	}
+}
+
+// UnaryTokenInterceptorFromContext behaves like UnaryTokenInterceptor but reads the bearer token
+// from the incoming gRPC metadata carried by the context (e.g. behind a gRPC gateway) instead of
+// the connect request headers.
+func (middleware *grpcAuthMiddleware) UnaryTokenInterceptorFromContext(routes ...string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			fullMethod := req.Spec().Procedure
+			if slices.Contains(routes, fullMethod) || slices.Contains(middleware.routes.Public, fullMethod) {
+				return next(ctx, req)
+			}
+			if _, ok := ServicePrincipalFromContext(ctx); ok {
+				return next(ctx, req)
 			}
 
-			claims := new(UserAuthClaims)
-			if err := idToken.Claims(claims); err != nil {
-				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to parse token claims: %v", err))
+			token, err := middleware.contextHelper.GetAccessTokenFromContext(ctx)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("missing or invalid token: %v", err))
 			}
 
-			newCtx := context.WithValue(ctx, ContextKeyUser, claims)
+			newCtx, err := middleware.authenticate(ctx, token)
+			if err != nil {
+				return nil, err
+			}
+			if err := middleware.checkRoles(newCtx, fullMethod); err != nil {
+				return nil, err
+			}
 			return next(newCtx, req)
 		}
 	}
 }
 
-// LoggingUnaryInterceptor logs sanitized gRPC request and response data
+// RequireVerifiedEmailInterceptor rejects callers whose token has email_verified set to false with
+// ErrEmailNotVerified. The given routes, such as the resend-verification endpoint, are exempt.
+// Requests without claims (public routes) are passed through, so register it after the token
+// interceptor.
+func (middleware *grpcAuthMiddleware) RequireVerifiedEmailInterceptor(routes ...string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			procedure := req.Spec().Procedure
+			if slices.Contains(routes, procedure) || slices.Contains(middleware.routes.UnverifiedEmailAllowed, procedure) {
+				return next(ctx, req)
+			}
+
+			if claims := middleware.claims(ctx); claims != nil && !claims.EmailVerified {
+				return nil, ErrEmailNotVerified
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// authenticate verifies the raw token, parses its claims and stores them in the returned context
+func (middleware *grpcAuthMiddleware) authenticate(ctx context.Context, token string) (context.Context, error) {
+	idToken, err := middleware.authenticator.GetVerifier().Verify(ctx, token)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("invalid token: %v", err))
+	}
+
+	if verifier, ok := middleware.authenticator.(audienceVerifier); ok {
+		if err := verifier.VerifyAudience(idToken); err != nil {
+			return nil, err
+		}
+	}
+
+	claims, err := middleware.claimMapper(idToken)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to parse token claims: %v", err))
+	}
+
+	// A token without a subject is anonymous and cannot be used on authenticated routes.
+	if claims.Id == "" {
+		return nil, ErrMissingTokenSubject
+	}
+
+	if middleware.blacklist != nil && claims.Jti != "" && middleware.blacklist.IsRevoked(ctx, claims.Jti) {
+		return nil, ErrTokenRevoked
+	}
+
+	ctx = context.WithValue(ctx, ContextKeyAccessToken, token)
+	return context.WithValue(ctx, middleware.claimsKey, claims), nil
+}
+
+// LoggingUnaryInterceptor logs gRPC requests with their duration and code. Every entry carries the
+// method, request id, tenant (as resolved by UnaryTenantInterceptor) and user (sub) when known.
+// Sanitized request and response bodies are only included when debug logging is enabled, see
+// bodyLoggingEnabled. Interceptors run in the order they are passed to connect.WithInterceptors,
+// so the recommended order is:
+//
+//	connect.WithInterceptors(
+//	    middleware.RequestIDUnaryInterceptor(),
+//	    middleware.UnaryTokenInterceptor(publicRoutes...),
+//	    middleware.UnaryTenantInterceptor(),
+//	    middleware.LoggingUnaryInterceptor(),
+//	)
+//
+// Placing the token interceptor before logging means rejected tokens are not logged here; swap
+// them if authentication failures must appear in request logs (they will then lack the user).
+// With a logger built by BuildLogger, repeated successful requests are sampled per method, while
+// failures logged at Error are always written.
 func (middleware *grpcAuthMiddleware) LoggingUnaryInterceptor() connect.UnaryInterceptorFunc {
 	return func(next connect.UnaryFunc) connect.UnaryFunc {
 		return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
 			start := time.Now()
 			fullMethod := request.Spec().Procedure
+			logger := middleware.loggR.With(zap.String("method", fullMethod))
+			if requestID := middleware.contextHelper.GetRequestID(ctx); requestID != "" {
+				logger = logger.With(zap.String("request_id", requestID))
+			}
+			if tenantID := tenantFromContext(ctx); tenantID != "" {
+				logger = logger.With(zap.String("tenant", tenantID))
+			}
+			if claims := middleware.claims(ctx); claims != nil {
+				logger = logger.With(zap.String("user", claims.Id))
+			}
 
-			sanitizedReq := middleware.sanitizeRequest(request)
-
-			middleware.loggR.Info("gRPC request received",
-				zap.String("method", fullMethod),
-				zap.Any("request", sanitizedReq),
-			)
+			logSuccess := middleware.shouldLog(fullMethod)
+			if logSuccess {
+				logger.Info("gRPC request received",
+					middleware.bodyField("request", request.Any()),
+				)
+			}
 
 			resp, err := next(ctx, request)
 			duration := time.Since(start)
 
 			if err != nil {
-				middleware.loggR.Error("gRPC request failed",
-					zap.String("method", fullMethod),
-					zap.Error(err),
-					zap.Duration("duration", duration),
-				)
-			} else {
-				middleware.loggR.Info("gRPC request completed",
-					zap.String("method", fullMethod),
-					zap.Any("response", resp),
+				logFailure(logger, "gRPC request failed", err, zap.Duration("duration", duration))
+			} else if logSuccess {
+				logger.Info("gRPC request completed",
+					middleware.bodyField("response", responseMessage(resp)),
 					zap.Duration("duration", duration),
+					zap.String("code", "ok"),
 				)
 			}
 
@@ -101,6 +769,95 @@ func (middleware *grpcAuthMiddleware) LoggingUnaryInterceptor() connect.UnaryInt
 	}
 }
 
+// logFailure logs a failed call with its connect code. Codes caused by the client are logged at
+// Warn so they do not trigger error alerts; everything else is logged at Error.
+func logFailure(logger *zap.Logger, msg string, err error, fields ...zap.Field) {
+	code := connect.CodeOf(err)
+	fields = append(fields, zap.String("code", code.String()), zap.Error(err))
+
+	switch code {
+	case connect.CodeCanceled, connect.CodeInvalidArgument, connect.CodeNotFound, connect.CodeAlreadyExists,
+		connect.CodePermissionDenied, connect.CodeFailedPrecondition, connect.CodeOutOfRange, connect.CodeUnauthenticated:
+		logger.Warn(msg, fields...)
+	default:
+		logger.Error(msg, fields...)
+	}
+}
+
+// shouldLog reports whether a successful call to the procedure should be logged, honouring the
+// skip list and sampling rates of the logging options
+func (middleware *grpcAuthMiddleware) shouldLog(procedure string) bool {
+	if slices.Contains(middleware.loggingOptions.SkipProcedures, procedure) {
+		return false
+	}
+
+	rate := middleware.loggingOptions.SampleRates[procedure]
+	if rate <= 1 {
+		return true
+	}
+
+	counter, _ := middleware.sampleCounters.LoadOrStore(procedure, new(atomic.Uint64))
+	return (counter.(*atomic.Uint64).Add(1)-1)%rate == 0
+}
+
+// responseMessage returns the message of a response, or nil when there is none
+func responseMessage(resp connect.AnyResponse) any {
+	if resp == nil {
+		return nil
+	}
+	return resp.Any()
+}
+
+// marshalSanitized encodes a message as JSON after masking its sensitive fields
+func (middleware *grpcAuthMiddleware) marshalSanitized(msg any) ([]byte, error) {
+	sanitized := middleware.sanitizeMessage(msg)
+	if protoMsg, ok := sanitized.(proto.Message); ok {
+		return protojson.Marshal(protoMsg)
+	}
+	return json.Marshal(sanitized)
+}
+
+// defaultMaxBodyBytes is the default limit of a logged request or response body
+const defaultMaxBodyBytes = 4 * 1024
+
+// bodyLoggingEnabled reports whether message bodies are logged, which is only the case at Debug
+// level: the level of the Config given with WithConfig, or else the level enabled on the logger
+func (middleware *grpcAuthMiddleware) bodyLoggingEnabled() bool {
+	if middleware.config != nil {
+		return middleware.config.GetLogLevel() <= zapcore.DebugLevel
+	}
+	return middleware.loggR.Core().Enabled(zapcore.DebugLevel)
+}
+
+// bodyField returns the log field for a sanitized message encoded as JSON, or a skipped field when
+// body logging is disabled. Bodies larger than MaxBodyBytes are replaced by a truncated prefix,
+// their total size and SHA-256 hash.
+func (middleware *grpcAuthMiddleware) bodyField(key string, msg any) zap.Field {
+	if msg == nil || !middleware.bodyLoggingEnabled() {
+		return zap.Skip()
+	}
+
+	data, err := middleware.marshalSanitized(msg)
+	if err != nil {
+		return zap.Any(key, middleware.sanitizeMessage(msg))
+	}
+
+	maxBytes := middleware.loggingOptions.MaxBodyBytes
+	if maxBytes == 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+	if maxBytes > 0 && len(data) > maxBytes {
+		sum := sha256.Sum256(data)
+		return zap.Dict(key,
+			zap.String("truncated", strings.ToValidUTF8(string(data[:maxBytes]), "")),
+			zap.Int("size", len(data)),
+			zap.String("sha256", hex.EncodeToString(sum[:])),
+		)
+	}
+
+	return zap.Any(key, json.RawMessage(data))
+}
+
 // CorsMiddleware sets CORS configuration for HTTP server
 func (middleware *grpcAuthMiddleware) CorsMiddleware(h http.Handler) http.Handler {
 	c := cors.New(cors.Options{
@@ -119,20 +876,97 @@ func (middleware *grpcAuthMiddleware) HealthChecker(srvName string) *grpchealth.
 	return grpchealth.NewStaticChecker(srvName)
 }
 
-// sanitizeRequest masks sensitive fields in request struct
-func (middleware *grpcAuthMiddleware) sanitizeRequest(req interface{}) interface{} {
-	sensitiveFields := map[string]struct{}{
-		"password": {},
-		"token":    {},
-		"secret":   {},
-		"apikey":   {},
-		"apiKey":   {},
-		"auth":     {},
+// RegisterReflection mounts the gRPC server reflection handlers (v1 and v1alpha) for the given
+// fully-qualified service names, so tools like grpcurl can discover the API. Reflection exposes the
+// whole schema, so only enable it outside production:
+//
+//	if !cfg.IsProduction() {
+//	    middleware.RegisterReflection(mux, "orders.v1.OrderService")
+//	}
+//
+// It does nothing when mux is nil or no services are given.
+func (middleware *grpcAuthMiddleware) RegisterReflection(mux *http.ServeMux, services ...string) {
+	if mux == nil || len(services) == 0 {
+		return
+	}
+
+	reflector := grpcreflect.NewStaticReflector(services...)
+	mux.Handle(grpcreflect.NewHandlerV1(reflector))
+	mux.Handle(grpcreflect.NewHandlerV1Alpha(reflector))
+}
+
+// RedactionStrategy controls how sanitize hides the value of a sensitive string field
+type RedactionStrategy int
+
+const (
+	// RedactFull replaces the value with "[REDACTED]", revealing neither value nor length
+	RedactFull RedactionStrategy = iota
+	// RedactMasked replaces every character with "*", preserving the length
+	RedactMasked
+	// RedactPartial keeps the last 4 characters visible behind "****" (e.g. "****1234"); values of
+	// 4 characters or less are fully masked
+	RedactPartial
+)
+
+// partialVisibleChars is the number of trailing characters kept visible by RedactPartial
+const partialVisibleChars = 4
+
+// defaultSensitiveFields are always redacted, with the strategy of WithRedactionStrategy
+var defaultSensitiveFields = []string{"password", "token", "secret", "apikey", "auth"}
+
+// WithRedactionStrategy sets how sensitive fields are redacted in logs (default RedactFull)
+func WithRedactionStrategy(strategy RedactionStrategy) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.redaction = strategy
+	}
+}
+
+// WithFieldRedaction marks a field as sensitive, matching its Go name case-insensitively, and
+// redacts it with the given strategy, overriding WithRedactionStrategy for that field
+//
+// Example Usage:
+//
+//	middleware := NewMiddleware(authenticator, logger, helper,
+//	    WithFieldRedaction("CardNumber", RedactPartial),
+//	    WithFieldRedaction("Phone", RedactMasked))
+func WithFieldRedaction(field string, strategy RedactionStrategy) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		if middleware.fieldRedaction == nil {
+			middleware.fieldRedaction = map[string]RedactionStrategy{}
+		}
+		middleware.fieldRedaction[strings.ToLower(field)] = strategy
+	}
+}
+
+// sanitizeMessage masks sensitive fields in request and response structs
+func (middleware *grpcAuthMiddleware) sanitizeMessage(req interface{}) interface{} {
+	sensitiveFields := make(map[string]RedactionStrategy, len(defaultSensitiveFields)+len(middleware.fieldRedaction))
+	for _, field := range defaultSensitiveFields {
+		sensitiveFields[field] = middleware.redaction
+	}
+	for field, strategy := range middleware.fieldRedaction {
+		sensitiveFields[field] = strategy
 	}
 	return sanitize(req, sensitiveFields)
 }
 
-func sanitize(v interface{}, sensitiveFields map[string]struct{}) interface{} {
+// redact hides a sensitive string value according to the strategy
+func redact(value string, strategy RedactionStrategy) string {
+	switch strategy {
+	case RedactMasked:
+		return strings.Repeat("*", utf8.RuneCountInString(value))
+	case RedactPartial:
+		runes := []rune(value)
+		if len(runes) <= partialVisibleChars {
+			return strings.Repeat("*", len(runes))
+		}
+		return "****" + string(runes[len(runes)-partialVisibleChars:])
+	default:
+		return "[REDACTED]"
+	}
+}
+
+func sanitize(v interface{}, sensitiveFields map[string]RedactionStrategy) interface{} {
 	if v == nil {
 		return nil
 	}
@@ -159,9 +993,9 @@ func sanitize(v interface{}, sensitiveFields map[string]struct{}) interface{} {
 			continue
 		}
 
-		if _, isSensitive := sensitiveFields[fieldName]; isSensitive {
+		if strategy, isSensitive := sensitiveFields[fieldName]; isSensitive {
 			if field.Type.Kind() == reflect.String {
-				copied.Field(i).SetString("[REDACTED]")
+				copied.Field(i).SetString(redact(value.String(), strategy))
 			} else {
 				copied.Field(i).Set(reflect.Zero(field.Type))
 			}
@@ -176,10 +1010,23 @@ func sanitize(v interface{}, sensitiveFields map[string]struct{}) interface{} {
 }
 
 // NewMiddleware  returns a new instance of grpcAuthMiddleware
-func NewMiddleware(authenticator Authenticator, logger *zap.Logger, contextHelper ContextHelper) Middleware {
-	return &grpcAuthMiddleware{
+func NewMiddleware(authenticator Authenticator, logger *zap.Logger, contextHelper ContextHelper, opts ...MiddlewareOption) Middleware {
+	middleware := &grpcAuthMiddleware{
 		loggR:         logger,
 		authenticator: authenticator,
 		contextHelper: contextHelper,
+		tenantClaim: func(claims *UserAuthClaims) []string {
+			return claims.Organization
+		},
+		claimMapper:         defaultClaimMapper,
+		claimsKey:           ContextKeyUser,
+		headerSkip:          []string{HealthCheckProcedure},
+		tenantSources:       []TenantSource{TenantFromHeader},
+		featureDisabledCode: connect.CodePermissionDenied,
+		clock:               systemClock{},
+	}
+	for _, opt := range opts {
+		opt(middleware)
 	}
+	return middleware
 }
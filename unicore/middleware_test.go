@@ -0,0 +1,784 @@
+package unicore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// gatewayMetadataInterceptor copies the Authorization header into incoming gRPC metadata, like a
+// gRPC gateway in front of the service does
+func gatewayMetadataInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			md := metadata.Pairs("authorization", req.Header().Get("Authorization"))
+			return next(metadata.NewIncomingContext(ctx, md), req)
+		}
+	}
+}
+
+func TestUnaryTokenInterceptorFromContextMatchesHeaderPath(t *testing.T) {
+	middleware := newTestMiddleware()
+	token := newTestToken(t, map[string]any{"sub": "alice", "email": "alice@example.com"})
+
+	call := func(interceptors ...connect.Interceptor) (*UserAuthClaims, string) {
+		var claims *UserAuthClaims
+		var accessToken string
+		client := newTestClient(t, func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			claims = middleware.contextHelper.GetUserClaims(ctx)
+			accessToken, _ = ctx.Value(ContextKeyAccessToken).(string)
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		}, interceptors...)
+
+		req := connect.NewRequest(&emptypb.Empty{})
+		req.Header().Set("Authorization", "Bearer "+token)
+		if _, err := client.CallUnary(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		return claims, accessToken
+	}
+
+	headerClaims, headerToken := call(middleware.UnaryTokenInterceptor())
+	contextClaims, contextToken := call(gatewayMetadataInterceptor(), middleware.UnaryTokenInterceptorFromContext())
+
+	if headerClaims == nil || contextClaims == nil {
+		t.Fatalf("claims missing from context: header %v, metadata %v", headerClaims, contextClaims)
+	}
+	if headerClaims.Id != "alice" || contextClaims.Id != headerClaims.Id || contextClaims.Email != headerClaims.Email {
+		t.Fatalf("claims differ: header %+v, metadata %+v", headerClaims, contextClaims)
+	}
+	if headerToken != token || contextToken != token {
+		t.Fatalf("access token not stored: header %q, metadata %q", headerToken, contextToken)
+	}
+}
+
+func TestUnaryTokenInterceptorFromContextRequiresMetadata(t *testing.T) {
+	middleware := newTestMiddleware()
+	called := false
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		called = true
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.UnaryTokenInterceptorFromContext())
+
+	// The header alone is not read by the context-based interceptor.
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer "+newTestToken(t, nil))
+	_, err := client.CallUnary(context.Background(), req)
+	assertCode(t, err, connect.CodeUnauthenticated)
+	if called {
+		t.Fatal("handler ran without a token in the metadata")
+	}
+}
+
+func TestUnaryTokenInterceptorFromContextSkipsPublicRoutes(t *testing.T) {
+	middleware := newTestMiddleware()
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.UnaryTokenInterceptorFromContext(testProcedure))
+
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnaryTimezoneInterceptor(t *testing.T) {
+	middleware := newTestMiddleware()
+	tests := []struct {
+		name       string
+		header     string
+		claims     map[string]any
+		wantZone   string
+		wantLocale string
+	}{
+		{name: "valid header", header: "Europe/Berlin", wantZone: "Europe/Berlin"},
+		{name: "invalid header", header: "Not/A_Zone", wantZone: "UTC"},
+		{name: "missing", wantZone: "UTC"},
+		{name: "claim", claims: map[string]any{"zoneinfo": "America/New_York", "locale": "en-US"}, wantZone: "America/New_York", wantLocale: "en-US"},
+		{name: "header over claim", header: "Asia/Tokyo", claims: map[string]any{"zoneinfo": "America/New_York"}, wantZone: "Asia/Tokyo"},
+		{name: "invalid claim", claims: map[string]any{"zoneinfo": "Mars/Olympus"}, wantZone: "UTC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var zone, locale string
+			client := newTestClient(t, func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				zone = middleware.contextHelper.GetTimezone(ctx).String()
+				locale = middleware.contextHelper.GetLocale(ctx)
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			}, middleware.UnaryTokenInterceptor(), middleware.UnaryTimezoneInterceptor())
+
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("Authorization", "Bearer "+newTestToken(t, tt.claims))
+			if tt.header != "" {
+				req.Header().Set(XTimezoneKey, tt.header)
+			}
+			if _, err := client.CallUnary(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+
+			if zone != tt.wantZone || locale != tt.wantLocale {
+				t.Fatalf("got zone %q locale %q, want %q %q", zone, locale, tt.wantZone, tt.wantLocale)
+			}
+		})
+	}
+}
+
+func TestGetTimezoneDefaultsToUTC(t *testing.T) {
+	if location := NewContextHelper(nil).GetTimezone(context.Background()); location != time.UTC {
+		t.Fatalf("expected UTC without the interceptor, got %v", location)
+	}
+}
+
+func TestUnaryTokenInterceptorRequiresSubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		wantCode connect.Code
+	}{
+		{name: "present", claims: map[string]any{"sub": "alice"}},
+		{name: "missing", claims: map[string]any{"sub": nil}, wantCode: connect.CodeUnauthenticated},
+		{name: "empty", claims: map[string]any{"sub": ""}, wantCode: connect.CodeUnauthenticated},
+	}
+
+	middleware := newTestMiddleware()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			client := newTestClient(t, func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				subject = middleware.contextHelper.GetUserClaims(ctx).Id
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			}, middleware.UnaryTokenInterceptor())
+
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("Authorization", "Bearer "+newTestToken(t, tt.claims))
+			_, err := client.CallUnary(context.Background(), req)
+			if tt.wantCode != 0 {
+				assertCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subject != "alice" {
+				t.Fatalf("expected subject alice in context, got %q", subject)
+			}
+		})
+	}
+}
+
+func TestMaxPageInterceptor(t *testing.T) {
+	middleware := newTestMiddleware()
+	client := newTestClient(t, func(context.Context, *connect.Request[commonv1.PageRequest]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.MaxPageInterceptor(1000))
+
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&commonv1.PageRequest{Page: 1000})); err != nil {
+		t.Fatalf("page at the maximum rejected: %v", err)
+	}
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&commonv1.PageRequest{Page: 1001}))
+	assertCode(t, err, connect.CodeInvalidArgument)
+}
+
+func TestSignedTenantInterceptor(t *testing.T) {
+	secret := []byte("gateway-secret")
+	tests := []struct {
+		name      string
+		tenant    string
+		signature string
+		wantCode  connect.Code
+	}{
+		{name: "valid", tenant: "acme", signature: SignTenant(secret, "acme")},
+		{name: "missing signature", tenant: "acme", wantCode: connect.CodePermissionDenied},
+		{name: "tampered tenant", tenant: "other", signature: SignTenant(secret, "acme"), wantCode: connect.CodePermissionDenied},
+		{name: "other secret", tenant: "acme", signature: SignTenant([]byte("guess"), "acme"), wantCode: connect.CodePermissionDenied},
+		{name: "not hex", tenant: "acme", signature: "zz", wantCode: connect.CodePermissionDenied},
+		{name: "missing tenant", signature: SignTenant(secret, ""), wantCode: connect.CodeInvalidArgument},
+	}
+
+	middleware := newTestMiddleware()
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.SignedTenantInterceptor(secret))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := connect.NewRequest(&emptypb.Empty{})
+			if tt.tenant != "" {
+				req.Header().Set(XTenantKey, tt.tenant)
+			}
+			if tt.signature != "" {
+				req.Header().Set(XTenantSignatureKey, tt.signature)
+			}
+
+			_, err := client.CallUnary(context.Background(), req)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			assertCode(t, err, tt.wantCode)
+		})
+	}
+}
+
+func TestTenantConsistencyInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		tenant   string
+		claims   map[string]any
+		wantCode connect.Code
+	}{
+		{name: "matching", tenant: "acme", claims: map[string]any{"organization": []string{"other", "acme"}}},
+		{name: "mismatching", tenant: "victim", claims: map[string]any{"organization": []string{"acme"}}, wantCode: connect.CodePermissionDenied},
+		{name: "no organization", tenant: "acme", wantCode: connect.CodePermissionDenied},
+		{name: "service account", tenant: "victim", claims: map[string]any{"azp": "billing-service"}},
+	}
+
+	middleware := newTestMiddleware()
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.UnaryTokenInterceptor(), middleware.UnaryTenantInterceptor(), middleware.TenantConsistencyInterceptor("billing-service"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("Authorization", "Bearer "+newTestToken(t, tt.claims))
+			req.Header().Set(XTenantKey, tt.tenant)
+
+			_, err := client.CallUnary(context.Background(), req)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			assertCode(t, err, tt.wantCode)
+		})
+	}
+}
+
+func TestTenantConsistencyInterceptorChecksResolvedTenant(t *testing.T) {
+	// The tenant resolved into the context wins over the header, e.g. when it came from the host.
+	middleware := newTestMiddleware()
+	resolveTenant := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return next(withTenant(ctx, "victim"), req)
+		}
+	})
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.UnaryTokenInterceptor(), resolveTenant, middleware.TenantConsistencyInterceptor())
+
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer "+newTestToken(t, map[string]any{"organization": []string{"acme"}}))
+	req.Header().Set(XTenantKey, "acme")
+	_, err := client.CallUnary(context.Background(), req)
+	assertCode(t, err, connect.CodePermissionDenied)
+}
+
+func TestTimeBudgetInterceptor(t *testing.T) {
+	middleware := newTestMiddleware()
+	var remaining time.Duration
+	client := newTestClient(t, func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil, connect.NewError(connect.CodeInternal, errors.New("no deadline"))
+		}
+		remaining = time.Until(deadline)
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.TimeBudgetInterceptor())
+
+	call := func(budget string) error {
+		req := connect.NewRequest(&emptypb.Empty{})
+		req.Header().Set(XTotalBudgetKey, budget)
+		_, err := client.CallUnary(context.Background(), req)
+		return err
+	}
+
+	if err := call("250"); err != nil {
+		t.Fatal(err)
+	}
+	if remaining <= 0 || remaining > 250*time.Millisecond {
+		t.Fatalf("expected the handler deadline within the 250ms budget, got %v", remaining)
+	}
+	assertCode(t, call("0"), connect.CodeDeadlineExceeded)
+	assertCode(t, call("-5"), connect.CodeDeadlineExceeded)
+	assertCode(t, call("soon"), connect.CodeInvalidArgument)
+}
+
+func TestTimeBudgetAcrossAttempts(t *testing.T) {
+	middleware := newTestMiddleware()
+	var mu sync.Mutex
+	var budgets []int64
+	server := newTestServer(t, func(ctx context.Context, req *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		budget, _ := strconv.ParseInt(req.Header().Get(XTotalBudgetKey), 10, 64)
+		mu.Lock()
+		budgets = append(budgets, budget)
+		mu.Unlock()
+		// Every attempt consumes part of the budget before failing.
+		time.Sleep(60 * time.Millisecond)
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("try again"))
+	}, middleware.TimeBudgetInterceptor())
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+testProcedure,
+		connect.WithInterceptors(TimeBudgetClientInterceptor()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		if _, err = client.CallUnary(ctx, connect.NewRequest(&emptypb.Empty{})); connect.CodeOf(err) == connect.CodeDeadlineExceeded {
+			break
+		}
+	}
+
+	assertCode(t, err, connect.CodeDeadlineExceeded)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(budgets) < 2 || len(budgets) > 3 {
+		t.Fatalf("expected the 150ms budget to allow 2 or 3 attempts of 60ms, got %d", len(budgets))
+	}
+	for i := 1; i < len(budgets); i++ {
+		if budgets[i] >= budgets[i-1] {
+			t.Fatalf("expected each attempt to carry less budget, got %v", budgets)
+		}
+	}
+}
+
+type loginResponse struct {
+	UserID string
+	Token  string
+}
+
+func TestLoggingUnaryInterceptorRedactsResponse(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	authenticator := newTestAuthenticator()
+	middleware := NewMiddleware(authenticator, zap.New(core), NewContextHelper(authenticator))
+
+	handler := middleware.LoggingUnaryInterceptor()(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&loginResponse{UserID: "alice", Token: "secret-api-key"}), nil
+	})
+	if _, err := handler(context.Background(), connect.NewRequest(&emptypb.Empty{})); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.FilterMessage("gRPC request completed").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected one completion entry, got %d", len(entries))
+	}
+	logged, err := json.Marshal(entries[0].ContextMap()["response"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(logged), "secret-api-key") {
+		t.Fatalf("response token leaked into the log: %s", logged)
+	}
+	if !strings.Contains(string(logged), "[REDACTED]") || !strings.Contains(string(logged), "alice") {
+		t.Fatalf("expected the redacted response with its other fields, got %s", logged)
+	}
+}
+
+func TestRequireVerifiedEmailInterceptor(t *testing.T) {
+	const resendProcedure = "/test.v1.TestService/ResendVerification"
+	tests := []struct {
+		name     string
+		verified bool
+		allowed  []string
+		config   RouteConfig
+		wantCode connect.Code
+	}{
+		{name: "verified", verified: true},
+		{name: "unverified", wantCode: connect.CodePermissionDenied},
+		{name: "unverified on an exempt route", allowed: []string{testProcedure}},
+		{name: "unverified on a configured route", config: RouteConfig{UnverifiedEmailAllowed: []string{testProcedure}}},
+		{name: "unverified with another exempt route", allowed: []string{resendProcedure}, wantCode: connect.CodePermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := newTestMiddleware(WithRouteConfig(tt.config))
+			client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			}, middleware.UnaryTokenInterceptor(), middleware.RequireVerifiedEmailInterceptor(tt.allowed...))
+
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("Authorization", "Bearer "+newTestToken(t, map[string]any{"email_verified": tt.verified}))
+			_, err := client.CallUnary(context.Background(), req)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			assertCode(t, err, tt.wantCode)
+			var connectErr *connect.Error
+			if !errors.As(err, &connectErr) || connectErr.Message() != ErrEmailNotVerified.Message() {
+				t.Fatalf("expected the email verification message, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnaryTenantInterceptorSources(t *testing.T) {
+	single := []string{"acme"}
+	several := []string{"acme", "other"}
+	tests := []struct {
+		name     string
+		sources  []TenantSource
+		header   string
+		claimed  []string
+		want     string
+		wantCode connect.Code
+	}{
+		{name: "default header", header: "acme", want: "acme"},
+		{name: "default ignores claim", claimed: single, wantCode: connect.CodeInvalidArgument},
+		{name: "default ignores mismatch", header: "victim", claimed: single, want: "victim"},
+		{name: "header first header only", sources: []TenantSource{TenantFromHeader, TenantFromClaim}, header: "acme", want: "acme"},
+		{name: "header first claim only", sources: []TenantSource{TenantFromHeader, TenantFromClaim}, claimed: single, want: "acme"},
+		{name: "header first both", sources: []TenantSource{TenantFromHeader, TenantFromClaim}, header: "other", claimed: several, want: "other"},
+		{name: "header first conflict", sources: []TenantSource{TenantFromHeader, TenantFromClaim}, header: "victim", claimed: single, wantCode: connect.CodePermissionDenied},
+		{name: "claim first header only", sources: []TenantSource{TenantFromClaim, TenantFromHeader}, header: "acme", want: "acme"},
+		{name: "claim first claim only", sources: []TenantSource{TenantFromClaim, TenantFromHeader}, claimed: single, want: "acme"},
+		{name: "claim first both", sources: []TenantSource{TenantFromClaim, TenantFromHeader}, header: "acme", claimed: single, want: "acme"},
+		{name: "claim first conflict", sources: []TenantSource{TenantFromClaim, TenantFromHeader}, header: "victim", claimed: several, wantCode: connect.CodePermissionDenied},
+		{name: "ambiguous claim", sources: []TenantSource{TenantFromClaim, TenantFromHeader}, claimed: several, wantCode: connect.CodeInvalidArgument},
+		{name: "claim only", sources: []TenantSource{TenantFromClaim}, header: "acme", wantCode: connect.CodeInvalidArgument},
+		{name: "neither", sources: []TenantSource{TenantFromHeader, TenantFromClaim}, wantCode: connect.CodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []MiddlewareOption
+			if tt.sources != nil {
+				opts = append(opts, WithTenantSources(tt.sources...))
+			}
+			middleware := newTestMiddleware(opts...)
+
+			var tenantID string
+			client := newTestClient(t, func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				tenantID = tenantFromContext(ctx)
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			}, middleware.UnaryTokenInterceptor(), middleware.UnaryTenantInterceptor())
+
+			claims := map[string]any{}
+			if tt.claimed != nil {
+				claims["organization"] = tt.claimed
+			}
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("Authorization", "Bearer "+newTestToken(t, claims))
+			if tt.header != "" {
+				req.Header().Set(XTenantKey, tt.header)
+			}
+
+			_, err := client.CallUnary(context.Background(), req)
+			if tt.wantCode != 0 {
+				assertCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tenantID != tt.want {
+				t.Fatalf("expected tenant %q, got %q", tt.want, tenantID)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		strategy RedactionStrategy
+		value    string
+		want     string
+	}{
+		{strategy: RedactFull, value: "4111111111111111", want: "[REDACTED]"},
+		{strategy: RedactFull, value: "", want: "[REDACTED]"},
+		{strategy: RedactMasked, value: "hunter2", want: "*******"},
+		{strategy: RedactMasked, value: "пароль", want: "******"},
+		{strategy: RedactPartial, value: "4111111111111234", want: "****1234"},
+		{strategy: RedactPartial, value: "+4917612345678", want: "****5678"},
+		{strategy: RedactPartial, value: "1234", want: "****"},
+		{strategy: RedactPartial, value: "12", want: "**"},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.value, tt.strategy); got != tt.want {
+			t.Errorf("redact(%q, %d) = %q, want %q", tt.value, tt.strategy, got, tt.want)
+		}
+	}
+}
+
+type paymentRequest struct {
+	CardNumber string
+	Phone      string
+	Password   string
+	Amount     int64
+}
+
+func TestSanitizeMessageRedactionStrategies(t *testing.T) {
+	req := &paymentRequest{CardNumber: "4111111111111234", Phone: "+4917612345678", Password: "hunter2", Amount: 4200}
+
+	tests := []struct {
+		name string
+		opts []MiddlewareOption
+		want paymentRequest
+	}{
+		{
+			name: "default",
+			want: paymentRequest{CardNumber: "4111111111111234", Phone: "+4917612345678", Password: "[REDACTED]", Amount: 4200},
+		},
+		{
+			name: "global strategy",
+			opts: []MiddlewareOption{WithRedactionStrategy(RedactMasked)},
+			want: paymentRequest{CardNumber: "4111111111111234", Phone: "+4917612345678", Password: "*******", Amount: 4200},
+		},
+		{
+			name: "per field",
+			opts: []MiddlewareOption{
+				WithFieldRedaction("cardnumber", RedactPartial),
+				WithFieldRedaction("Phone", RedactMasked),
+			},
+			want: paymentRequest{CardNumber: "****1234", Phone: "**************", Password: "[REDACTED]", Amount: 4200},
+		},
+		{
+			name: "per field overrides global",
+			opts: []MiddlewareOption{
+				WithRedactionStrategy(RedactMasked),
+				WithFieldRedaction("Password", RedactFull),
+				WithFieldRedaction("CardNumber", RedactPartial),
+			},
+			want: paymentRequest{CardNumber: "****1234", Phone: "+4917612345678", Password: "[REDACTED]", Amount: 4200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := newTestMiddleware(tt.opts...).sanitizeMessage(req).(*paymentRequest)
+			if !ok {
+				t.Fatal("expected a sanitized *paymentRequest")
+			}
+			if *got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+	if req.Password != "hunter2" {
+		t.Fatal("expected the original message to be left untouched")
+	}
+}
+
+func TestRequestIDUnaryInterceptorDoesNotMutateSharedErrors(t *testing.T) {
+	middleware := newTestMiddleware()
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return nil, ErrMissingTenantHeader
+	}, middleware.RequestIDUnaryInterceptor())
+
+	const callers = 8
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requestID := "req-" + strconv.Itoa(i)
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set(XRequestIDKey, requestID)
+
+			_, err := client.CallUnary(context.Background(), req)
+			var connectErr *connect.Error
+			if !errors.As(err, &connectErr) {
+				t.Errorf("expected a connect error, got %v", err)
+				return
+			}
+			if got := connectErr.Meta().Get(XRequestIDKey); got != requestID {
+				t.Errorf("expected request id %s on the error, got %q", requestID, got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := ErrMissingTenantHeader.Meta().Get(XRequestIDKey); got != "" {
+		t.Fatalf("expected the shared error to be left untouched, got request id %q", got)
+	}
+}
+
+func TestRequestIDUnaryInterceptorValidatesClientIDs(t *testing.T) {
+	middleware := newTestMiddleware()
+	var stored string
+	client := newTestClient(t, func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		stored, _ = ctx.Value(ContextKeyRequestID).(string)
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.RequestIDUnaryInterceptor())
+
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{name: "uuid", header: "0b8e4a4e-3f0a-4c55-9d1e-2f7f5a1c9b10", wantKept: true},
+		{name: "trace id", header: "gateway:1234_abc.5", wantKept: true},
+		{name: "missing", header: ""},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "log injection", header: "abc\" level=error msg=forged"},
+		{name: "html", header: "<script>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := connect.NewRequest(&emptypb.Empty{})
+			if tt.header != "" {
+				req.Header().Set(XRequestIDKey, tt.header)
+			}
+			resp, err := client.CallUnary(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			echoed := resp.Header().Get(XRequestIDKey)
+			if echoed != stored {
+				t.Fatalf("expected the stored id %q to be echoed, got %q", stored, echoed)
+			}
+			if kept := stored == tt.header; kept != tt.wantKept {
+				t.Fatalf("expected the client id to be kept: %v, stored %q", tt.wantKept, stored)
+			}
+			if !isValidRequestID(stored) {
+				t.Fatalf("expected a valid request id, got %q", stored)
+			}
+		})
+	}
+}
+
+func TestDeprecationInterceptor(t *testing.T) {
+	const otherProcedure = "/test.v1.TestService/Other"
+	tests := []struct {
+		name        string
+		deprecated  map[string]string
+		fail        bool
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "sunset date",
+			deprecated:  map[string]string{testProcedure: "2027-01-31"},
+			wantHeaders: map[string]string{"Deprecation": "true", "Sunset": "Sun, 31 Jan 2027 00:00:00 GMT"},
+		},
+		{
+			name:        "message",
+			deprecated:  map[string]string{testProcedure: "use v2"},
+			wantHeaders: map[string]string{"Deprecation": "true", "Warning": `299 - "use v2"`},
+		},
+		{
+			name:        "error",
+			deprecated:  map[string]string{testProcedure: "2027-01-31T12:00:00+01:00"},
+			fail:        true,
+			wantHeaders: map[string]string{"Deprecation": "true", "Sunset": "Sun, 31 Jan 2027 11:00:00 GMT"},
+		},
+		{
+			name:        "not deprecated",
+			deprecated:  map[string]string{otherProcedure: "use v2"},
+			wantHeaders: map[string]string{"Deprecation": "", "Sunset": "", "Warning": ""},
+		},
+		{
+			name:        "not deprecated error",
+			deprecated:  map[string]string{otherProcedure: "use v2"},
+			fail:        true,
+			wantHeaders: map[string]string{"Deprecation": "", "Sunset": "", "Warning": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				if tt.fail {
+					return nil, ErrTenantMismatch
+				}
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			}, newTestMiddleware().DeprecationInterceptor(tt.deprecated))
+
+			resp, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+			var header http.Header
+			if tt.fail {
+				var connectErr *connect.Error
+				if !errors.As(err, &connectErr) {
+					t.Fatalf("expected a connect error, got %v", err)
+				}
+				header = connectErr.Meta()
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				header = resp.Header()
+			}
+
+			for key, want := range tt.wantHeaders {
+				if got := header.Get(key); got != want {
+					t.Errorf("expected %s header %q, got %q", key, want, got)
+				}
+			}
+		})
+	}
+
+	if got := ErrTenantMismatch.Meta().Get("Deprecation"); got != "" {
+		t.Fatalf("expected the shared error to be left untouched, got Deprecation %q", got)
+	}
+}
+
+func TestResponseHeaderFilterInterceptor(t *testing.T) {
+	shared := connect.NewError(connect.CodeUnavailable, errors.New("upstream unavailable"))
+	shared.Meta().Set("X-Upstream-Token", "secret")
+	shared.Meta().Set("Retry-After", "5")
+
+	client := newTestClient(t, func(_ context.Context, req *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		if req.Header().Get("X-Fail") != "" {
+			return nil, shared
+		}
+		resp := connect.NewResponse(&emptypb.Empty{})
+		resp.Header().Set("X-Upstream-Token", "secret")
+		resp.Header().Set("X-Served", "true")
+		return resp, nil
+	}, newTestMiddleware().ResponseHeaderFilterInterceptor([]string{"x-upstream-token"}))
+
+	resp, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header().Get("X-Upstream-Token") != "" || resp.Header().Get("X-Served") != "true" {
+		t.Fatalf("expected only the blocklisted header to be removed, got %v", resp.Header())
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("X-Fail", "true")
+			_, err := client.CallUnary(context.Background(), req)
+			var connectErr *connect.Error
+			if !errors.As(err, &connectErr) {
+				t.Errorf("expected a connect error, got %v", err)
+				return
+			}
+			if connectErr.Meta().Get("X-Upstream-Token") != "" || connectErr.Meta().Get("Retry-After") != "5" {
+				t.Errorf("expected only the blocklisted header to be removed, got %v", connectErr.Meta())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if shared.Meta().Get("X-Upstream-Token") != "secret" {
+		t.Fatal("expected the shared error to be left untouched")
+	}
+}
@@ -0,0 +1,91 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrSchemaDrift is returned by Migrate when the database does not match the models outside
+// development and testing
+var ErrSchemaDrift = errors.New("database schema does not match the models")
+
+type gormMigrator struct {
+	db     *gorm.DB
+	cfg    Config
+	models []any
+}
+
+// Migrate auto-migrates the models in development and testing. In every other environment it only
+// verifies that each model's table and columns exist, returning an error describing the drift.
+func (migrator *gormMigrator) Migrate(ctx context.Context) error {
+	db := migrator.db.WithContext(ctx)
+	logger := migrator.cfg.Logger().With(zap.String("environment", migrator.cfg.GetEnvironment()))
+
+	if migrator.cfg.IsDevelopment() || migrator.cfg.IsTesting() {
+		for _, model := range migrator.models {
+			if err := db.AutoMigrate(model); err != nil {
+				return fmt.Errorf("failed to migrate %T: %w", model, err)
+			}
+			logger.Info("applied migration", zap.String("model", fmt.Sprintf("%T", model)))
+		}
+		return nil
+	}
+
+	var drift []string
+	for _, model := range migrator.models {
+		modelDrift, err := schemaDrift(db, model)
+		if err != nil {
+			return err
+		}
+		drift = append(drift, modelDrift...)
+	}
+
+	if len(drift) > 0 {
+		logger.Error("database schema drift detected", zap.Strings("drift", drift))
+		return fmt.Errorf("%w: %s", ErrSchemaDrift, strings.Join(drift, "; "))
+	}
+
+	logger.Info("database schema verified", zap.Int("models", len(migrator.models)))
+	return nil
+}
+
+// schemaDrift lists the table and columns of model missing from the database
+func schemaDrift(db *gorm.DB, model any) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+
+	if !db.Migrator().HasTable(model) {
+		return []string{fmt.Sprintf("missing table %s", stmt.Schema.Table)}, nil
+	}
+
+	var drift []string
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || field.IgnoreMigration {
+			continue
+		}
+		if !db.Migrator().HasColumn(model, field.DBName) {
+			drift = append(drift, fmt.Sprintf("missing column %s.%s", stmt.Schema.Table, field.DBName))
+		}
+	}
+	return drift, nil
+}
+
+// NewMigrator returns a Migrator for the models. Only development and testing environments are
+// ever altered, so a production deployment with pending schema changes fails at startup instead of
+// running AutoMigrate; apply those changes with reviewed migrations first.
+//
+// Example Usage:
+//
+//	if err := NewMigrator(db, cfg, &Product{}, &Order{}).Migrate(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+func NewMigrator(db *gorm.DB, cfg Config, models ...any) Migrator {
+	return &gormMigrator{db: db, cfg: cfg, models: models}
+}
@@ -0,0 +1,103 @@
+package unicore
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"slices"
+
+	"connectrpc.com/connect"
+)
+
+const (
+	// ContextKeyPeerCertificate is used to store the verified client certificate in context.
+	ContextKeyPeerCertificate = "PeerCertificateKey"
+	// ContextKeyServicePrincipal is used to store the service identity authenticated through mTLS.
+	ContextKeyServicePrincipal = "ServicePrincipalKey"
+)
+
+// PeerCertificateHandler stores the verified client certificate of the TLS connection in the
+// request context. Connect requests only expose the peer address (req.Peer()), not its TLS state,
+// so wrap the handler mux with it for MTLSInterceptor to see the certificate. Certificates are
+// only stored when the server verified them, i.e. tls.Config.ClientAuth is VerifyClientCertIfGiven
+// or RequireAndVerifyClientCert with ClientCAs set.
+//
+// Example Usage:
+//
+//	server := &http.Server{Handler: PeerCertificateHandler(mux), TLSConfig: tlsConfig}
+func PeerCertificateHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), ContextKeyPeerCertificate, r.TLS.VerifiedChains[0][0]))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// PeerCertificateFromContext returns the verified client certificate stored by
+// PeerCertificateHandler
+func PeerCertificateFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(ContextKeyPeerCertificate).(*x509.Certificate)
+	return cert, ok && cert != nil
+}
+
+// ServicePrincipalFromContext returns the service identity authenticated by MTLSInterceptor
+func ServicePrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(ContextKeyServicePrincipal).(string)
+	return principal, ok && principal != ""
+}
+
+// serviceIdentity derives the service name from a certificate: the first URI SAN (e.g. a SPIFFE
+// id), then the first DNS SAN, then the subject common name
+func serviceIdentity(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// MTLSInterceptor authenticates internal callers by their client certificate on the given
+// procedures. When PeerCertificateHandler stored a verified certificate, the service identity
+// derived from it is stored as the service principal together with synthetic claims (sub is the
+// identity, typ is "mTLS") so GetUserClaims keeps working, and the token interceptors skip token
+// verification. Calls without a certificate, or to other procedures, fall back to token
+// authentication. Register it before UnaryTokenInterceptor.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(
+//	    middleware.MTLSInterceptor("/billing.v1.BillingService/Charge"),
+//	    middleware.UnaryTokenInterceptor(publicRoutes...),
+//	)
+func (middleware *grpcAuthMiddleware) MTLSInterceptor(procedures ...string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if !slices.Contains(procedures, req.Spec().Procedure) {
+				return next(ctx, req)
+			}
+
+			cert, ok := PeerCertificateFromContext(ctx)
+			if !ok {
+				return next(ctx, req)
+			}
+
+			identity := serviceIdentity(cert)
+			if identity == "" {
+				return next(ctx, req)
+			}
+
+			claims := &UserAuthClaims{
+				Id:                identity,
+				Typ:               "mTLS",
+				PreferredUsername: identity,
+				Exp:               cert.NotAfter.Unix(),
+			}
+			ctx = context.WithValue(ctx, ContextKeyServicePrincipal, identity)
+			ctx = context.WithValue(ctx, middleware.claimsKey, claims)
+			return next(ctx, req)
+		}
+	}
+}
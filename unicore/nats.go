@@ -0,0 +1,91 @@
+package unicore
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+type jetStreamPublisher struct {
+	js            jetstream.JetStream
+	requireTenant bool
+}
+
+// PublisherOption customizes the publisher returned by NewPublisher
+type PublisherOption func(*jetStreamPublisher)
+
+// WithRequireTenant makes Publish fail with ErrMissingTenant when the context carries no tenant,
+// instead of publishing the message without a tenant header.
+func WithRequireTenant(required bool) PublisherOption {
+	return func(publisher *jetStreamPublisher) {
+		publisher.requireTenant = required
+	}
+}
+
+// Publish sends data to the subject, propagating the tenant of ctx in the x-tenant-id header
+func (publisher *jetStreamPublisher) Publish(ctx context.Context, subject string, data []byte) error {
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" && publisher.requireTenant {
+		return ErrMissingTenant
+	}
+	if tenantID != "" {
+		msg.Header.Set(XTenantKey, tenantID)
+	}
+
+	_, err := publisher.js.PublishMsg(ctx, msg)
+	return err
+}
+
+// NewPublisher returns a Publisher backed by JetStream
+func NewPublisher(js jetstream.JetStream, opts ...PublisherOption) Publisher {
+	publisher := &jetStreamPublisher{js: js}
+	for _, opt := range opts {
+		opt(publisher)
+	}
+	return publisher
+}
+
+type jetStreamConsumer struct {
+	loggR    *zap.Logger
+	consumer jetstream.Consumer
+}
+
+// Consume delivers messages to handler with the tenant from the x-tenant-id header stored in the
+// context, so WithTenantScope and GetTenant work as they do for RPCs. Messages are acked when the
+// handler succeeds and nacked for redelivery otherwise.
+func (consumer *jetStreamConsumer) Consume(ctx context.Context, handler MessageHandler) (jetstream.ConsumeContext, error) {
+	return consumer.consumer.Consume(func(msg jetstream.Msg) {
+		msgCtx := ctx
+		if tenantID := msg.Headers().Get(XTenantKey); tenantID != "" {
+			msgCtx = context.WithValue(ctx, XTenantKey, tenantID)
+		}
+
+		if err := handler(msgCtx, msg); err != nil {
+			consumer.loggR.Error("message handler failed",
+				zap.String("subject", msg.Subject()),
+				zap.Error(err),
+			)
+			if nakErr := msg.Nak(); nakErr != nil {
+				consumer.loggR.Error("failed to nak message", zap.String("subject", msg.Subject()), zap.Error(nakErr))
+			}
+			return
+		}
+
+		if err := msg.Ack(); err != nil {
+			consumer.loggR.Error("failed to ack message", zap.String("subject", msg.Subject()), zap.Error(err))
+		}
+	})
+}
+
+// NewConsumer returns a Consumer wrapping a JetStream consumer
+func NewConsumer(consumer jetstream.Consumer, logger *zap.Logger) Consumer {
+	return &jetStreamConsumer{
+		loggR:    logger,
+		consumer: consumer,
+	}
+}
@@ -0,0 +1,232 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultOutboxPollInterval is how often the dispatcher looks for unpublished events
+	defaultOutboxPollInterval = time.Second
+	// defaultOutboxBatchSize is the number of events published per poll
+	defaultOutboxBatchSize = 100
+	// defaultOutboxInitialBackoff is the wait before the first retry of a failed event
+	defaultOutboxInitialBackoff = time.Second
+	// defaultOutboxMaxBackoff caps the wait between retries of a failed event
+	defaultOutboxMaxBackoff = 5 * time.Minute
+	// outboxLastErrorSize bounds the stored publish error
+	outboxLastErrorSize = 1024
+)
+
+// OutboxEvent is a row of the outbox table: an event waiting to be published
+type OutboxEvent struct {
+	ID            string `gorm:"primaryKey;size:36"`
+	TenantID      string `gorm:"size:64;index"`
+	Subject       string `gorm:"size:255;not null"`
+	Payload       []byte
+	Attempts      int
+	LastError     string     `gorm:"size:1024"`
+	NextAttemptAt time.Time  `gorm:"index"`
+	PublishedAt   *time.Time `gorm:"index"`
+	CreatedAt     time.Time
+}
+
+// TableName stores every event in the "outbox" table
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// MigrateOutbox creates or updates the outbox table
+func MigrateOutbox(db *gorm.DB) error {
+	return db.AutoMigrate(&OutboxEvent{})
+}
+
+type gormOutbox struct{}
+
+// Enqueue inserts the event through tx, stamped with the tenant of the tx context
+func (outbox *gormOutbox) Enqueue(tx *gorm.DB, subject string, data []byte) error {
+	now := time.Now().UTC()
+	event := &OutboxEvent{
+		ID:            newRequestID(),
+		TenantID:      tenantFromContext(tx.Statement.Context),
+		Subject:       subject,
+		Payload:       data,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	return tx.Create(event).Error
+}
+
+// NewOutbox returns an Outbox storing events in the outbox table. Enqueue with the handle given by
+// WithTransaction so the event is only committed together with the state change it describes; an
+// OutboxDispatcher publishes it afterwards.
+//
+// Example Usage:
+//
+//	err := WithTransaction(ctx, db, func(tx *gorm.DB) error {
+//	    if err := tx.Create(&order).Error; err != nil {
+//	        return err
+//	    }
+//	    return outbox.Enqueue(tx, "orders.created", payload)
+//	})
+func NewOutbox() Outbox {
+	return &gormOutbox{}
+}
+
+type outboxDispatcher struct {
+	db             *gorm.DB
+	publisher      Publisher
+	loggR          *zap.Logger
+	pollInterval   time.Duration
+	batchSize      int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// OutboxOption customizes the dispatcher returned by NewOutboxDispatcher
+type OutboxOption func(*outboxDispatcher)
+
+// WithOutboxPollInterval sets how often unpublished events are looked up (default 1s)
+func WithOutboxPollInterval(interval time.Duration) OutboxOption {
+	return func(dispatcher *outboxDispatcher) {
+		dispatcher.pollInterval = interval
+	}
+}
+
+// WithOutboxBatchSize sets the number of events published per poll (default 100)
+func WithOutboxBatchSize(batchSize int) OutboxOption {
+	return func(dispatcher *outboxDispatcher) {
+		dispatcher.batchSize = batchSize
+	}
+}
+
+// WithOutboxBackoff sets the wait before retrying a failed event, doubled on every failure and
+// capped at maxBackoff (default 1s up to 5m)
+func WithOutboxBackoff(initial, maxBackoff time.Duration) OutboxOption {
+	return func(dispatcher *outboxDispatcher) {
+		dispatcher.initialBackoff = initial
+		dispatcher.maxBackoff = maxBackoff
+	}
+}
+
+// Run polls the outbox until ctx is done, returning nil on cancellation
+func (dispatcher *outboxDispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(dispatcher.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := dispatcher.dispatch(ctx); err != nil && ctx.Err() == nil {
+			dispatcher.loggR.Error("failed to dispatch outbox events", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch publishes one batch of due events. Rows are locked with SKIP LOCKED where supported, so
+// several replicas can run a dispatcher without publishing the same event concurrently.
+func (dispatcher *outboxDispatcher) dispatch(ctx context.Context) error {
+	return dispatcher.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("published_at IS NULL AND next_attempt_at <= ?", time.Now().UTC()).
+			Order("created_at").
+			Limit(dispatcher.batchSize)
+		if tx.Dialector.Name() != "sqlite" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var events []OutboxEvent
+		if err := query.Find(&events).Error; err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			eventCtx := ctx
+			if event.TenantID != "" {
+				eventCtx = context.WithValue(ctx, XTenantKey, event.TenantID)
+			}
+
+			if publishErr := dispatcher.publisher.Publish(eventCtx, event.Subject, event.Payload); publishErr != nil {
+				if err := dispatcher.markFailed(tx, event, publishErr); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Model(&OutboxEvent{}).Where("id = ?", event.ID).Update("published_at", time.Now().UTC()).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// markFailed records the publish error and schedules the next attempt with exponential backoff
+func (dispatcher *outboxDispatcher) markFailed(tx *gorm.DB, event OutboxEvent, publishErr error) error {
+	attempts := event.Attempts + 1
+	backoff := dispatcher.initialBackoff
+	for i := 1; i < attempts && backoff < dispatcher.maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, dispatcher.maxBackoff)
+
+	lastError := publishErr.Error()
+	if len(lastError) > outboxLastErrorSize {
+		lastError = lastError[:outboxLastErrorSize]
+	}
+
+	dispatcher.loggR.Warn("failed to publish outbox event",
+		zap.String("id", event.ID),
+		zap.String("subject", event.Subject),
+		zap.Int("attempts", attempts),
+		zap.Duration("retry_in", backoff),
+		zap.Error(publishErr),
+	)
+
+	return tx.Model(&OutboxEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": time.Now().UTC().Add(backoff),
+	}).Error
+}
+
+// NewOutboxDispatcher returns a dispatcher publishing the events of the outbox table through
+// publisher. Delivery is at-least-once: an event published just before a crash, or before marking
+// it as sent fails, is published again, so consumers must be idempotent (the event id is not part
+// of the message, so deduplicate on a business key in the payload).
+//
+// Example Usage:
+//
+//	dispatcher, err := NewOutboxDispatcher(db, NewPublisher(js), logger, WithOutboxPollInterval(500*time.Millisecond))
+//	go dispatcher.Run(ctx)
+func NewOutboxDispatcher(db *gorm.DB, publisher Publisher, logger *zap.Logger, opts ...OutboxOption) (OutboxDispatcher, error) {
+	dispatcher := &outboxDispatcher{
+		db:             db,
+		publisher:      publisher,
+		loggR:          logger,
+		pollInterval:   defaultOutboxPollInterval,
+		batchSize:      defaultOutboxBatchSize,
+		initialBackoff: defaultOutboxInitialBackoff,
+		maxBackoff:     defaultOutboxMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(dispatcher)
+	}
+
+	if dispatcher.pollInterval <= 0 || dispatcher.batchSize <= 0 || dispatcher.initialBackoff <= 0 || dispatcher.maxBackoff < dispatcher.initialBackoff {
+		return nil, errors.New("outbox poll interval, batch size and backoff must be positive")
+	}
+	return dispatcher, nil
+}
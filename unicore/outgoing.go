@@ -0,0 +1,51 @@
+package unicore
+
+import (
+	"context"
+
+	"connectrpc.com/connect"
+	"google.golang.org/grpc/metadata"
+)
+
+// XOnBehalfOfKey is the header carrying the subject of the user a service call is made for
+const XOnBehalfOfKey = "x-on-behalf-of"
+
+// WithServiceAccountContext returns an outgoing context that authenticates with the given service
+// token while preserving the original user's subject in the x-on-behalf-of header for audit logging.
+// The tenant of ctx, when present, is forwarded as well.
+//
+// The values are stored as outgoing gRPC metadata; connect clients pick them up through
+// OutgoingMetadataInterceptor.
+//
+// Example Usage:
+//
+//	outCtx := WithServiceAccountContext(ctx, serviceToken, helper.GetUserClaims(ctx))
+//	resp, err := client.Notify(outCtx, connect.NewRequest(msg))
+func WithServiceAccountContext(ctx context.Context, serviceToken string, claims *UserAuthClaims) context.Context {
+	pairs := []string{"authorization", "Bearer " + serviceToken}
+	if claims != nil && claims.Id != "" {
+		pairs = append(pairs, XOnBehalfOfKey, claims.Id)
+	}
+	if tenantID := tenantFromContext(ctx); tenantID != "" {
+		pairs = append(pairs, XTenantKey, tenantID)
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// OutgoingMetadataInterceptor is a client-side interceptor that copies the outgoing gRPC metadata
+// of the context into the connect request headers, replacing any existing values.
+func OutgoingMetadataInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if md, ok := metadata.FromOutgoingContext(ctx); ok && req.Spec().IsClient {
+				for key, values := range md {
+					req.Header().Del(key)
+					for _, value := range values {
+						req.Header().Add(key, value)
+					}
+				}
+			}
+			return next(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package unicore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestWithServiceAccountContext(t *testing.T) {
+	ctx := withTenant(context.Background(), "acme")
+	outCtx := WithServiceAccountContext(ctx, "service-token", &UserAuthClaims{Id: "alice"})
+
+	md, ok := metadata.FromOutgoingContext(outCtx)
+	if !ok {
+		t.Fatal("expected outgoing metadata")
+	}
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer service-token" {
+		t.Fatalf("expected the service token, got %v", got)
+	}
+	if got := md.Get(XOnBehalfOfKey); len(got) != 1 || got[0] != "alice" {
+		t.Fatalf("expected the user subject in %s, got %v", XOnBehalfOfKey, got)
+	}
+	if got := md.Get(XTenantKey); len(got) != 1 || got[0] != "acme" {
+		t.Fatalf("expected the tenant, got %v", got)
+	}
+}
+
+func TestWithServiceAccountContextWithoutUser(t *testing.T) {
+	md, _ := metadata.FromOutgoingContext(WithServiceAccountContext(context.Background(), "service-token", nil))
+	if got := md.Get(XOnBehalfOfKey); len(got) != 0 {
+		t.Fatalf("expected no %s header without claims, got %v", XOnBehalfOfKey, got)
+	}
+}
+
+func TestOutgoingMetadataInterceptorSendsServiceAccountHeaders(t *testing.T) {
+	var header http.Header
+	server := newTestServer(t, func(_ context.Context, req *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		header = req.Header().Clone()
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	})
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+testProcedure,
+		connect.WithInterceptors(OutgoingMetadataInterceptor()))
+
+	outCtx := WithServiceAccountContext(context.Background(), "service-token", &UserAuthClaims{Id: "alice"})
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer user-token")
+	if _, err := client.CallUnary(outCtx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := header.Get("Authorization"); got != "Bearer service-token" {
+		t.Fatalf("expected the service token to replace the user token, got %q", got)
+	}
+	if got := header.Get(XOnBehalfOfKey); got != "alice" {
+		t.Fatalf("expected the audit header to carry alice, got %q", got)
+	}
+}
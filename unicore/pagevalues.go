@@ -0,0 +1,78 @@
+package unicore
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+)
+
+// Query parameters read by PageRequestFromValues and written by PageRequestToValues
+const (
+	pageParam      = "page"
+	limitParam     = "limit"
+	sortParam      = "sort"
+	directionParam = "direction"
+	filterParam    = "filter"
+)
+
+// PageRequestFromValues builds a PageRequest from URL query parameters (page, limit, sort,
+// direction and filter). The direction is "asc" or "desc", case-insensitively. The sort field must
+// be a plain column name such as "created_at" or "orders.total", since it ends up in ORDER BY;
+// anything else is dropped. Missing or invalid values get the defaults of WithPaginationScope, see
+// NormalizePageRequest. Check the sort field against the columns clients may sort by, e.g. with
+// WithMultiSortScope, before using it on tables with sensitive columns.
+//
+// Example Usage:
+//
+//	page := PageRequestFromValues(r.URL.Query())
+func PageRequestFromValues(values url.Values) *commonv1.PageRequest {
+	p := &commonv1.PageRequest{
+		Filter: values.Get(filterParam),
+	}
+
+	if sort := strings.TrimSpace(values.Get(sortParam)); isSortField(sort) {
+		p.Sort = sort
+	}
+	if page, err := strconv.ParseInt(values.Get(pageParam), 10, 32); err == nil {
+		p.Page = int32(page)
+	}
+	if limit, err := strconv.ParseInt(values.Get(limitParam), 10, 32); err == nil {
+		p.Limit = int32(limit)
+	}
+	if strings.EqualFold(strings.TrimSpace(values.Get(directionParam)), "asc") {
+		p.Direction = commonv1.SortDirection_SORT_DIRECTION_ASC
+	}
+
+	return NormalizePageRequest(p, DefaultMaxPageLimit)
+}
+
+// PageRequestToValues encodes a PageRequest as URL query parameters understood by
+// PageRequestFromValues. Unset fields are omitted.
+//
+// Example Usage:
+//
+//	endpoint.RawQuery = PageRequestToValues(req.GetPage()).Encode()
+func PageRequestToValues(p *commonv1.PageRequest) url.Values {
+	values := url.Values{}
+	if page := p.GetPage(); page != 0 {
+		values.Set(pageParam, strconv.FormatInt(int64(page), 10))
+	}
+	if limit := p.GetLimit(); limit != 0 {
+		values.Set(limitParam, strconv.FormatInt(int64(limit), 10))
+	}
+	if sort := p.GetSort(); sort != "" {
+		values.Set(sortParam, sort)
+	}
+	switch p.GetDirection() {
+	case commonv1.SortDirection_SORT_DIRECTION_ASC:
+		values.Set(directionParam, "asc")
+	case commonv1.SortDirection_SORT_DIRECTION_DESC:
+		values.Set(directionParam, "desc")
+	}
+	if filter := p.GetFilter(); filter != "" {
+		values.Set(filterParam, filter)
+	}
+	return values
+}
@@ -0,0 +1,155 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"gorm.io/gorm"
+)
+
+// totalCountColumn is the alias of the window function column added by PaginateWithCount
+const totalCountColumn = "unicore_total_count"
+
+// countedRow scans a row together with the total produced by COUNT(*) OVER()
+type countedRow[T any] struct {
+	Row        T     `gorm:"embedded"`
+	TotalCount int64 `gorm:"column:unicore_total_count"`
+}
+
+// PaginateWithCount loads one page of T into dest together with the total number of matching rows,
+// applying the tenant scope of ctx and WithPaginationScope. On databases supporting window functions
+// the total is computed in the same query with COUNT(*) OVER(); SQLite, and pages past the end
+// (which return no row to read the total from), fall back to a separate COUNT query.
+//
+// Example Usage:
+//
+//	var products []Product
+//	result, err := PaginateWithCount(ctx, db.Where("active = ?", true), req.GetPage(), &products)
+func PaginateWithCount[T any](ctx context.Context, db *gorm.DB, page *commonv1.PageRequest, dest *[]T) (*PagedResult[[]T], error) {
+	base := db.WithContext(ctx).Model(new(T)).Scopes(WithTenantScope(ctx)).Session(&gorm.Session{})
+
+	if base.Dialector.Name() != "sqlite" {
+		if err := base.Statement.Parse(new(T)); err != nil {
+			return nil, err
+		}
+
+		var rows []countedRow[T]
+		selection := fmt.Sprintf("%s.*, COUNT(*) OVER() AS %s", base.Statement.Quote(base.Statement.Table), totalCountColumn)
+		if err := base.Select(selection).Scopes(WithPaginationScope(page)).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+
+		if len(rows) > 0 {
+			items := make([]T, len(rows))
+			for i, row := range rows {
+				items[i] = row.Row
+			}
+			*dest = items
+			return NewPagedResult(rows[0].TotalCount, *dest), nil
+		}
+	}
+
+	return paginateWithSeparateCount(base, page, dest)
+}
+
+// Paginate loads one page of the query into a PagedResult: it counts every matching row, then
+// applies WithPaginationScope and runs Find. Both queries reuse the conditions of db, including
+// any tenant scope already applied, and db itself is left untouched. Queries grouped with Group
+// are counted through a subquery, so the total is the number of groups rather than the rows of
+// the first group. Unlike PaginateWithCount, it does not add the tenant scope itself.
+//
+// Example Usage:
+//
+//	result, err := Paginate[Product](db.WithContext(ctx).Scopes(WithTenantScope(ctx)).Where("active = ?", true), req.GetPage())
+func Paginate[T any](db *gorm.DB, page *commonv1.PageRequest) (*PagedResult[[]T], error) {
+	var items []T
+	base := db.Model(new(T)).Session(&gorm.Session{})
+	return paginateWithSeparateCount(base, page, &items)
+}
+
+// paginateWithSeparateCount loads one page of base into dest after counting its rows with a
+// separate COUNT query. base must be a new session so both queries start from the same conditions.
+func paginateWithSeparateCount[T any](base *gorm.DB, page *commonv1.PageRequest, dest *[]T) (*PagedResult[[]T], error) {
+	total, err := countRows(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := base.Scopes(WithPaginationScope(page)).Find(dest).Error; err != nil {
+		return nil, err
+	}
+
+	return NewPagedResult(total, *dest), nil
+}
+
+// countRows counts the rows of base. GORM counts a grouped query by reading back every group, so
+// those are wrapped in a subquery and counted by the database instead.
+func countRows(base *gorm.DB) (int64, error) {
+	var total int64
+	if _, grouped := base.Statement.Clauses["GROUP BY"]; grouped {
+		err := base.Session(&gorm.Session{NewDB: true}).Table("(?) AS unicore_grouped", base).Count(&total).Error
+		return total, err
+	}
+
+	err := base.Count(&total).Error
+	return total, err
+}
+
+// PaginateDistinct works like Paginate for queries joining one-to-many tables, where COUNT(*)
+// would count a row once per joined row: the total counts the distinct values of distinctColumn
+// (the primary key of T when empty) and the page selects DISTINCT rows of T. Both queries are
+// restricted to the tenant of the query's context on the tenant_id column of T's table, which is
+// qualified so it stays unambiguous once joined; ErrMissingTenant is returned without a tenant.
+// An unqualified sort field is qualified with T's table for the same reason.
+//
+// Example Usage:
+//
+//	query := db.WithContext(ctx).Joins("JOIN order_lines ON order_lines.order_id = orders.id").
+//	    Where("order_lines.sku = ?", sku)
+//	result, err := PaginateDistinct[Order](query, req.GetPage(), "orders.id")
+func PaginateDistinct[T any](db *gorm.DB, page *commonv1.PageRequest, distinctColumn string) (*PagedResult[[]T], error) {
+	ctx := db.Statement.Context
+	if ctx == nil || tenantFromContext(ctx) == "" {
+		return nil, ErrMissingTenant
+	}
+
+	base := db.Model(new(T)).Session(&gorm.Session{})
+	if err := base.Statement.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	table := base.Statement.Table
+	if distinctColumn == "" {
+		if base.Statement.Schema.PrioritizedPrimaryField == nil {
+			return nil, fmt.Errorf("paginating %s: no distinct column given and no primary key", table)
+		}
+		distinctColumn = table + "." + base.Statement.Schema.PrioritizedPrimaryField.DBName
+	}
+	base = base.Scopes(WithTenantScopeColumn(ctx, table+".tenant_id")).Session(&gorm.Session{})
+
+	var total int64
+	if err := base.Distinct(distinctColumn).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// ORDER BY must name a column of the DISTINCT selection, so sort on T's own column
+	sorted := &commonv1.PageRequest{
+		Page:      page.GetPage(),
+		Limit:     page.GetLimit(),
+		Sort:      NormalizePageRequest(page, DefaultMaxPageLimit).GetSort(),
+		Direction: page.GetDirection(),
+		Filter:    page.GetFilter(),
+	}
+	if !strings.Contains(sorted.Sort, ".") {
+		sorted.Sort = table + "." + sorted.Sort
+	}
+
+	var items []T
+	selection := base.Statement.Quote(table) + ".*"
+	if err := base.Distinct(selection).Scopes(WithPaginationScope(sorted)).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	return NewPagedResult(total, items), nil
+}
@@ -0,0 +1,202 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// seedCustomerPurchases gives the acme customers 3, 2 and 1 purchases and the customer of another
+// tenant 2 purchases
+func seedCustomerPurchases(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := openTestDB(t, &joinCustomer{}, &joinPurchase{})
+	db.Create(&[]joinCustomer{
+		{ID: 1, TenantID: "acme", Name: "alice"},
+		{ID: 2, TenantID: "acme", Name: "bob"},
+		{ID: 3, TenantID: "acme", Name: "carol"},
+		{ID: 4, TenantID: "other", Name: "dave"},
+	})
+	db.Create(&[]joinPurchase{
+		{TenantID: "acme", JoinCustomerID: 1, Sku: "book"},
+		{TenantID: "acme", JoinCustomerID: 1, Sku: "pen"},
+		{TenantID: "acme", JoinCustomerID: 1, Sku: "ink"},
+		{TenantID: "acme", JoinCustomerID: 2, Sku: "book"},
+		{TenantID: "acme", JoinCustomerID: 2, Sku: "pen"},
+		{TenantID: "acme", JoinCustomerID: 3, Sku: "lamp"},
+		{TenantID: "other", JoinCustomerID: 4, Sku: "book"},
+		{TenantID: "other", JoinCustomerID: 4, Sku: "pen"},
+	})
+	return db
+}
+
+func joinedPurchases(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.WithContext(ctx).Joins("JOIN join_purchases ON join_purchases.join_customer_id = join_customers.id")
+}
+
+func customerNames(customers []joinCustomer) []string {
+	names := make([]string, 0, len(customers))
+	for _, customer := range customers {
+		names = append(names, customer.Name)
+	}
+	return names
+}
+
+func TestPaginateDistinctCountsJoinedRowsOnce(t *testing.T) {
+	db := seedCustomerPurchases(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	var joined int64
+	joinedPurchases(ctx, db).Model(&joinCustomer{}).Where("join_customers.tenant_id = ?", "acme").Count(&joined)
+	if joined != 6 {
+		t.Fatalf("expected the join to yield 6 rows, got %d", joined)
+	}
+
+	// "id" exists on both tables, so the sort only works once it is qualified with the customers table.
+	page := &commonv1.PageRequest{Page: 1, Limit: 2, Sort: "id", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+	for name, column := range map[string]string{"explicit column": "join_customers.id", "primary key": ""} {
+		t.Run(name, func(t *testing.T) {
+			result, err := PaginateDistinct[joinCustomer](joinedPurchases(ctx, db), page, column)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != 3 {
+				t.Fatalf("expected a total of 3 customers, got %d", result.Total)
+			}
+			if got, want := customerNames(result.Items), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	second := &commonv1.PageRequest{Page: 2, Limit: 2, Sort: "id", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+	result, err := PaginateDistinct[joinCustomer](joinedPurchases(ctx, db), second, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := customerNames(result.Items), []string{"carol"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("second page: got %v, want %v", got, want)
+	}
+}
+
+func TestPaginateDistinctKeepsConditions(t *testing.T) {
+	db := seedCustomerPurchases(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	page := &commonv1.PageRequest{Sort: "name", Direction: commonv1.SortDirection_SORT_DIRECTION_DESC}
+	result, err := PaginateDistinct[joinCustomer](joinedPurchases(ctx, db).Where("join_purchases.sku = ?", "book"), page, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := customerNames(result.Items), []string{"bob", "alice"}; result.Total != 2 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the 2 acme book buyers, got %v of %d", got, result.Total)
+	}
+}
+
+func TestPaginateDistinctRequiresTenant(t *testing.T) {
+	db := seedCustomerPurchases(t)
+
+	_, err := PaginateDistinct[joinCustomer](joinedPurchases(context.Background(), db), nil, "")
+	if !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("expected ErrMissingTenant, got %v", err)
+	}
+}
+
+// withWindowFunctions returns a handle on the connection of db whose dialector does not report
+// SQLite, so PaginateWithCount takes its COUNT(*) OVER() path, which SQLite supports as well. The
+// SQL of every query is sent to queries.
+func withWindowFunctions(t *testing.T, db *gorm.DB, queries chan<- string) *gorm.DB {
+	t.Helper()
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := gorm.Open(renamedDialector{Dialector: &sqlite.Dialector{Conn: sqlDB}, name: "postgres"}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = renamed.Callback().Query().After("gorm:query").Register("test:record_sql", func(tx *gorm.DB) {
+		queries <- tx.Statement.SQL.String()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return renamed
+}
+
+func TestPaginateWithCountWindowFunction(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	db.Create(&[]exportRow{
+		{TenantID: "acme", Name: "a", CreatedAt: 10},
+		{TenantID: "acme", Name: "b", CreatedAt: 20},
+		{TenantID: "other", Name: "x", CreatedAt: 30},
+		{TenantID: "acme", Name: "c", CreatedAt: 40},
+	})
+	queries := make(chan string, 10)
+	windowed := withWindowFunctions(t, db, queries)
+	ctx := withTenant(context.Background(), "acme")
+
+	var rows []exportRow
+	page := &commonv1.PageRequest{Page: 1, Limit: 2, Sort: "id", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+	result, err := PaginateWithCount(ctx, windowed, page, &rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sql := <-queries; !strings.Contains(sql, "COUNT(*) OVER()") {
+		t.Fatalf("expected the window function query, got %s", sql)
+	}
+	if len(queries) != 0 {
+		t.Fatalf("expected a single query, got another: %s", <-queries)
+	}
+	if result.Total != 3 {
+		t.Fatalf("expected a total of 3 acme rows, got %d", result.Total)
+	}
+	want := []exportRow{{ID: 1, TenantID: "acme", Name: "a", CreatedAt: 10}, {ID: 2, TenantID: "acme", Name: "b", CreatedAt: 20}}
+	if !reflect.DeepEqual(rows, want) || !reflect.DeepEqual(result.Items, want) {
+		t.Fatalf("expected the embedded columns to be scanned into the rows, got %+v", rows)
+	}
+}
+
+func TestPaginateWithCountWindowFunctionPastLastPage(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	db.Create(&[]exportRow{{TenantID: "acme", Name: "a"}, {TenantID: "acme", Name: "b"}})
+	queries := make(chan string, 10)
+	windowed := withWindowFunctions(t, db, queries)
+
+	var rows []exportRow
+	result, err := PaginateWithCount(withTenant(context.Background(), "acme"), windowed, &commonv1.PageRequest{Page: 5, Limit: 2}, &rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The page holds no row to read the total from, so it is counted separately.
+	if len(queries) < 2 {
+		t.Fatalf("expected a separate count after the empty page, got %d queries", len(queries))
+	}
+	if result.Total != 2 || len(rows) != 0 {
+		t.Fatalf("expected an empty page of 2 rows, got %+v of %d", rows, result.Total)
+	}
+}
+
+func TestPaginateWithCountSeparateCount(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	db.Create(&[]exportRow{{TenantID: "acme", Name: "a"}, {TenantID: "acme", Name: "b"}, {TenantID: "other", Name: "x"}})
+
+	var rows []exportRow
+	result, err := PaginateWithCount(withTenant(context.Background(), "acme"), db, &commonv1.PageRequest{Limit: 1}, &rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 2 || len(rows) != 1 || rows[0].TenantID != "acme" {
+		t.Fatalf("expected one of 2 acme rows, got %+v of %d", rows, result.Total)
+	}
+}
@@ -0,0 +1,47 @@
+package unicore
+
+import (
+	"fmt"
+	"slices"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+// WithPreloadScope creates a GORM scope function eager loading the named associations with
+// db.Preload. Nested associations use dots ("Orders.Items"). Each association costs one extra
+// query per load, and a preloaded has-many relation is loaded in full, so only preload what the
+// response actually needs. The association queries are not tenant scoped; records reached
+// through a tenant scoped parent normally share its tenant.
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithPreloadScope("Customer", "Items")).First(&order, "id = ?", id)
+func WithPreloadScope(associations ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, association := range associations {
+			db = db.Preload(association)
+		}
+		return db
+	}
+}
+
+// WithPreloadAllowed works like WithPreloadScope for associations requested by the client,
+// failing the query with CodeInvalidArgument when one is not in allowed. Use it instead of
+// WithPreloadScope with client input, so callers cannot load arbitrarily large object graphs.
+//
+// Example Usage:
+//
+//	allowed := []string{"Customer", "Items"}
+//	page, err := orders.List(ctx, req.GetPage(), WithPreloadAllowed(req.GetInclude(), allowed))
+func WithPreloadAllowed(requested, allowed []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, association := range requested {
+			if !slices.Contains(allowed, association) {
+				_ = db.AddError(connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("including %q is not allowed", association)))
+				return db
+			}
+		}
+		return WithPreloadScope(requested...)(db)
+	}
+}
@@ -0,0 +1,90 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"connectrpc.com/connect"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// maxPanicStackLines caps the stack lines attached to a panic error outside production
+const maxPanicStackLines = 32
+
+// WithConfig gives the middleware the service configuration, e.g. so RecoveryInterceptor knows
+// whether it runs in production. Without it the middleware behaves as in production.
+func WithConfig(cfg Config) MiddlewareOption {
+	return func(middleware *grpcAuthMiddleware) {
+		middleware.config = cfg
+	}
+}
+
+// isProduction reports whether the configured environment is production, defaulting to true
+// when no Config was given
+func (middleware *grpcAuthMiddleware) isProduction() bool {
+	return middleware.config == nil || middleware.config.IsProduction()
+}
+
+// RecoveryInterceptor turns handler panics into CodeInternal errors instead of dropping the
+// connection, logging the panic value with its stack. In production the client only gets a
+// generic message; otherwise the panic message and a truncated stack are attached as an
+// errdetails.DebugInfo detail. Register it first so it also covers the other interceptors.
+//
+// Example Usage:
+//
+//	middleware := NewMiddleware(authenticator, logger, helper, WithConfig(cfg))
+//	connect.WithInterceptors(
+//	    middleware.RecoveryInterceptor(),
+//	    middleware.RequestIDUnaryInterceptor(),
+//	    middleware.UnaryTokenInterceptor(),
+//	)
+func (middleware *grpcAuthMiddleware) RecoveryInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (resp connect.AnyResponse, err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				stack := debug.Stack()
+				middleware.loggR.Error("panic recovered",
+					zap.String("method", req.Spec().Procedure),
+					zap.Any("panic", recovered),
+					zap.ByteString("stack", stack),
+				)
+
+				resp = nil
+				err = middleware.panicError(recovered, stack)
+			}()
+			return next(ctx, req)
+		}
+	}
+}
+
+// panicError builds the error returned for a recovered panic
+func (middleware *grpcAuthMiddleware) panicError(recovered any, stack []byte) error {
+	if middleware.isProduction() {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("internal error"))
+	}
+
+	message := fmt.Sprintf("panic: %v", recovered)
+	connectErr := connect.NewError(connect.CodeInternal, fmt.Errorf("%s", message))
+	addErrorDetail(connectErr, &errdetails.DebugInfo{
+		Detail:       message,
+		StackEntries: truncateStack(stack, maxPanicStackLines),
+	})
+	return connectErr
+}
+
+// truncateStack splits the stack into lines, keeping at most maxLines
+func truncateStack(stack []byte, maxLines int) []string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], "...")
+	}
+	return lines
+}
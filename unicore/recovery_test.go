@@ -0,0 +1,97 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// environmentConfig reports a fixed environment; the other Config methods are not used
+type environmentConfig struct {
+	Config
+	production bool
+}
+
+func (cfg environmentConfig) IsProduction() bool { return cfg.production }
+
+func panickingHandler(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+	panic("secret connection string")
+}
+
+func debugInfo(t *testing.T, err error) *errdetails.DebugInfo {
+	t.Helper()
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("expected a connect error, got %v", err)
+	}
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info, ok := value.(*errdetails.DebugInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+func TestRecoveryInterceptorHidesPanicInProduction(t *testing.T) {
+	for name, middleware := range map[string]*grpcAuthMiddleware{
+		"production": newTestMiddleware(WithConfig(environmentConfig{production: true})),
+		"no config":  newTestMiddleware(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, panickingHandler, middleware.RecoveryInterceptor())
+
+			_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+			assertCode(t, err, connect.CodeInternal)
+			if strings.Contains(err.Error(), "secret") {
+				t.Fatalf("expected the panic message to be hidden, got %v", err)
+			}
+			if info := debugInfo(t, err); info != nil {
+				t.Fatalf("expected no debug info in production, got %v", info)
+			}
+		})
+	}
+}
+
+func TestRecoveryInterceptorExposesPanicOutsideProduction(t *testing.T) {
+	middleware := newTestMiddleware(WithConfig(environmentConfig{production: false}))
+	client := newTestClient(t, panickingHandler, middleware.RecoveryInterceptor())
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	assertCode(t, err, connect.CodeInternal)
+	if !strings.Contains(err.Error(), "panic: secret connection string") {
+		t.Fatalf("expected the panic message, got %v", err)
+	}
+
+	info := debugInfo(t, err)
+	if info == nil {
+		t.Fatal("expected a DebugInfo detail")
+	}
+	if info.Detail != "panic: secret connection string" {
+		t.Fatalf("unexpected debug detail %q", info.Detail)
+	}
+	if len(info.StackEntries) == 0 || len(info.StackEntries) > maxPanicStackLines+1 {
+		t.Fatalf("expected a stack of at most %d lines, got %d", maxPanicStackLines, len(info.StackEntries))
+	}
+}
+
+func TestTruncateStack(t *testing.T) {
+	stack := []byte(strings.Repeat("frame\n", 10))
+
+	if lines := truncateStack(stack, 20); len(lines) != 10 {
+		t.Fatalf("expected a short stack to be kept, got %d lines", len(lines))
+	}
+	lines := truncateStack(stack, 4)
+	if len(lines) != 5 || lines[4] != "..." {
+		t.Fatalf("expected 4 lines and a marker, got %q", lines)
+	}
+}
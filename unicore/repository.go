@@ -0,0 +1,204 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+type gormRepository[T any] struct {
+	db *gorm.DB
+}
+
+// scoped returns a handle bound to ctx and filtered by its tenant
+func (repository *gormRepository[T]) scoped(ctx context.Context) *gorm.DB {
+	return repository.db.WithContext(ctx).Scopes(WithTenantScope(ctx))
+}
+
+// Create stamps the record with the tenant from ctx and inserts it
+func (repository *gormRepository[T]) Create(ctx context.Context, record *T) error {
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return ErrMissingTenant
+	}
+	if err := stampTenant(record, tenantID); err != nil {
+		return err
+	}
+
+	if err := repository.db.WithContext(ctx).Create(record).Error; err != nil {
+		return MapGormError(err)
+	}
+	return nil
+}
+
+// GetByID returns the record with the given primary key, or CodeNotFound when it does not exist
+// within the tenant
+func (repository *gormRepository[T]) GetByID(ctx context.Context, id string) (*T, error) {
+	record := new(T)
+	if err := repository.scoped(ctx).First(record, "id = ?", id).Error; err != nil {
+		return nil, MapGormError(err)
+	}
+	return record, nil
+}
+
+// List returns one page of the tenant's records together with the total count. Scopes, such as
+// WithPreloadScope or filters, apply to both the page and the count. As preloads cannot be combined
+// with the COUNT(*) OVER() query of PaginateWithCount, the total is then counted separately.
+func (repository *gormRepository[T]) List(ctx context.Context, page *commonv1.PageRequest, scopes ...func(*gorm.DB) *gorm.DB) (*PagedResult[[]T], error) {
+	var records []T
+	var result *PagedResult[[]T]
+	var err error
+	if len(scopes) == 0 {
+		result, err = PaginateWithCount(ctx, repository.db, page, &records)
+	} else {
+		base := repository.scoped(ctx).Model(new(T)).Scopes(scopes...).Session(&gorm.Session{})
+		result, err = paginateWithSeparateCount(base, page, &records)
+	}
+	if err != nil {
+		return nil, MapGormError(err)
+	}
+	return result, nil
+}
+
+// Update saves every field of the record, returning CodeNotFound when it does not exist within the
+// tenant. The tenant id is re-stamped so a record cannot be moved to another tenant.
+func (repository *gormRepository[T]) Update(ctx context.Context, record *T) error {
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return ErrMissingTenant
+	}
+	if err := stampTenant(record, tenantID); err != nil {
+		return err
+	}
+
+	result := repository.scoped(ctx).Model(record).Select("*").Updates(record)
+	if result.Error != nil {
+		return MapGormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("%T not found", *record))
+	}
+	return nil
+}
+
+// Delete soft deletes the record with the given primary key, after checking that it exists within
+// the tenant so ids of other tenants are reported as CodeNotFound. Models without a gorm.DeletedAt
+// field are deleted for good; soft deleted records can be brought back with Restore.
+func (repository *gormRepository[T]) Delete(ctx context.Context, id string) error {
+	return repository.delete(ctx, repository.scoped(ctx), id)
+}
+
+// HardDelete permanently removes the record with the given primary key, including a soft deleted
+// one, with the same tenant check as Delete
+func (repository *gormRepository[T]) HardDelete(ctx context.Context, id string) error {
+	return repository.delete(ctx, repository.scoped(ctx).Unscoped(), id)
+}
+
+// delete checks that the record exists within the tenant, then deletes it with db
+func (repository *gormRepository[T]) delete(ctx context.Context, db *gorm.DB, id string) error {
+	if tenantFromContext(ctx) == "" {
+		return ErrMissingTenant
+	}
+	db = db.Session(&gorm.Session{})
+
+	var count int64
+	if err := db.Model(new(T)).Where("id = ?", id).Count(&count).Error; err != nil {
+		return MapGormError(err)
+	}
+	if count == 0 {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("%T %s not found", *new(T), id))
+	}
+
+	result := db.Where("id = ?", id).Delete(new(T))
+	if result.Error != nil {
+		return MapGormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("%T %s not found", *new(T), id))
+	}
+	return nil
+}
+
+// DeleteWhere deletes every record of the tenant matching conditions (column to value, combined
+// with AND) and returns the number of rows deleted. Models with a gorm.DeletedAt field are soft
+// deleted. Empty conditions would delete every record of the tenant, so they are rejected with
+// ErrFullScanNotAllowed unless ctx was marked with AllowFullScan.
+func (repository *gormRepository[T]) DeleteWhere(ctx context.Context, conditions map[string]any) (int64, error) {
+	db, err := repository.bulkScoped(ctx, conditions)
+	if err != nil {
+		return 0, err
+	}
+
+	result := db.Delete(new(T))
+	if result.Error != nil {
+		return 0, MapGormError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// UpdateWhere sets the columns in updates on every record of the tenant matching conditions and
+// returns the number of rows updated, with the same guard against empty conditions as DeleteWhere.
+// The tenant column cannot be updated.
+func (repository *gormRepository[T]) UpdateWhere(ctx context.Context, conditions, updates map[string]any) (int64, error) {
+	if len(updates) == 0 {
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("no columns to update"))
+	}
+	if _, ok := updates["tenant_id"]; ok {
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("tenant_id cannot be updated"))
+	}
+
+	db, err := repository.bulkScoped(ctx, conditions)
+	if err != nil {
+		return 0, err
+	}
+
+	result := db.Model(new(T)).Updates(updates)
+	if result.Error != nil {
+		return 0, MapGormError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// bulkScoped returns a tenant scoped handle filtered by conditions, refusing to match every record
+// of the tenant unless ctx allows full scans
+func (repository *gormRepository[T]) bulkScoped(ctx context.Context, conditions map[string]any) (*gorm.DB, error) {
+	if tenantFromContext(ctx) == "" {
+		return nil, ErrMissingTenant
+	}
+
+	db := repository.scoped(ctx)
+	if len(conditions) == 0 {
+		if !isFullScanAllowed(ctx) {
+			return nil, ErrFullScanNotAllowed
+		}
+		return db, nil
+	}
+	return db.Where(conditions), nil
+}
+
+// Restore clears the soft delete marker of the record with the given primary key
+func (repository *gormRepository[T]) Restore(ctx context.Context, id string) error {
+	result := repository.scoped(ctx).Scopes(WithOnlyDeleted("")).Model(new(T)).Where("id = ?", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return MapGormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("deleted %T %s not found", *new(T), id))
+	}
+	return nil
+}
+
+// NewRepository returns a Repository for T whose operations are always scoped to the tenant in
+// the context. T must have an "id" primary key and a tenant_id column (see Tenantable).
+//
+// Example Usage:
+//
+//	products := NewRepository[Product](db)
+//	page, err := products.List(ctx, req.GetPage())
+func NewRepository[T any](db *gorm.DB) Repository[T] {
+	return &gormRepository[T]{db: db}
+}
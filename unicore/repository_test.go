@@ -0,0 +1,85 @@
+package unicore
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+func newDeleteTestRepository(t *testing.T) (*gorm.DB, Repository[softDeleteRow]) {
+	t.Helper()
+
+	db := openTestDB(t, &softDeleteRow{})
+	db.Create(&[]softDeleteRow{{ID: 1, TenantID: "acme"}, {ID: 2, TenantID: "other"}})
+	return db, NewRepository[softDeleteRow](db)
+}
+
+func countSoftDeleteRows(db *gorm.DB, id uint) (visible, all int64) {
+	db.Model(&softDeleteRow{}).Where("id = ?", id).Count(&visible)
+	db.Unscoped().Model(&softDeleteRow{}).Where("id = ?", id).Count(&all)
+	return visible, all
+}
+
+func TestRepositoryDeleteSoftDeletes(t *testing.T) {
+	db, repository := newDeleteTestRepository(t)
+
+	if err := repository.Delete(withTenant(context.Background(), "acme"), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if visible, all := countSoftDeleteRows(db, 1); visible != 0 || all != 1 {
+		t.Fatalf("expected the record to be soft deleted, got %d visible of %d", visible, all)
+	}
+}
+
+func TestRepositoryDeleteRejectsOtherTenant(t *testing.T) {
+	db, repository := newDeleteTestRepository(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	for name, remove := range map[string]func(context.Context, string) error{
+		"Delete":     repository.Delete,
+		"HardDelete": repository.HardDelete,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertCode(t, remove(ctx, "2"), connect.CodeNotFound)
+			if visible, _ := countSoftDeleteRows(db, 2); visible != 1 {
+				t.Fatal("expected the other tenant's record to be kept")
+			}
+		})
+	}
+}
+
+func TestRepositoryDeleteMissingRecord(t *testing.T) {
+	_, repository := newDeleteTestRepository(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	assertCode(t, repository.Delete(ctx, "42"), connect.CodeNotFound)
+	if err := repository.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	assertCode(t, repository.Delete(ctx, "1"), connect.CodeNotFound)
+}
+
+func TestRepositoryDeleteRequiresTenant(t *testing.T) {
+	_, repository := newDeleteTestRepository(t)
+
+	if err := repository.Delete(context.Background(), "1"); err != ErrMissingTenant {
+		t.Fatalf("expected ErrMissingTenant, got %v", err)
+	}
+}
+
+func TestRepositoryHardDelete(t *testing.T) {
+	db, repository := newDeleteTestRepository(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	if err := repository.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.HardDelete(ctx, "1"); err != nil {
+		t.Fatalf("expected a soft deleted record to be hard deletable: %v", err)
+	}
+	if _, all := countSoftDeleteRows(db, 1); all != 0 {
+		t.Fatal("expected the record to be removed for good")
+	}
+}
@@ -0,0 +1,142 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+)
+
+type requestSizeLimits struct {
+	defaultMax int64
+	procedures map[string]int64
+	streamMax  int64
+}
+
+// RequestSizeOption customizes MaxRequestSizeInterceptor
+type RequestSizeOption func(*requestSizeLimits)
+
+// WithProcedureSizeLimit overrides the per-message limit for one procedure. A limit of zero or less
+// disables the check for that procedure.
+func WithProcedureSizeLimit(procedure string, maxBytes int64) RequestSizeOption {
+	return func(limits *requestSizeLimits) {
+		limits.procedures[procedure] = maxBytes
+	}
+}
+
+// WithStreamSizeLimit caps the total size of all messages received on a single stream. Disabled by
+// default, leaving only the per-message limit.
+func WithStreamSizeLimit(maxBytes int64) RequestSizeOption {
+	return func(limits *requestSizeLimits) {
+		limits.streamMax = maxBytes
+	}
+}
+
+// limitFor returns the per-message limit of the procedure
+func (limits *requestSizeLimits) limitFor(procedure string) int64 {
+	if maxBytes, ok := limits.procedures[procedure]; ok {
+		return maxBytes
+	}
+	return limits.defaultMax
+}
+
+// checkMessage returns CodeResourceExhausted when the encoded size of msg exceeds maxBytes.
+// Messages that are not protobufs are not checked.
+func checkMessage(msg any, maxBytes int64) (int64, error) {
+	message, ok := msg.(proto.Message)
+	if !ok {
+		return 0, nil
+	}
+
+	size := int64(proto.Size(message))
+	if maxBytes > 0 && size > maxBytes {
+		return size, connect.NewError(connect.CodeResourceExhausted, fmt.Errorf("request message of %d bytes exceeds the %d byte limit", size, maxBytes))
+	}
+	return size, nil
+}
+
+type requestSizeInterceptor struct {
+	limits *requestSizeLimits
+}
+
+// WrapUnary rejects oversized unary requests before they reach the handler
+func (interceptor *requestSizeInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		if _, err := checkMessage(req.Any(), interceptor.limits.limitFor(req.Spec().Procedure)); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient leaves client streams unchanged
+func (interceptor *requestSizeInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler checks every received message and the running total of the stream
+func (interceptor *requestSizeInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return next(ctx, &sizeLimitedHandlerConn{
+			StreamingHandlerConn: conn,
+			messageMax:           interceptor.limits.limitFor(conn.Spec().Procedure),
+			streamMax:            interceptor.limits.streamMax,
+		})
+	}
+}
+
+type sizeLimitedHandlerConn struct {
+	connect.StreamingHandlerConn
+	messageMax int64
+	streamMax  int64
+	received   int64
+}
+
+// Receive reads the next message and fails once it or the stream total exceeds the limits
+func (conn *sizeLimitedHandlerConn) Receive(msg any) error {
+	if err := conn.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+
+	size, err := checkMessage(msg, conn.messageMax)
+	if err != nil {
+		return err
+	}
+
+	conn.received += size
+	if conn.streamMax > 0 && conn.received > conn.streamMax {
+		return connect.NewError(connect.CodeResourceExhausted, fmt.Errorf("stream received %d bytes, exceeding the %d byte limit", conn.received, conn.streamMax))
+	}
+	return nil
+}
+
+// MaxRequestSizeInterceptor rejects request messages whose encoded protobuf size exceeds maxBytes
+// with CodeResourceExhausted. Limits can be overridden per procedure, and streams are checked per
+// message and, with WithStreamSizeLimit, cumulatively.
+//
+// The check runs after Connect has read and decoded the message, so it keeps oversized payloads
+// away from handlers but does not bound the memory used while decoding. Pair it with
+// connect.WithReadMaxBytes on the handler (and ReadTimeout/MaxHeaderBytes on the http.Server) so
+// huge bodies are refused while reading; set that limit at least as high as the largest limit here.
+//
+// Example Usage:
+//
+//	interceptor := middleware.MaxRequestSizeInterceptor(1<<20,
+//	    WithProcedureSizeLimit("/files.v1.FileService/Upload", 16<<20),
+//	    WithStreamSizeLimit(64<<20))
+//	path, handler := filesv1connect.NewFileServiceHandler(svc, connect.WithInterceptors(interceptor))
+func (middleware *grpcAuthMiddleware) MaxRequestSizeInterceptor(maxBytes int64, opts ...RequestSizeOption) connect.Interceptor {
+	limits := &requestSizeLimits{
+		defaultMax: maxBytes,
+		procedures: map[string]int64{},
+	}
+	for _, opt := range opts {
+		opt(limits)
+	}
+
+	return &requestSizeInterceptor{limits: limits}
+}
@@ -0,0 +1,84 @@
+package unicore
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// RetryPolicy configures RetryInterceptor
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first call (default 3)
+	MaxAttempts int
+	// InitialBackoff is the base wait before the first retry, doubled on every retry (default 100ms)
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between retries (default 2s)
+	MaxBackoff time.Duration
+	// RetryableCodes are the codes that trigger a retry (default Unavailable and DeadlineExceeded)
+	RetryableCodes []connect.Code
+	// AllowedProcedures lists procedures retried even though they are not marked idempotent
+	AllowedProcedures []string
+}
+
+// withDefaults returns the policy with unset fields replaced by their defaults
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 2 * time.Second
+	}
+	if len(policy.RetryableCodes) == 0 {
+		policy.RetryableCodes = []connect.Code{connect.CodeUnavailable, connect.CodeDeadlineExceeded}
+	}
+	return policy
+}
+
+// RetryInterceptor is a client-side interceptor retrying failed unary calls with exponential
+// backoff and full jitter. Only procedures declared idempotent in their proto options
+// (idempotency_level) or listed in AllowedProcedures are retried. Retries stop early when the
+// next wait would pass the context deadline, and the last error is returned once attempts are
+// exhausted.
+//
+// Example Usage:
+//
+//	client := NewServiceClient(http.DefaultClient, url,
+//	    connect.WithInterceptors(RetryInterceptor(RetryPolicy{MaxAttempts: 4})))
+func RetryInterceptor(policy RetryPolicy) connect.UnaryInterceptorFunc {
+	policy = policy.withDefaults()
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			spec := req.Spec()
+			retryable := spec.IsClient &&
+				(spec.IdempotencyLevel != connect.IdempotencyUnknown || slices.Contains(policy.AllowedProcedures, spec.Procedure))
+
+			backoff := policy.InitialBackoff
+			for attempt := 1; ; attempt++ {
+				resp, err := next(ctx, req)
+				if err == nil || !retryable || attempt >= policy.MaxAttempts || !slices.Contains(policy.RetryableCodes, connect.CodeOf(err)) {
+					return resp, err
+				}
+
+				wait := rand.N(backoff) + 1
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+					return resp, err
+				}
+
+				select {
+				case <-ctx.Done():
+					return resp, err
+				case <-time.After(wait):
+				}
+
+				backoff = min(backoff*2, policy.MaxBackoff)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package unicore
+
+import (
+	"fmt"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+// WithSelectScope creates a GORM scope function restricting the query to the requested columns.
+// Every field must be in allowed, otherwise the query fails with CodeInvalidArgument; an empty
+// request selects every column. It composes with WithTenantScope and WithPaginationScope; columns
+// that are not selected are left at their zero value in the loaded models.
+//
+// Example Usage:
+//
+//	allowed := map[string]bool{"id": true, "name": true, "price": true}
+//	db.Scopes(WithTenantScope(ctx), WithSelectScope(req.Msg.GetFields(), allowed), WithPaginationScope(page)).Find(&products)
+func WithSelectScope(fields []string, allowed map[string]bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(fields) == 0 {
+			return db
+		}
+
+		for _, field := range fields {
+			if !allowed[field] {
+				_ = db.AddError(connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("selecting %q is not allowed", field)))
+				return db
+			}
+		}
+		return db.Select(fields)
+	}
+}
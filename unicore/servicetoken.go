@@ -0,0 +1,230 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// ServiceTokenType is the typ claim of tokens minted by a ServiceTokenAuthenticator
+	ServiceTokenType = "service"
+	// defaultServiceTokenTTL is the lifetime of minted service tokens
+	defaultServiceTokenTTL = 5 * time.Minute
+	// defaultServiceTokenLeeway is the clock skew tolerated when checking service token times
+	defaultServiceTokenLeeway = 30 * time.Second
+)
+
+// ServiceTokenClaims are the claims of a service token; the subject is the calling service
+type ServiceTokenClaims struct {
+	Typ string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+type jwtServiceTokenAuthenticator struct {
+	issuer    string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	ttl       time.Duration
+	leeway    time.Duration
+	clock     Clock
+}
+
+// ServiceTokenOption customizes the authenticator returned by NewServiceTokenAuthenticator
+type ServiceTokenOption func(*jwtServiceTokenAuthenticator)
+
+// WithServiceTokenTTL sets the lifetime of minted tokens (default 5m)
+func WithServiceTokenTTL(ttl time.Duration) ServiceTokenOption {
+	return func(authenticator *jwtServiceTokenAuthenticator) {
+		authenticator.ttl = ttl
+	}
+}
+
+// WithServiceTokenLeeway sets the clock skew tolerated on the exp, nbf and iat claims (default 30s)
+func WithServiceTokenLeeway(leeway time.Duration) ServiceTokenOption {
+	return func(authenticator *jwtServiceTokenAuthenticator) {
+		authenticator.leeway = leeway
+	}
+}
+
+// WithServiceTokenClock sets the clock used to mint and check tokens (default the system clock)
+func WithServiceTokenClock(clock Clock) ServiceTokenOption {
+	return func(authenticator *jwtServiceTokenAuthenticator) {
+		authenticator.clock = clock
+	}
+}
+
+// WithServiceTokenKeyPair signs tokens with an asymmetric key instead of the shared secret of the
+// Config, e.g. jwt.SigningMethodES256 with an *ecdsa.PrivateKey and its *ecdsa.PublicKey. Services
+// that only verify tokens may pass a nil private key; Mint then fails.
+func WithServiceTokenKeyPair(method jwt.SigningMethod, privateKey, publicKey any) ServiceTokenOption {
+	return func(authenticator *jwtServiceTokenAuthenticator) {
+		authenticator.method = method
+		authenticator.signKey = privateKey
+		authenticator.verifyKey = publicKey
+	}
+}
+
+// Issuer returns the iss claim of the tokens minted and accepted by the authenticator
+func (authenticator *jwtServiceTokenAuthenticator) Issuer() string {
+	return authenticator.issuer
+}
+
+// Mint returns a signed token identifying service, valid for the configured TTL
+func (authenticator *jwtServiceTokenAuthenticator) Mint(service string) (string, error) {
+	if service == "" {
+		return "", errors.New("service name is required")
+	}
+	if authenticator.signKey == nil {
+		return "", errors.New("service token authenticator has no signing key")
+	}
+
+	now := authenticator.clock.Now()
+	claims := ServiceTokenClaims{
+		Typ: ServiceTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    authenticator.issuer,
+			Subject:   service,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(authenticator.ttl)),
+			ID:        newRequestID(),
+		},
+	}
+	return jwt.NewWithClaims(authenticator.method, claims).SignedString(authenticator.signKey)
+}
+
+// Verify checks the signature, issuer, type and validity window of the token, tolerating the
+// configured clock skew, and returns its claims
+func (authenticator *jwtServiceTokenAuthenticator) Verify(token string) (*ServiceTokenClaims, error) {
+	claims := new(ServiceTokenClaims)
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{authenticator.method.Alg()}), jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return authenticator.verifyKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid service token: %w", err)
+	}
+
+	if claims.Issuer != authenticator.issuer {
+		return nil, fmt.Errorf("invalid service token: unexpected issuer %q", claims.Issuer)
+	}
+	if claims.Typ != ServiceTokenType {
+		return nil, fmt.Errorf("invalid service token: unexpected type %q", claims.Typ)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid service token: missing subject")
+	}
+
+	now := authenticator.clock.Now()
+	if claims.ExpiresAt == nil || now.After(claims.ExpiresAt.Add(authenticator.leeway)) {
+		return nil, errors.New("invalid service token: token is expired")
+	}
+	if claims.NotBefore != nil && now.Add(authenticator.leeway).Before(claims.NotBefore.Time) {
+		return nil, errors.New("invalid service token: token is not valid yet")
+	}
+	if claims.IssuedAt != nil && now.Add(authenticator.leeway).Before(claims.IssuedAt.Time) {
+		return nil, errors.New("invalid service token: token is issued in the future")
+	}
+
+	return claims, nil
+}
+
+// NewServiceTokenAuthenticator returns a ServiceTokenAuthenticator minting and verifying short-lived
+// JWTs for service-to-service calls, such as background jobs and cron triggers. Tokens are signed
+// with HS256 using Config.GetServiceTokenSecret, shared by every service, unless
+// WithServiceTokenKeyPair is given. issuer must be the same on both sides of a call.
+//
+// Example Usage:
+//
+//	tokens, err := NewServiceTokenAuthenticator(cfg, "unidrop-services")
+//	token, err := tokens.Mint("billing-cron")
+//	resp, err := client.Charge(WithServiceAccountContext(ctx, token, nil), connect.NewRequest(msg))
+func NewServiceTokenAuthenticator(cfg Config, issuer string, opts ...ServiceTokenOption) (ServiceTokenAuthenticator, error) {
+	if issuer == "" {
+		return nil, errors.New("service token issuer is required")
+	}
+
+	secret := cfg.GetServiceTokenSecret()
+	authenticator := &jwtServiceTokenAuthenticator{
+		issuer:    issuer,
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+		ttl:       defaultServiceTokenTTL,
+		leeway:    defaultServiceTokenLeeway,
+		clock:     systemClock{},
+	}
+	for _, opt := range opts {
+		opt(authenticator)
+	}
+
+	if authenticator.method == jwt.SigningMethodHS256 && len(secret) == 0 {
+		return nil, errors.New("service token secret is not configured")
+	}
+	if authenticator.verifyKey == nil {
+		return nil, errors.New("service token verification key is required")
+	}
+	return authenticator, nil
+}
+
+// ServiceTokenInterceptor authenticates calls carrying a service token minted by tokens. The
+// calling service is stored as the service principal together with synthetic claims (sub is the
+// service, typ is "service") so GetUserClaims keeps working, and the token interceptors skip user
+// token verification. Bearer tokens from another issuer are left to the user token interceptors,
+// while service tokens that fail verification are rejected with ErrInvalidServiceToken. Register
+// it before UnaryTokenInterceptor.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(
+//	    middleware.ServiceTokenInterceptor(tokens),
+//	    middleware.UnaryTokenInterceptor(publicRoutes...),
+//	)
+func (middleware *grpcAuthMiddleware) ServiceTokenInterceptor(tokens ServiceTokenAuthenticator) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if _, ok := ServicePrincipalFromContext(ctx); ok {
+				return next(ctx, req)
+			}
+
+			token, err := ParseBearerToken(req.Header().Get("Authorization"))
+			if err != nil || !isServiceToken(token, tokens.Issuer()) {
+				return next(ctx, req)
+			}
+
+			claims, err := tokens.Verify(token)
+			if err != nil {
+				middleware.loggR.Warn("service token rejected", zap.String("method", req.Spec().Procedure), zap.Error(err))
+				return nil, ErrInvalidServiceToken
+			}
+
+			userClaims := &UserAuthClaims{
+				Id:                claims.Subject,
+				Typ:               ServiceTokenType,
+				Iss:               claims.Issuer,
+				Jti:               claims.ID,
+				PreferredUsername: claims.Subject,
+				Exp:               claims.ExpiresAt.Unix(),
+			}
+			ctx = context.WithValue(ctx, ContextKeyServicePrincipal, claims.Subject)
+			ctx = context.WithValue(ctx, middleware.claimsKey, userClaims)
+			return next(ctx, req)
+		}
+	}
+}
+
+// isServiceToken reports whether the unverified token claims to be a service token of issuer
+func isServiceToken(token, issuer string) bool {
+	claims := new(ServiceTokenClaims)
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return false
+	}
+	return claims.Issuer == issuer && claims.Typ == ServiceTokenType
+}
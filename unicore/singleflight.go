@@ -0,0 +1,202 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+
+	"connectrpc.com/connect"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
+)
+
+// SingleFlightInterceptor coalesces concurrent identical calls to the given read-only procedures:
+// while a call is running, calls with the same procedure, tenant, user and request message wait
+// for it and share its result instead of hitting the database again. Every caller gets its own
+// copy of the response message, headers and error, so callers may modify them freely.
+//
+// Only list idempotent reads. The shared call keeps the values of the context of the caller that
+// started it but not its cancellation: it runs as long as any caller still waits for it and is
+// cancelled once all of them went away. Register it after the tenant and token interceptors, so
+// the user is known.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(
+//	    middleware.UnaryTenantInterceptor(),
+//	    middleware.UnaryTokenInterceptor(),
+//	    middleware.SingleFlightInterceptor("/catalog.v1.CatalogService/ListProducts"),
+//	)
+func (middleware *grpcAuthMiddleware) SingleFlightInterceptor(procedures ...string) connect.UnaryInterceptorFunc {
+	return middleware.singleFlight(procedures, true)
+}
+
+// SharedSingleFlightInterceptor works like SingleFlightInterceptor but also coalesces the calls of
+// different users of the same tenant. Only list procedures whose response depends on nothing but
+// the tenant and the request; users with different permissions would otherwise see each other's
+// results.
+func (middleware *grpcAuthMiddleware) SharedSingleFlightInterceptor(procedures ...string) connect.UnaryInterceptorFunc {
+	return middleware.singleFlight(procedures, false)
+}
+
+// flight tracks the callers waiting for one shared call
+type flight struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+func (middleware *grpcAuthMiddleware) singleFlight(procedures []string, perUser bool) connect.UnaryInterceptorFunc {
+	var (
+		group   singleflight.Group
+		mu      sync.Mutex
+		flights = make(map[string]*flight)
+	)
+
+	// leave removes a caller from the flight and cancels the shared call once nobody waits for it
+	leave := func(key string, f *flight) {
+		mu.Lock()
+		defer mu.Unlock()
+		f.waiters--
+		if f.waiters > 0 {
+			return
+		}
+		f.cancel()
+		if flights[key] == f {
+			delete(flights, key)
+			// Later callers must start a new call instead of joining the cancelled one.
+			group.Forget(key)
+		}
+	}
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			procedure := req.Spec().Procedure
+			if !slices.Contains(procedures, procedure) {
+				return next(ctx, req)
+			}
+
+			msg, ok := req.Any().(proto.Message)
+			if !ok {
+				return next(ctx, req)
+			}
+			body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+			if err != nil {
+				return next(ctx, req)
+			}
+
+			tenantID := tenantFromContext(ctx)
+			if tenantID == "" {
+				tenantID = req.Header().Get(XTenantKey)
+			}
+			key := procedure + "\x00" + tenantID + "\x00"
+			if perUser {
+				key += middleware.singleFlightUser(ctx, req)
+			}
+			key += "\x00" + string(body)
+
+			// Joining the flight and the call happens under the lock, so every caller waiting for a
+			// call is counted on the flight whose context the call runs with.
+			mu.Lock()
+			f, ok := flights[key]
+			if !ok {
+				sharedCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+				f = &flight{ctx: sharedCtx, cancel: cancel}
+				flights[key] = f
+			}
+			f.waiters++
+			results := group.DoChan(key, func() (any, error) {
+				resp, err := next(f.ctx, req)
+				mu.Lock()
+				if flights[key] == f {
+					delete(flights, key)
+				}
+				mu.Unlock()
+				if err != nil {
+					// The error is copied by every waiter, so share one whose metadata is not
+					// created lazily.
+					var connectErr *connect.Error
+					if errors.As(err, &connectErr) {
+						return nil, cloneError(connectErr)
+					}
+					return nil, err
+				}
+				// Initialize the lazily created maps before the response is shared.
+				resp.Header()
+				resp.Trailer()
+				return resp, nil
+			})
+			mu.Unlock()
+			defer leave(key, f)
+
+			select {
+			case <-ctx.Done():
+				return nil, contextError(ctx.Err())
+			case result := <-results:
+				if result.Err != nil {
+					var connectErr *connect.Error
+					if errors.As(result.Err, &connectErr) {
+						return nil, cloneError(connectErr)
+					}
+					return nil, result.Err
+				}
+				resp, _ := result.Val.(connect.AnyResponse)
+				return cloneResponse(resp), nil
+			}
+		}
+	}
+}
+
+// singleFlightUser identifies the caller by the subject of the verified token, or by the raw
+// authorization header when no token was verified yet
+func (middleware *grpcAuthMiddleware) singleFlightUser(ctx context.Context, req connect.AnyRequest) string {
+	if claims := middleware.claims(ctx); claims != nil {
+		return "sub:" + claims.Id
+	}
+	return "auth:" + req.Header().Get("Authorization")
+}
+
+// contextError converts the error of a done context into the matching connect error
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, err)
+	}
+	return connect.NewError(connect.CodeCanceled, err)
+}
+
+// cloneResponse returns a deep copy of a *connect.Response holding a protobuf message, with its
+// own headers and trailers. Other responses are returned as is.
+func cloneResponse(resp connect.AnyResponse) connect.AnyResponse {
+	if resp == nil {
+		return nil
+	}
+	msg, ok := resp.Any().(proto.Message)
+	if !ok {
+		return resp
+	}
+
+	respType := reflect.TypeOf(resp)
+	if respType.Kind() != reflect.Ptr || respType.Elem().Kind() != reflect.Struct {
+		return resp
+	}
+	cloned := reflect.New(respType.Elem())
+	msgField := cloned.Elem().FieldByName("Msg")
+	if !msgField.IsValid() || !msgField.CanSet() {
+		return resp
+	}
+	msgField.Set(reflect.ValueOf(proto.Clone(msg)))
+
+	clonedResp, ok := cloned.Interface().(connect.AnyResponse)
+	if !ok {
+		return resp
+	}
+	for key, values := range resp.Header() {
+		clonedResp.Header()[key] = slices.Clone(values)
+	}
+	for key, values := range resp.Trailer() {
+		clonedResp.Trailer()[key] = slices.Clone(values)
+	}
+	return clonedResp
+}
@@ -0,0 +1,317 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// singleFlightServer serves a handler counting its executions behind a single flight interceptor.
+// The handler blocks until release is closed, so concurrent calls overlap, and then fails with
+// fail if it is set.
+type singleFlightServer struct {
+	client     *connect.Client[commonv1.PageRequest, emptypb.Empty]
+	executions atomic.Int32
+	arrived    atomic.Int32
+	left       atomic.Int32
+	release    chan struct{}
+	fail       error
+}
+
+func newSingleFlightServer(t *testing.T, singleFlight connect.Interceptor) *singleFlightServer {
+	t.Helper()
+
+	server := &singleFlightServer{release: make(chan struct{})}
+	// countArrivals runs before the single flight interceptor and, like interceptors adding
+	// headers do, writes to the metadata of the errors it gets back.
+	countArrivals := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			server.arrived.Add(1)
+			resp, err := next(ctx, req)
+			server.left.Add(1)
+			if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+				connectErr.Meta().Set("X-Caller", req.Header().Get("X-Caller"))
+			}
+			return resp, err
+		}
+	})
+	server.client = newTestClient(t, func(ctx context.Context, _ *connect.Request[commonv1.PageRequest]) (*connect.Response[emptypb.Empty], error) {
+		server.executions.Add(1)
+		select {
+		case <-server.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if server.fail != nil {
+			return nil, server.fail
+		}
+		resp := connect.NewResponse(&emptypb.Empty{})
+		resp.Header().Set("X-Served", "true")
+		return resp, nil
+	}, countArrivals, singleFlight)
+	return server
+}
+
+// callConcurrently issues the calls in parallel, waits until all reached the server and then
+// releases the handler
+func (server *singleFlightServer) callConcurrently(t *testing.T, calls []*connect.Request[commonv1.PageRequest]) []error {
+	t.Helper()
+
+	errs := make([]error, len(calls))
+	var wg sync.WaitGroup
+	for i, req := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := server.client.CallUnary(context.Background(), req)
+			if err == nil && resp.Header().Get("X-Served") != "true" {
+				t.Error("expected every caller to get the response headers")
+			}
+			errs[i] = err
+		}()
+	}
+
+	waitFor(t, func() bool { return int(server.arrived.Load()) == len(calls) })
+	// Give the last arrivals time to join the running call before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(server.release)
+	wg.Wait()
+	return errs
+}
+
+// waitFor polls condition until it holds and fails the test after 5 seconds
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func pageCall(tenantID string, page int32) *connect.Request[commonv1.PageRequest] {
+	req := connect.NewRequest(&commonv1.PageRequest{Page: page, Limit: 20})
+	req.Header().Set(XTenantKey, tenantID)
+	return req
+}
+
+// userPageCall is a pageCall authorized with the given raw authorization header
+func userPageCall(tenantID, authorization string) *connect.Request[commonv1.PageRequest] {
+	req := pageCall(tenantID, 1)
+	req.Header().Set("Authorization", authorization)
+	return req
+}
+
+func TestSingleFlightInterceptorCoalescesIdenticalCalls(t *testing.T) {
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor(testProcedure))
+
+	const callers = 10
+	calls := make([]*connect.Request[commonv1.PageRequest], callers)
+	for i := range calls {
+		calls[i] = pageCall("acme", 1)
+	}
+	for _, err := range server.callConcurrently(t, calls) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := server.executions.Load(); got != 1 {
+		t.Fatalf("expected the handler to run once for %d identical calls, ran %d times", callers, got)
+	}
+}
+
+func TestSingleFlightInterceptorKeysByTenantAndMessage(t *testing.T) {
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor(testProcedure))
+
+	calls := []*connect.Request[commonv1.PageRequest]{
+		pageCall("acme", 1),
+		pageCall("acme", 1),
+		pageCall("other", 1),
+		pageCall("acme", 2),
+	}
+	for _, err := range server.callConcurrently(t, calls) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := server.executions.Load(); got != 3 {
+		t.Fatalf("expected one execution per tenant and message, got %d", got)
+	}
+}
+
+func TestSingleFlightInterceptorKeysByUser(t *testing.T) {
+	calls := func() []*connect.Request[commonv1.PageRequest] {
+		return []*connect.Request[commonv1.PageRequest]{
+			userPageCall("acme", "Bearer alice"),
+			userPageCall("acme", "Bearer alice"),
+			userPageCall("acme", "Bearer bob"),
+		}
+	}
+
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor(testProcedure))
+	for _, err := range server.callConcurrently(t, calls()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := server.executions.Load(); got != 2 {
+		t.Fatalf("expected one execution per user, got %d", got)
+	}
+
+	shared := newSingleFlightServer(t, newTestMiddleware().SharedSingleFlightInterceptor(testProcedure))
+	for _, err := range shared.callConcurrently(t, calls()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := shared.executions.Load(); got != 1 {
+		t.Fatalf("expected the users to share one execution, got %d", got)
+	}
+}
+
+func TestSingleFlightInterceptorKeysByClaimsSubject(t *testing.T) {
+	middleware := newTestMiddleware()
+	alice := context.WithValue(context.Background(), middleware.claimsKey, &UserAuthClaims{Id: "alice"})
+	bob := context.WithValue(context.Background(), middleware.claimsKey, &UserAuthClaims{Id: "bob"})
+	req := userPageCall("acme", "Bearer token")
+
+	if middleware.singleFlightUser(alice, req) == middleware.singleFlightUser(bob, req) {
+		t.Fatal("expected different subjects to get different keys")
+	}
+	if middleware.singleFlightUser(alice, req) == middleware.singleFlightUser(context.Background(), req) {
+		t.Fatal("expected a verified subject to differ from the raw authorization header")
+	}
+}
+
+func TestSingleFlightInterceptorClonesErrorPerCaller(t *testing.T) {
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor(testProcedure))
+	server.fail = ErrTenantMismatch
+
+	const callers = 8
+	calls := make([]*connect.Request[commonv1.PageRequest], callers)
+	for i := range calls {
+		calls[i] = pageCall("acme", 1)
+		calls[i].Header().Set("X-Caller", strconv.Itoa(i))
+	}
+	for i, err := range server.callConcurrently(t, calls) {
+		assertCode(t, err, connect.CodeOf(ErrTenantMismatch))
+		var connectErr *connect.Error
+		if !errors.As(err, &connectErr) || connectErr.Meta().Get("X-Caller") != strconv.Itoa(i) {
+			t.Fatalf("expected caller %d to get its own error metadata, got %v", i, err)
+		}
+	}
+
+	if got := server.executions.Load(); got != 1 {
+		t.Fatalf("expected the failing handler to run once, ran %d times", got)
+	}
+	if got := ErrTenantMismatch.Meta().Get("X-Caller"); got != "" {
+		t.Fatalf("expected the shared error to be untouched, got X-Caller %q", got)
+	}
+}
+
+func TestSingleFlightInterceptorDetachesCancellation(t *testing.T) {
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor(testProcedure))
+
+	// The first caller starts the shared call and gives up while it runs; the second still gets
+	// the response.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := server.client.CallUnary(ctx, pageCall("acme", 1))
+		first <- err
+	}()
+	waitFor(t, func() bool { return server.executions.Load() == 1 })
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := server.client.CallUnary(context.Background(), pageCall("acme", 1))
+		second <- err
+	}()
+	waitFor(t, func() bool { return server.arrived.Load() == 2 })
+	// Give the second caller time to join the running call.
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assertCode(t, <-first, connect.CodeCanceled)
+	waitFor(t, func() bool { return server.left.Load() >= 1 })
+	close(server.release)
+	if err := <-second; err != nil {
+		t.Fatalf("expected the waiting caller to succeed, got %v", err)
+	}
+	if got := server.executions.Load(); got != 1 {
+		t.Fatalf("expected one execution, got %d", got)
+	}
+}
+
+func TestSingleFlightInterceptorCancelsAbandonedCalls(t *testing.T) {
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor(testProcedure))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := server.client.CallUnary(ctx, pageCall("acme", 1))
+		done <- err
+	}()
+	waitFor(t, func() bool { return server.executions.Load() == 1 })
+	cancel()
+	assertCode(t, <-done, connect.CodeCanceled)
+
+	// Once its only caller left, the shared call is cancelled and a new caller starts a new one.
+	close(server.release)
+	if _, err := server.client.CallUnary(context.Background(), pageCall("acme", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.executions.Load(); got != 2 {
+		t.Fatalf("expected a new execution after the abandoned one, got %d", got)
+	}
+}
+
+func TestSingleFlightInterceptorIgnoresOtherProcedures(t *testing.T) {
+	server := newSingleFlightServer(t, newTestMiddleware().SingleFlightInterceptor("/test.v1.TestService/List"))
+
+	calls := []*connect.Request[commonv1.PageRequest]{pageCall("acme", 1), pageCall("acme", 1), pageCall("acme", 1)}
+	for _, err := range server.callConcurrently(t, calls) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := server.executions.Load(); got != 3 {
+		t.Fatalf("expected procedures outside the allowlist to run every time, got %d executions", got)
+	}
+}
+
+func TestCloneResponse(t *testing.T) {
+	resp := connect.NewResponse(&commonv1.PageRequest{Page: 1, Sort: "name"})
+	resp.Header().Set("X-Served", "true")
+	resp.Trailer().Set("X-Count", "1")
+
+	cloned, ok := cloneResponse(resp).(*connect.Response[commonv1.PageRequest])
+	if !ok {
+		t.Fatal("expected a *connect.Response[commonv1.PageRequest]")
+	}
+	cloned.Msg.Page = 2
+	cloned.Msg.Sort = "price"
+	cloned.Header().Set("X-Served", "false")
+	cloned.Trailer().Add("X-Count", "2")
+
+	if resp.Msg.GetPage() != 1 || resp.Msg.GetSort() != "name" {
+		t.Fatalf("expected the shared message to be untouched, got %v", resp.Msg)
+	}
+	if resp.Header().Get("X-Served") != "true" || len(resp.Trailer().Values("X-Count")) != 1 {
+		t.Fatal("expected the shared headers and trailers to be untouched")
+	}
+}
@@ -0,0 +1,109 @@
+package unicore
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SortSpec is one column of a multi-column sort
+type SortSpec struct {
+	Field     string
+	Direction commonv1.SortDirection
+}
+
+// ParseSortSpecs parses a comma separated sort parameter such as "created_at desc, name asc". The
+// direction is matched case-insensitively and defaults to ascending when omitted.
+func ParseSortSpecs(sort string) ([]SortSpec, error) {
+	var specs []SortSpec
+	for _, part := range strings.Split(sort, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid sort %q", strings.TrimSpace(part)))
+		}
+
+		spec := SortSpec{Field: fields[0], Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				spec.Direction = commonv1.SortDirection_SORT_DIRECTION_DESC
+			default:
+				return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid sort direction %q", fields[1]))
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// WithMultiSortScope orders by every spec in turn within a single ORDER BY clause, falling back to
+// "created_at desc" when specs is empty. Every field must be in allowed, otherwise the query fails
+// with CodeInvalidArgument; column names are quoted by GORM so they cannot inject SQL.
+//
+// Example Usage:
+//
+//	specs, err := ParseSortSpecs("created_at desc, name asc")
+//	db.Scopes(WithMultiSortScope(specs, map[string]bool{"created_at": true, "name": true})).Find(&records)
+func WithMultiSortScope(specs []SortSpec, allowed map[string]bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(specs) == 0 {
+			return db.Order(clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "created_at"}, Desc: true}}})
+		}
+
+		columns := make([]clause.OrderByColumn, 0, len(specs))
+		for _, spec := range specs {
+			if !allowed[spec.Field] {
+				_ = db.AddError(connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("sorting by %q is not allowed", spec.Field)))
+				return db
+			}
+			columns = append(columns, clause.OrderByColumn{
+				Column: clause.Column{Name: spec.Field},
+				Desc:   spec.Direction == commonv1.SortDirection_SORT_DIRECTION_DESC,
+			})
+		}
+
+		return db.Order(clause.OrderBy{Columns: columns})
+	}
+}
+
+// WithCaseInsensitiveSortScope works like WithPaginationScope, but sorts case-insensitively when
+// the requested sort field is one of textFields, so "alice" comes before "Bob". Other fields, such
+// as numbers and dates, are sorted as before; rows equal apart from case keep a case-sensitive
+// order. The comparison depends on the driver:
+//   - Postgres uses the ICU root collation (COLLATE "und-x-icu"), which needs a server built with ICU
+//   - SQLite uses COLLATE NOCASE
+//   - Other databases compare LOWER(column)
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithCaseInsensitiveSortScope(req.GetPage(), "name", "email")).Find(&customers)
+func WithCaseInsensitiveSortScope(pagination *commonv1.PageRequest, textFields ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		normalized := NormalizePageRequest(pagination, DefaultMaxPageLimit)
+		if slices.Contains(textFields, normalized.GetSort()) {
+			column := db.Statement.Quote(normalized.GetSort())
+			switch db.Dialector.Name() {
+			case "postgres":
+				column += ` COLLATE "und-x-icu"`
+			case "sqlite":
+				column += " COLLATE NOCASE"
+			default:
+				column = "LOWER(" + column + ")"
+			}
+			db = db.Order(clause.OrderByColumn{
+				Column: clause.Column{Name: column, Raw: true},
+				Desc:   normalized.GetDirection() == commonv1.SortDirection_SORT_DIRECTION_DESC,
+			})
+		}
+		return WithPaginationScope(pagination)(db)
+	}
+}
@@ -0,0 +1,92 @@
+package unicore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"gorm.io/gorm"
+)
+
+func seedMixedCaseNames(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := openTestDB(t, &exportRow{})
+	rows := []exportRow{{Name: "bob", CreatedAt: 3}, {Name: "Alice", CreatedAt: 1}, {Name: "dave", CreatedAt: 4}, {Name: "Carol", CreatedAt: 2}}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func sortedNames(t *testing.T, db *gorm.DB, scope func(*gorm.DB) *gorm.DB) []string {
+	t.Helper()
+
+	var rows []exportRow
+	if err := db.Scopes(scope).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, row.Name)
+	}
+	return names
+}
+
+func TestWithCaseInsensitiveSortScope(t *testing.T) {
+	db := seedMixedCaseNames(t)
+	asc := &commonv1.PageRequest{Sort: "name", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+	desc := &commonv1.PageRequest{Sort: "name", Direction: commonv1.SortDirection_SORT_DIRECTION_DESC}
+
+	if got, want := sortedNames(t, db, WithCaseInsensitiveSortScope(asc, "name")), []string{"Alice", "bob", "Carol", "dave"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ascending: got %v, want %v", got, want)
+	}
+	if got, want := sortedNames(t, db, WithCaseInsensitiveSortScope(desc, "name")), []string{"dave", "Carol", "bob", "Alice"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("descending: got %v, want %v", got, want)
+	}
+	// Without opting the field in, names keep their case-sensitive order.
+	if got, want := sortedNames(t, db, WithCaseInsensitiveSortScope(asc, "email")), []string{"Alice", "Carol", "bob", "dave"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("not opted in: got %v, want %v", got, want)
+	}
+}
+
+func TestWithCaseInsensitiveSortScopeKeepsOtherFields(t *testing.T) {
+	db := seedMixedCaseNames(t)
+
+	page := &commonv1.PageRequest{Sort: "created_at", Direction: commonv1.SortDirection_SORT_DIRECTION_ASC}
+	if got, want := sortedNames(t, db, WithCaseInsensitiveSortScope(page, "name")), []string{"Alice", "Carol", "bob", "dave"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Scopes(WithCaseInsensitiveSortScope(page, "name")).Find(&[]exportRow{})
+	})
+	if strings.Contains(sql, "COLLATE") {
+		t.Fatalf("expected a plain sort on created_at, got %s", sql)
+	}
+}
+
+func TestWithCaseInsensitiveSortScopeDialects(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	page := &commonv1.PageRequest{Sort: "name"}
+
+	for name, want := range map[string]string{
+		"sqlite":   "ORDER BY `name` COLLATE NOCASE",
+		"postgres": "ORDER BY `name` COLLATE \"und-x-icu\"",
+		"mysql":    "ORDER BY LOWER(`name`)",
+	} {
+		t.Run(name, func(t *testing.T) {
+			renamed, err := gorm.Open(renamedDialector{Dialector: db.Dialector, name: name}, &gorm.Config{DryRun: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sql := renamed.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return tx.Scopes(WithCaseInsensitiveSortScope(page, "name")).Find(&[]exportRow{})
+			})
+			if !strings.Contains(sql, want) {
+				t.Fatalf("expected %s, got %s", want, sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.uber.org/zap"
+)
+
+type streamLoggingInterceptor struct {
+	middleware *grpcAuthMiddleware
+}
+
+// WrapUnary leaves unary calls to LoggingUnaryInterceptor
+func (interceptor *streamLoggingInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return next
+}
+
+// WrapStreamingClient leaves client streams unchanged
+func (interceptor *streamLoggingInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler logs the opening and closing of the stream together with its message counts
+func (interceptor *streamLoggingInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	middleware := interceptor.middleware
+
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		start := time.Now()
+		fullMethod := conn.Spec().Procedure
+		logger := middleware.loggR.With(
+			zap.String("method", fullMethod),
+			zap.String("stream_type", conn.Spec().StreamType.String()),
+		)
+		if requestID := middleware.contextHelper.GetRequestID(ctx); requestID != "" {
+			logger = logger.With(zap.String("request_id", requestID))
+		}
+		if tenantID := tenantFromContext(ctx); tenantID != "" {
+			logger = logger.With(zap.String("tenant", tenantID))
+		}
+		if claims := middleware.claims(ctx); claims != nil {
+			logger = logger.With(zap.String("user", claims.Id))
+		}
+
+		logSuccess := middleware.shouldLog(fullMethod)
+		if logSuccess {
+			logger.Info("gRPC stream opened")
+		}
+
+		logged := &loggingHandlerConn{
+			StreamingHandlerConn: conn,
+			middleware:           middleware,
+			logger:               logger,
+		}
+		err := next(ctx, logged)
+		fields := []zap.Field{
+			zap.Uint64("messages_received", logged.received.Load()),
+			zap.Uint64("messages_sent", logged.sent.Load()),
+			zap.Duration("duration", time.Since(start)),
+		}
+
+		if err != nil {
+			logFailure(logger, "gRPC stream failed", err, fields...)
+		} else if logSuccess {
+			logger.Info("gRPC stream closed", fields...)
+		}
+
+		return err
+	}
+}
+
+type loggingHandlerConn struct {
+	connect.StreamingHandlerConn
+	middleware *grpcAuthMiddleware
+	logger     *zap.Logger
+	received   atomic.Uint64
+	sent       atomic.Uint64
+}
+
+// Receive counts received messages and logs them when LogStreamMessages is set
+func (conn *loggingHandlerConn) Receive(msg any) error {
+	err := conn.StreamingHandlerConn.Receive(msg)
+	if err != nil {
+		return err
+	}
+
+	conn.received.Add(1)
+	if conn.middleware.loggingOptions.LogStreamMessages && conn.middleware.bodyLoggingEnabled() {
+		conn.logger.Info("gRPC stream message received", conn.middleware.bodyField("request", msg))
+	}
+	return nil
+}
+
+// Send counts sent messages and logs them when LogStreamMessages is set
+func (conn *loggingHandlerConn) Send(msg any) error {
+	if err := conn.StreamingHandlerConn.Send(msg); err != nil {
+		if !errors.Is(err, io.EOF) {
+			conn.logger.Warn("failed to send gRPC stream message", zap.Error(err))
+		}
+		return err
+	}
+
+	conn.sent.Add(1)
+	if conn.middleware.loggingOptions.LogStreamMessages && conn.middleware.bodyLoggingEnabled() {
+		conn.logger.Info("gRPC stream message sent", conn.middleware.bodyField("response", msg))
+	}
+	return nil
+}
+
+// LoggingStreamInterceptor logs streaming calls: the stream opening, then on close the number of
+// messages received and sent, the duration and the error, if any. Payloads are only logged,
+// sanitized and size-bounded like unary bodies, when LoggingOptions.LogStreamMessages is set and
+// debug logging is enabled. The skip list and sampling rates apply to successful streams; failures
+// are always logged.
+//
+// Example Usage:
+//
+//	path, handler := chatv1connect.NewChatServiceHandler(svc,
+//	    connect.WithInterceptors(middleware.LoggingStreamInterceptor()))
+func (middleware *grpcAuthMiddleware) LoggingStreamInterceptor() connect.Interceptor {
+	return &streamLoggingInterceptor{middleware: middleware}
+}
@@ -0,0 +1,279 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"connectrpc.com/connect"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultBulkBatchSize is the number of rows inserted per statement by BulkCreate
+const defaultBulkBatchSize = 100
+
+// Tenantable is implemented by models that can be stamped with a tenant id. Models that do not
+// implement it are stamped through an exported string TenantID field instead.
+type Tenantable interface {
+	SetTenantID(tenantID string)
+}
+
+type bulkOptions struct {
+	batchSize int
+}
+
+// BulkOption customizes BulkCreate
+type BulkOption func(*bulkOptions)
+
+// WithBatchSize sets the number of rows inserted per statement (default 100)
+func WithBatchSize(batchSize int) BulkOption {
+	return func(options *bulkOptions) {
+		options.batchSize = batchSize
+	}
+}
+
+// tenantFromContext returns the tenant id stored by UnaryTenantInterceptor, or an empty string
+func tenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(XTenantKey).(string)
+	return tenantID
+}
+
+// stampTenant sets the tenant id on a record through Tenantable or its TenantID field
+func stampTenant(record any, tenantID string) error {
+	value := reflect.ValueOf(record)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		if tenantable, ok := value.Interface().(Tenantable); ok {
+			tenantable.SetTenantID(tenantID)
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct {
+		if field := value.FieldByName("TenantID"); field.IsValid() && field.CanSet() && field.Kind() == reflect.String {
+			field.SetString(tenantID)
+			return nil
+		}
+	}
+
+	return connect.NewError(connect.CodeInternal, fmt.Errorf("model %T has no tenant field", record))
+}
+
+// RegisterTenantCreateCallback registers a GORM callback filling the tenant_id column of created
+// records with the tenant of the statement context, so creates cannot forget it. A record already
+// carrying another tenant fails the create with ErrCrossTenantWrite, so a request cannot write
+// into another tenant. Models without a tenant_id column, creates from maps and contexts without
+// a tenant are left alone; queries must use WithContext for the tenant to be found.
+//
+// Example Usage:
+//
+//	if err := RegisterTenantCreateCallback(db, NewContextHelper(authenticator)); err != nil {
+//	    log.Fatal(err)
+//	}
+func RegisterTenantCreateCallback(db *gorm.DB, helper ContextHelper) error {
+	return db.Callback().Create().Before("gorm:create").Register("unicore:tenant_create", func(tx *gorm.DB) {
+		tenantID, err := helper.GetTenantFromContext(tx.Statement.Context)
+		if err != nil {
+			return
+		}
+		stampCreateTenant(tx, tenantID)
+	})
+}
+
+// stampCreateTenant sets tenant_id on every record being inserted that has none, and rejects
+// records of another tenant
+func stampCreateTenant(tx *gorm.DB, tenantID string) {
+	stmt := tx.Statement
+	if tx.Error != nil || stmt.Schema == nil {
+		return
+	}
+
+	field := stmt.Schema.LookUpField("tenant_id")
+	if field == nil {
+		return
+	}
+
+	stamp := func(record reflect.Value) {
+		current, _ := field.ValueOf(stmt.Context, record)
+		// Dereference pointer columns such as *string so the value, not its address, is compared
+		value := reflect.Indirect(reflect.ValueOf(current))
+		if !value.IsValid() || value.IsZero() {
+			tx.AddError(field.Set(stmt.Context, record, tenantID))
+			return
+		}
+		if fmt.Sprint(value.Interface()) != tenantID {
+			tx.AddError(ErrCrossTenantWrite)
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if record := reflect.Indirect(stmt.ReflectValue.Index(i)); record.Kind() == reflect.Struct {
+				stamp(record)
+			}
+		}
+	case reflect.Struct:
+		stamp(stmt.ReflectValue)
+	}
+}
+
+// BulkCreate stamps every record with the tenant id from ctx and inserts them in batches inside a
+// single transaction, returning the number of rows inserted. It fails when ctx carries no tenant or
+// when the model cannot be stamped (it neither implements Tenantable nor has a TenantID field).
+//
+// Example Usage:
+//
+//	count, err := BulkCreate(ctx, db, products, WithBatchSize(500))
+func BulkCreate[T any](ctx context.Context, db *gorm.DB, records []T, opts ...BulkOption) (int64, error) {
+	options := bulkOptions{batchSize: defaultBulkBatchSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return 0, ErrMissingTenant
+	}
+
+	for i := range records {
+		// Prefer the addressable element so value-typed models are stamped in place.
+		if err := stampTenant(&records[i], tenantID); err != nil {
+			return 0, err
+		}
+	}
+
+	var inserted int64
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.CreateInBatches(records, options.batchSize)
+		inserted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// tenantSchemaPattern restricts tenant ids usable as schema names
+var tenantSchemaPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// WithTenantSchemaScope creates a GORM scope function for schema-per-tenant deployments. Instead of
+// filtering on a tenant_id column, it qualifies the model's table with the schema named after the
+// tenant in ctx, e.g. "acme"."orders", quoting both names for the driver. A missing tenant adds
+// ErrMissingTenant to the query, and tenant ids containing anything but letters, digits, "_" or
+// "-" add ErrInvalidTenant rather than being interpolated.
+//
+// Trade-offs compared to WithTenantScope:
+//   - Isolation is enforced by the schema, so a forgotten scope on a join cannot leak rows, but every
+//     table in a query (including joins and raw SQL) must be qualified the same way
+//   - Migrations must be applied to every tenant schema
+//   - Connections are shared; use a TenantResolver instead when tenants need separate databases or
+//     a per-connection search_path
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantSchemaScope(ctx)).Find(&orders)
+func WithTenantSchemaScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		tenantID := tenantFromContext(ctx)
+		if tenantID == "" {
+			_ = db.AddError(ErrMissingTenant)
+			return db
+		}
+		if !tenantSchemaPattern.MatchString(tenantID) {
+			_ = db.AddError(ErrInvalidTenant)
+			return db
+		}
+
+		model := db.Statement.Model
+		if model == nil {
+			model = db.Statement.Dest
+		}
+		table := db.Statement.Table
+		if table == "" {
+			if err := db.Statement.Parse(model); err != nil {
+				_ = db.AddError(err)
+				return db
+			}
+			table = db.Statement.Schema.Table
+		}
+
+		// Set the expression directly, since Table would quote an already quoted name again.
+		db.Statement.TableExpr = &clause.Expr{SQL: db.Statement.Quote(tenantID) + "." + db.Statement.Quote(table)}
+		db.Statement.Table = table
+		return db
+	}
+}
+
+type cachedTenantResolver struct {
+	open    func(tenantID string) (*gorm.DB, error)
+	opening singleflight.Group
+
+	mu  sync.RWMutex
+	dbs map[string]*gorm.DB
+}
+
+// Resolve returns the connection of the tenant in ctx, opening it on first use. Connections are
+// opened outside the lock, so a slow tenant does not hold up the others, and concurrent first
+// requests of a tenant share a single open.
+func (resolver *cachedTenantResolver) Resolve(ctx context.Context) (*gorm.DB, error) {
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return nil, ErrMissingTenant
+	}
+
+	resolver.mu.RLock()
+	db, ok := resolver.dbs[tenantID]
+	resolver.mu.RUnlock()
+	if ok {
+		return db.WithContext(ctx), nil
+	}
+
+	opened, err, _ := resolver.opening.Do(tenantID, func() (any, error) {
+		resolver.mu.RLock()
+		db, ok := resolver.dbs[tenantID]
+		resolver.mu.RUnlock()
+		if ok {
+			return db, nil
+		}
+
+		db, err := resolver.open(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		resolver.mu.Lock()
+		resolver.dbs[tenantID] = db
+		resolver.mu.Unlock()
+		return db, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return opened.(*gorm.DB).WithContext(ctx), nil
+}
+
+// NewTenantResolver returns a TenantResolver that opens a connection per tenant with open and
+// caches it for later requests. open typically points the DSN at the tenant's database or sets its
+// search_path, e.g. "search_path=" + tenantID for Postgres.
+//
+// Example Usage:
+//
+//	resolver := NewTenantResolver(func(tenantID string) (*gorm.DB, error) {
+//	    return gorm.Open(postgres.Open(baseDSN+" search_path="+tenantID), cfg.GetGormConfig())
+//	})
+//	db, err := resolver.Resolve(ctx)
+func NewTenantResolver(open func(tenantID string) (*gorm.DB, error)) TenantResolver {
+	return &cachedTenantResolver{
+		open: open,
+		dbs:  map[string]*gorm.DB{},
+	}
+}
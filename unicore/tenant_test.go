@@ -0,0 +1,114 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// nullableTenantRow keeps its tenant in a nullable column
+type nullableTenantRow struct {
+	ID       uint
+	TenantID *string
+	Name     string
+}
+
+func newTenantCreateTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := openTestDB(t, &exportRow{}, &nullableTenantRow{}, &unauditedRow{})
+	if err := RegisterTenantCreateCallback(db, NewContextHelper(nil)); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRegisterTenantCreateCallbackStampsTenant(t *testing.T) {
+	db := newTenantCreateTestDB(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	row := exportRow{Name: "single"}
+	if err := db.WithContext(ctx).Create(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.TenantID != "acme" {
+		t.Fatalf("expected the tenant to be stamped, got %q", row.TenantID)
+	}
+
+	rows := []*exportRow{{Name: "first"}, {Name: "second", TenantID: "acme"}}
+	if err := db.WithContext(ctx).Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	db.Model(&exportRow{}).Where("tenant_id = ?", "acme").Count(&count)
+	if count != 3 {
+		t.Fatalf("expected 3 acme rows, got %d", count)
+	}
+}
+
+func TestRegisterTenantCreateCallbackRejectsOtherTenant(t *testing.T) {
+	db := newTenantCreateTestDB(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	err := db.WithContext(ctx).Create(&exportRow{TenantID: "victim", Name: "spoofed"}).Error
+	if !errors.Is(err, ErrCrossTenantWrite) {
+		t.Fatalf("expected ErrCrossTenantWrite, got %v", err)
+	}
+
+	// One spoofed record fails the whole batch.
+	err = db.WithContext(ctx).Create(&[]exportRow{{Name: "mine"}, {TenantID: "victim", Name: "spoofed"}}).Error
+	if !errors.Is(err, ErrCrossTenantWrite) {
+		t.Fatalf("expected ErrCrossTenantWrite for the batch, got %v", err)
+	}
+
+	var count int64
+	db.Model(&exportRow{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected nothing to be created, found %d rows", count)
+	}
+}
+
+func TestRegisterTenantCreateCallbackPointerColumn(t *testing.T) {
+	db := newTenantCreateTestDB(t)
+	ctx := withTenant(context.Background(), "acme")
+	acme, victim, empty := "acme", "victim", ""
+
+	for name, tenantID := range map[string]*string{"nil": nil, "empty": &empty, "same": &acme} {
+		t.Run(name, func(t *testing.T) {
+			row := nullableTenantRow{TenantID: tenantID, Name: name}
+			if err := db.WithContext(ctx).Create(&row).Error; err != nil {
+				t.Fatal(err)
+			}
+			if row.TenantID == nil || *row.TenantID != "acme" {
+				t.Fatalf("expected the tenant to be stamped, got %v", row.TenantID)
+			}
+		})
+	}
+
+	err := db.WithContext(ctx).Create(&nullableTenantRow{TenantID: &victim}).Error
+	if !errors.Is(err, ErrCrossTenantWrite) {
+		t.Fatalf("expected ErrCrossTenantWrite, got %v", err)
+	}
+}
+
+func TestRegisterTenantCreateCallbackSkips(t *testing.T) {
+	db := newTenantCreateTestDB(t)
+
+	// Without a tenant in the context, records are created as given.
+	if err := db.Create(&exportRow{TenantID: "job", Name: "import"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Models without a tenant column are left alone.
+	if err := db.WithContext(withTenant(context.Background(), "acme")).Create(&unauditedRow{ID: "1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var row exportRow
+	db.First(&row)
+	if row.TenantID != "job" {
+		t.Fatalf("expected the given tenant to be kept, got %q", row.TenantID)
+	}
+}
@@ -0,0 +1,82 @@
+package unicore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+// knownTenantTTL is how long TenantValidationInterceptor trusts that a tenant exists
+const knownTenantTTL = 30 * time.Second
+
+type gormTenantRegistry struct {
+	db     *gorm.DB
+	table  string
+	column string
+}
+
+// Exists reports whether a row with the tenant id exists in the tenants table
+func (registry *gormTenantRegistry) Exists(ctx context.Context, tenantID string) (bool, error) {
+	var count int64
+	err := registry.db.WithContext(ctx).Table(registry.table).Where(registry.column+" = ?", tenantID).Limit(1).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// NewGormTenantRegistry returns a TenantRegistry looking tenants up in a database table.
+//
+// Parameters:
+//   - table: The tenants table, defaults to "tenants" when empty
+//   - column: The tenant id column, defaults to "id" when empty
+//
+// Example Usage:
+//
+//	interceptor := middleware.TenantValidationInterceptor(NewGormTenantRegistry(db, "", ""))
+func NewGormTenantRegistry(db *gorm.DB, table, column string) TenantRegistry {
+	if table == "" {
+		table = "tenants"
+	}
+	if column == "" {
+		column = "id"
+	}
+	return &gormTenantRegistry{db: db, table: table, column: column}
+}
+
+// TenantValidationInterceptor rejects requests for tenants unknown to the registry with
+// ErrUnknownTenant before the handler runs. The tenant is read from the context, so register it
+// after UnaryTenantInterceptor; requests without a tenant are left to that interceptor. Existing
+// tenants are remembered for 30 seconds on the WithMiddlewareClock clock, so a deleted tenant may
+// be served briefly; unknown tenants are looked up again on every request.
+func (middleware *grpcAuthMiddleware) TenantValidationInterceptor(registry TenantRegistry) connect.UnaryInterceptorFunc {
+	var known sync.Map
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			tenantID := tenantFromContext(ctx)
+			if tenantID == "" {
+				return next(ctx, req)
+			}
+
+			if expiresAt, ok := known.Load(tenantID); ok && middleware.clock.Now().Before(expiresAt.(time.Time)) {
+				return next(ctx, req)
+			}
+
+			exists, err := registry.Exists(ctx, tenantID)
+			if err != nil {
+				return nil, MapGormError(err)
+			}
+			if !exists {
+				known.Delete(tenantID)
+				return nil, ErrUnknownTenant
+			}
+
+			known.Store(tenantID, middleware.clock.Now().Add(knownTenantTTL))
+			return next(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package unicore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WithTransaction runs fn in a transaction, committing when fn returns nil and rolling back
+// otherwise. Errors are translated through MapGormError.
+//
+// The handle passed to fn carries ctx but is not scoped to its tenant, so the same transaction can
+// reach global tables without a tenant column; add WithTenantScope to queries of tenant tables.
+// The transaction is stored in the context of the handle (tx.Statement.Context). Calling
+// WithTransaction again with that context reuses the transaction through a savepoint, so nested
+// units of work roll back independently without opening a second connection.
+//
+// Example Usage:
+//
+//	err := WithTransaction(ctx, db, func(tx *gorm.DB) error {
+//	    var customer Customer
+//	    if err := tx.Scopes(WithTenantScope(ctx)).First(&customer, "id = ?", order.CustomerID).Error; err != nil {
+//	        return err
+//	    }
+//	    if err := tx.Create(&order).Error; err != nil {
+//	        return err
+//	    }
+//	    return reserveStock(tx.Statement.Context, order)
+//	})
+func WithTransaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if existing, ok := TransactionFromContext(ctx); ok {
+		db = existing
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txCtx := context.WithValue(ctx, ContextKeyTransaction, tx)
+		return fn(tx.WithContext(txCtx))
+	})
+	if err != nil {
+		return MapGormError(err)
+	}
+	return nil
+}
+
+// TransactionFromContext returns the transaction started by WithTransaction, if any
+func TransactionFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(ContextKeyTransaction).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+type serializableOptions struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+// SerializableOption customizes the retry behaviour of RunSerializable
+type SerializableOption func(*serializableOptions)
+
+// WithSerializableRetries sets how many times a transaction is retried after a serialization
+// failure (default 3)
+func WithSerializableRetries(maxRetries int) SerializableOption {
+	return func(options *serializableOptions) {
+		options.maxRetries = maxRetries
+	}
+}
+
+// WithSerializableBackoff sets the initial wait between retries, doubled on every attempt
+// (default 50ms)
+func WithSerializableBackoff(backoff time.Duration) SerializableOption {
+	return func(options *serializableOptions) {
+		options.backoff = backoff
+	}
+}
+
+// RunSerializable runs fn in a SERIALIZABLE transaction. Like with WithTransaction, the handle
+// passed to fn carries ctx but is not scoped to its tenant; add WithTenantScope to queries of
+// tenant tables. When the database aborts the transaction with a serialization failure (Postgres
+// SQLSTATE 40001) the whole transaction is retried with exponential backoff, so fn must be safe
+// to run more than once. Errors are translated through MapGormError, a serialization failure
+// left after the last retry becoming CodeAborted.
+//
+// Example Usage:
+//
+//	err := RunSerializable(ctx, db, func(tx *gorm.DB) error {
+//	    var account Account
+//	    if err := tx.Scopes(WithTenantScope(ctx)).First(&account, "id = ?", id).Error; err != nil {
+//	        return err
+//	    }
+//	    return tx.Model(&account).Update("balance", account.Balance-amount).Error
+//	}, WithSerializableRetries(5))
+func RunSerializable(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...SerializableOption) error {
+	options := serializableOptions{
+		maxRetries: 3,
+		backoff:    50 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	wait := options.backoff
+	for attempt := 0; ; attempt++ {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(tx)
+		}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+
+		if err == nil {
+			return nil
+		}
+		if sqlStateOf(err) != sqlStateSerializationFailure || attempt >= options.maxRetries {
+			return MapGormError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return MapGormError(ctx.Err())
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,124 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+// sqlStateError mimics the errors of Postgres drivers exposing their SQLSTATE
+type sqlStateError struct {
+	state string
+}
+
+func (err *sqlStateError) Error() string    { return "sqlstate " + err.state }
+func (err *sqlStateError) SQLState() string { return err.state }
+
+func TestRunSerializableRetriesSerializationFailure(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	ctx := withTenant(context.Background(), "acme")
+
+	attempts := 0
+	err := RunSerializable(ctx, db, func(tx *gorm.DB) error {
+		attempts++
+		if err := tx.Create(&exportRow{TenantID: "acme", Name: "order"}).Error; err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return &sqlStateError{state: sqlStateSerializationFailure}
+		}
+		return nil
+	}, WithSerializableBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+	var count int64
+	db.Model(&exportRow{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected the failed attempts to be rolled back, found %d rows", count)
+	}
+}
+
+func TestRunSerializableGivesUpAfterRetries(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+
+	attempts := 0
+	err := RunSerializable(withTenant(context.Background(), "acme"), db, func(tx *gorm.DB) error {
+		attempts++
+		return &sqlStateError{state: sqlStateSerializationFailure}
+	}, WithSerializableRetries(2), WithSerializableBackoff(time.Millisecond))
+
+	assertCode(t, err, connect.CodeAborted)
+	if attempts != 3 {
+		t.Fatalf("expected the first attempt and 2 retries, got %d attempts", attempts)
+	}
+}
+
+func TestRunSerializableDoesNotRetryOtherErrors(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+
+	attempts := 0
+	err := RunSerializable(withTenant(context.Background(), "acme"), db, func(tx *gorm.DB) error {
+		attempts++
+		return errors.New("insufficient balance")
+	}, WithSerializableBackoff(time.Millisecond))
+
+	assertCode(t, err, connect.CodeInternal)
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestTransactionsLeaveTenantScopeToCaller(t *testing.T) {
+	run := map[string]func(context.Context, *gorm.DB, func(*gorm.DB) error) error{
+		"WithTransaction": WithTransaction,
+		"RunSerializable": func(ctx context.Context, db *gorm.DB, fn func(*gorm.DB) error) error {
+			return RunSerializable(ctx, db, fn)
+		},
+	}
+
+	for name, transaction := range run {
+		t.Run(name, func(t *testing.T) {
+			// unauditedRow is a global table without a tenant column.
+			db := openTestDB(t, &exportRow{}, &unauditedRow{})
+			db.Create(&[]exportRow{{TenantID: "acme", Name: "a"}, {TenantID: "other", Name: "b"}})
+			db.Create(&unauditedRow{ID: "1", Name: "global"})
+			ctx := withTenant(context.Background(), "acme")
+
+			var scoped, all []exportRow
+			var global []unauditedRow
+			err := transaction(ctx, db, func(tx *gorm.DB) error {
+				if tenantFromContext(tx.Statement.Context) != "acme" {
+					t.Error("expected the handle to carry the request context")
+				}
+				if err := tx.Find(&global).Error; err != nil {
+					return err
+				}
+				if err := tx.Scopes(WithTenantScope(ctx)).Find(&scoped).Error; err != nil {
+					return err
+				}
+				return tx.Find(&all).Error
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(global) != 1 {
+				t.Fatalf("expected the global row, got %+v", global)
+			}
+			if len(scoped) != 1 || scoped[0].TenantID != "acme" {
+				t.Fatalf("expected only the acme row with the tenant scope, got %+v", scoped)
+			}
+			if len(all) != 2 {
+				t.Fatalf("expected the unscoped handle to see every row, got %+v", all)
+			}
+		})
+	}
+}
@@ -3,13 +3,16 @@ package unicore
 import (
 	"context"
 	"net/http"
+	"time"
 
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
 	"connectrpc.com/connect"
 	"connectrpc.com/grpchealth"
 	"github.com/coreos/go-oidc"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/net/http2"
 	"google.golang.org/grpc"
 	"gorm.io/gorm"
@@ -20,28 +23,39 @@ type PagedResult[T any] struct {
 	Total int64
 }
 
+// PageMetadata describes the position of a page within a PagedResult
+type PageMetadata struct {
+	CurrentPage int32
+	TotalPages  int32
+	TotalItems  int64
+	HasNext     bool
+	HasPrev     bool
+}
+
 type UserAuthClaims struct {
-	Exp               int64          `json:"exp"`
-	Iat               int64          `json:"iat"`
-	Jti               string         `json:"jti"`
-	Iss               string         `json:"iss"`
-	Aud               []string       `json:"aud"`
-	Id                string         `json:"sub"`
-	Typ               string         `json:"typ"`
-	Azp               string         `json:"azp"`
-	Sid               string         `json:"sid"`
-	Acr               string         `json:"acr"`
-	AllowedOrigins    []string       `json:"allowed-origins"`
-	RealmAccess       RealmAccess    `json:"realm_access"`
-	ResourceAccess    ResourceAccess `json:"resource_access"`
-	Scope             string         `json:"scope"`
-	EmailVerified     bool           `json:"email_verified"`
-	Organization      []string       `json:"organization"`
-	Name              string         `json:"name"`
-	PreferredUsername string         `json:"preferred_username"`
-	GivenName         string         `json:"given_name"`
-	FamilyName        string         `json:"family_name"`
-	Email             string         `json:"email"`
+	Exp               int64            `json:"exp"`
+	Iat               int64            `json:"iat"`
+	Jti               string           `json:"jti"`
+	Iss               string           `json:"iss"`
+	Aud               jwt.ClaimStrings `json:"aud"`
+	Id                string           `json:"sub"`
+	Typ               string           `json:"typ"`
+	Azp               string           `json:"azp"`
+	Sid               string           `json:"sid"`
+	Acr               string           `json:"acr"`
+	AllowedOrigins    []string         `json:"allowed-origins"`
+	RealmAccess       RealmAccess      `json:"realm_access"`
+	ResourceAccess    ResourceAccess   `json:"resource_access"`
+	Scope             string           `json:"scope"`
+	EmailVerified     bool             `json:"email_verified"`
+	Organization      []string         `json:"organization"`
+	Name              string           `json:"name"`
+	PreferredUsername string           `json:"preferred_username"`
+	GivenName         string           `json:"given_name"`
+	FamilyName        string           `json:"family_name"`
+	Email             string           `json:"email"`
+	Zoneinfo          string           `json:"zoneinfo"`
+	Locale            string           `json:"locale"`
 	jwt.RegisteredClaims
 }
 
@@ -50,9 +64,11 @@ type RealmAccess struct {
 	Roles []string `json:"roles"`
 }
 
-// ResourceAccess defines roles at the resource level
+// ResourceAccess defines roles at the resource level. Account keeps the roles of the "account"
+// client while Clients holds the roles of every client in the token, including "account".
 type ResourceAccess struct {
-	Account AccountRoles `json:"account"`
+	Account AccountRoles            `json:"account"`
+	Clients map[string]AccountRoles `json:"-"`
 }
 
 // AccountRoles defines roles within the "account" resource
@@ -60,6 +76,43 @@ type AccountRoles struct {
 	Roles []string `json:"roles"`
 }
 
+// RequestContext groups the caller's identity for a request, see ContextHelper.GetRequestContext
+type RequestContext struct {
+	// TenantID is the tenant stored by UnaryTenantInterceptor
+	TenantID string
+	// UserClaims are the claims stored by UnaryTokenInterceptor
+	UserClaims *UserAuthClaims
+	// RequestID is the id set by RequestIDUnaryInterceptor, empty when it did not run
+	RequestID string
+}
+
+// LoggingOptions controls what LoggingUnaryInterceptor and LoggingStreamInterceptor write. Failed
+// requests are always logged regardless of these settings.
+type LoggingOptions struct {
+	// SkipProcedures lists procedures that are never logged on success (e.g. HealthCheckProcedure)
+	SkipProcedures []string
+	// MaxBodyBytes bounds the logged JSON of request and response bodies; larger bodies are logged
+	// as a truncated prefix with their size and hash (default 4KB, negative disables the limit)
+	MaxBodyBytes int
+	// SampleRates logs only 1 in N successful requests for the given procedures
+	SampleRates map[string]uint64
+	// LogStreamMessages logs every sanitized message of streaming calls, not only their counts, when
+	// debug logging is enabled
+	LogStreamMessages bool
+}
+
+// RouteConfig declares the access rules of every procedure in one place, see WithRouteConfig
+type RouteConfig struct {
+	// Public lists procedures served without a token
+	Public []string
+	// RolesRequired maps procedures to realm roles; callers need at least one of them
+	RolesRequired map[string][]string
+	// UnverifiedEmailAllowed lists procedures open to callers whose email is not verified
+	UnverifiedEmailAllowed []string
+	// FeaturesRequired maps procedures to feature flags their tenant needs, see FeatureFlagInterceptor
+	FeaturesRequired map[string][]string
+}
+
 type Config interface {
 	LoadEnv()
 	GetGormConfig() *gorm.Config
@@ -71,12 +124,30 @@ type Config interface {
 	IsTesting() bool
 	IsDevelopment() bool
 	IsProduction() bool
+	GetCursorSecret() []byte
+	GetServiceTokenSecret() []byte
+	GetLogLevel() zapcore.Level
+	GetLogFormat() string
+	GetLogSamplingInitial() int
+	GetLogSamplingThereafter() int
 }
 
 type ContextHelper interface {
 	GetTenant(context.Context) (string, error)
+	GetTenantFromContext(context.Context) (string, error)
 	GetUserClaims(context.Context) *UserAuthClaims
 	GetAccessToken(request connect.AnyRequest) (string, error)
+	GetAccessTokenFromContext(context.Context) (string, error)
+	GetTimezone(context.Context) *time.Location
+	GetLocale(context.Context) string
+	GetRequestID(context.Context) string
+	GetClientRoles(ctx context.Context, client string) []string
+	HasRealmRole(ctx context.Context, role string) bool
+	HasResourceRole(ctx context.Context, client, role string) bool
+	WithTenant(ctx context.Context, tenantID string) context.Context
+	WithUserClaims(ctx context.Context, claims *UserAuthClaims) context.Context
+	GetRequestContext(context.Context) (*RequestContext, error)
+	WithRequestContext(ctx context.Context, requestContext *RequestContext) context.Context
 }
 
 type Authenticator interface {
@@ -92,5 +163,131 @@ type Middleware interface {
 	LoggingUnaryInterceptor() connect.UnaryInterceptorFunc
 	HealthChecker(string) *grpchealth.StaticChecker
 	UnaryTokenInterceptor(...string) connect.UnaryInterceptorFunc
+	UnaryTokenInterceptorFromContext(...string) connect.UnaryInterceptorFunc
 	UnaryTenantInterceptor() connect.UnaryInterceptorFunc
+	UnaryTimezoneInterceptor() connect.UnaryInterceptorFunc
+	RequestIDUnaryInterceptor() connect.UnaryInterceptorFunc
+	MaxPageInterceptor(int32) connect.UnaryInterceptorFunc
+	SignedTenantInterceptor([]byte) connect.UnaryInterceptorFunc
+	TenantConsistencyInterceptor(...string) connect.UnaryInterceptorFunc
+	TimeBudgetInterceptor() connect.UnaryInterceptorFunc
+	DeprecationInterceptor(map[string]string) connect.UnaryInterceptorFunc
+	ResponseHeaderFilterInterceptor([]string) connect.UnaryInterceptorFunc
+	AuditInterceptor(AuditSink, ...string) connect.UnaryInterceptorFunc
+	RegisterReflection(*http.ServeMux, ...string)
+	MaxRequestSizeInterceptor(maxBytes int64, opts ...RequestSizeOption) connect.Interceptor
+	RequireVerifiedEmailInterceptor(routes ...string) connect.UnaryInterceptorFunc
+	LoggingStreamInterceptor() connect.Interceptor
+	RequireHeadersInterceptor(required ...string) connect.UnaryInterceptorFunc
+	MTLSInterceptor(procedures ...string) connect.UnaryInterceptorFunc
+	ConnectErrorInterceptor() connect.UnaryInterceptorFunc
+	PublicRoutes() []string
+	TenantValidationInterceptor(registry TenantRegistry) connect.UnaryInterceptorFunc
+	RecoveryInterceptor() connect.UnaryInterceptorFunc
+	APIVersionInterceptor(minVersion string) connect.UnaryInterceptorFunc
+	ServiceTokenInterceptor(tokens ServiceTokenAuthenticator) connect.UnaryInterceptorFunc
+	ValidationUnaryInterceptor(opts ...ValidationOption) connect.UnaryInterceptorFunc
+	TenantFromHostInterceptor(baseDomain string) connect.UnaryInterceptorFunc
+	SingleFlightInterceptor(procedures ...string) connect.UnaryInterceptorFunc
+	SharedSingleFlightInterceptor(procedures ...string) connect.UnaryInterceptorFunc
+	FeatureFlagInterceptor(flags FlagStore) connect.UnaryInterceptorFunc
+	DrainingInterceptor(state *ShutdownState) connect.UnaryInterceptorFunc
+}
+
+// LifecycleManager coordinates graceful shutdown of servers, consumers and connections
+type LifecycleManager interface {
+	Register(name string, fn func(ctx context.Context) error)
+	Run(ctx context.Context) error
+	ShutdownState() *ShutdownState
+}
+
+// MessageHandler processes a JetStream message with a context carrying its tenant
+type MessageHandler func(ctx context.Context, msg jetstream.Msg) error
+
+// Publisher publishes events, propagating the tenant of the context
+type Publisher interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+}
+
+// Outbox stores events in the same transaction as the state change they describe
+type Outbox interface {
+	Enqueue(tx *gorm.DB, subject string, data []byte) error
+}
+
+// OutboxDispatcher publishes the events stored by an Outbox
+type OutboxDispatcher interface {
+	Run(ctx context.Context) error
+}
+
+// Consumer consumes events, restoring the tenant of the publisher into the handler context
+type Consumer interface {
+	Consume(ctx context.Context, handler MessageHandler) (jetstream.ConsumeContext, error)
+}
+
+// Migrator applies or verifies the database schema of a set of models
+type Migrator interface {
+	Migrate(ctx context.Context) error
+}
+
+// TenantResolver returns the database handle of the tenant in the context
+type TenantResolver interface {
+	Resolve(ctx context.Context) (*gorm.DB, error)
+}
+
+// TenantRegistry knows which tenants exist
+type TenantRegistry interface {
+	Exists(ctx context.Context, tenantID string) (bool, error)
+}
+
+// Repository provides tenant-scoped CRUD operations for a model
+type Repository[T any] interface {
+	Create(ctx context.Context, record *T) error
+	GetByID(ctx context.Context, id string) (*T, error)
+	List(ctx context.Context, page *commonv1.PageRequest, scopes ...func(*gorm.DB) *gorm.DB) (*PagedResult[[]T], error)
+	Update(ctx context.Context, record *T) error
+	Delete(ctx context.Context, id string) error
+	HardDelete(ctx context.Context, id string) error
+	DeleteWhere(ctx context.Context, conditions map[string]any) (int64, error)
+	UpdateWhere(ctx context.Context, conditions, updates map[string]any) (int64, error)
+	Restore(ctx context.Context, id string) error
+}
+
+// ServiceTokenAuthenticator mints and verifies short-lived tokens for service-to-service calls
+type ServiceTokenAuthenticator interface {
+	Issuer() string
+	Mint(service string) (string, error)
+	Verify(token string) (*ServiceTokenClaims, error)
+}
+
+// FlagStore reports which features are enabled for a tenant
+type FlagStore interface {
+	Enabled(tenantID, feature string) bool
+}
+
+// TenantCache caches values per tenant, isolating the entries of each tenant
+type TenantCache[T any] interface {
+	GetOrLoad(ctx context.Context, key string, loader func() (T, error)) (T, error)
+	Invalidate(tenantID string)
+	InvalidateKey(ctx context.Context, key string)
+}
+
+// BatchLoader coalesces concurrent loads of single keys into batch queries
+type BatchLoader[K comparable, V any] interface {
+	Load(ctx context.Context, key K) (V, error)
+}
+
+// Clock tells the time, letting tests control token expiry and TTLs
+type Clock interface {
+	Now() time.Time
+}
+
+// TokenBlacklist tracks revoked token ids (jti) until the tokens expire
+type TokenBlacklist interface {
+	IsRevoked(ctx context.Context, jti string) bool
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+}
+
+// AuditSink stores audit events
+type AuditSink interface {
+	Write(ctx context.Context, event AuditEvent) error
 }
@@ -6,11 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
 	"connectrpc.com/connect"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PagedResult -------------For Pagination
@@ -22,6 +29,38 @@ func (p *PagedResult[T]) GetTotalPages(limit int32) int32 {
 	return int32((p.Total + int64(limit) - 1) / int64(limit))
 }
 
+// HasNextPage reports whether another page follows the given one
+func (p *PagedResult[T]) HasNextPage(page, limit int32) bool {
+	if page <= 0 {
+		return false
+	}
+	return page < p.GetTotalPages(limit)
+}
+
+// HasPrevPage reports whether a page precedes the given one
+func (p *PagedResult[T]) HasPrevPage(page int32) bool {
+	return p != nil && page > 1
+}
+
+// PageMeta returns the pagination metadata for the given page so handlers can fill their
+// response in one call
+func (p *PagedResult[T]) PageMeta(page, limit int32) PageMetadata {
+	if page < 0 {
+		page = 0
+	}
+
+	meta := PageMetadata{
+		CurrentPage: page,
+		TotalPages:  p.GetTotalPages(limit),
+		HasNext:     p.HasNextPage(page, limit),
+		HasPrev:     p.HasPrevPage(page),
+	}
+	if p != nil {
+		meta.TotalItems = p.Total
+	}
+	return meta
+}
+
 func NewPagedResult[T any](total int64, items T) *PagedResult[T] {
 	return &PagedResult[T]{
 		Items: items,
@@ -46,46 +85,212 @@ func NewPagedResult[T any](total int64, items T) *PagedResult[T] {
 //	    Sort:      "created_at",
 //	    Direction: SortDirection_SORT_DIRECTION_DESC,
 //	})).Find(&records)
+//
+// A limit of PageLimitAll returns every row without LIMIT/OFFSET, but only when the query context
+// was marked with AllowUnpaginated; otherwise it falls back to the default limit. Limits above
+// DefaultMaxPageLimit are capped, see WithPaginationScopeMaxLimit to use another maximum. Pages
+// skipping more than DefaultMaxPageOffset rows fail with CodeInvalidArgument, see
+// WithPaginationScopeMaxDepth.
 func WithPaginationScope(pagination *commonv1.PageRequest) func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		// Defaults
-		page := pagination.GetPage()
-		if page <= 0 {
-			page = 1
-		}
-
-		limit := pagination.GetLimit()
-		if limit <= 0 {
-			limit = 20
-		}
+	return WithPaginationScopeMaxLimit(pagination, DefaultMaxPageLimit)
+}
 
-		offset := (page - 1) * limit
+// WithPaginationScopeMaxLimit works like WithPaginationScope with a custom cap on the limit,
+// applying the values of NormalizePageRequest.
+//
+// Example Usage:
+//
+//	db.Scopes(WithPaginationScopeMaxLimit(req.GetPage(), 500)).Find(&records)
+func WithPaginationScopeMaxLimit(pagination *commonv1.PageRequest, maxLimit int32) func(db *gorm.DB) *gorm.DB {
+	return WithPaginationScopeMaxDepth(pagination, maxLimit, DefaultMaxPageOffset)
+}
 
-		// Apply limit and offset
-		db = db.Limit(int(limit)).Offset(int(offset))
+// WithPaginationScopeMaxDepth works like WithPaginationScopeMaxLimit with a custom cap on the
+// number of rows skipped to reach the page: when the offset exceeds maxOffset (DefaultMaxPageOffset
+// when zero, no cap when negative) the query fails with the CodeInvalidArgument error of
+// ValidatePageDepth instead of scanning the skipped rows.
+//
+// Example Usage:
+//
+//	err := db.Scopes(WithPaginationScopeMaxDepth(req.GetPage(), 100, 1000)).Find(&records).Error
+func WithPaginationScopeMaxDepth(pagination *commonv1.PageRequest, maxLimit, maxOffset int32) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		unpaginated := pagination.GetLimit() == PageLimitAll && isUnpaginatedAllowed(db.Statement.Context)
+		normalized := NormalizePageRequest(pagination, maxLimit)
 
-		// Sorting
-		sort := pagination.GetSort()
-		direction := pagination.GetDirection()
+		page := normalized.GetPage()
+		limit := normalized.GetLimit()
+		offset := int64(page-1) * int64(limit)
 
-		if sort == "" {
-			sort = "created_at"
+		// Apply limit and offset
+		if !unpaginated {
+			if err := validatePageOffset(page, offset, maxOffset); err != nil {
+				db.AddError(err)
+				return db
+			}
+			db = db.Limit(int(limit)).Offset(int(offset))
 		}
 
+		// Sorting
 		order := "desc"
-		switch direction {
-		case commonv1.SortDirection_SORT_DIRECTION_ASC:
+		if normalized.GetDirection() == commonv1.SortDirection_SORT_DIRECTION_ASC {
 			order = "asc"
-		case commonv1.SortDirection_SORT_DIRECTION_DESC:
-			order = "desc"
 		}
 
-		db = db.Order(fmt.Sprintf("%s %s", sort, order))
+		db = db.Order(fmt.Sprintf("%s %s", normalized.GetSort(), order))
 
 		return db
 	}
 }
 
+const (
+	// DefaultPageLimit is the limit used when a PageRequest has none
+	DefaultPageLimit int32 = 20
+	// DefaultMaxPageLimit is the largest limit accepted by WithPaginationScope
+	DefaultMaxPageLimit int32 = 100
+	// DefaultMaxPageOffset is the largest number of rows WithPaginationScope skips to reach a page
+	DefaultMaxPageOffset int32 = 10000
+)
+
+// sortFieldPattern matches the column names, optionally table qualified, accepted as sort fields
+var sortFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// isSortField reports whether sort is a plain column name that is safe to put in ORDER BY
+func isSortField(sort string) bool {
+	return sortFieldPattern.MatchString(sort)
+}
+
+// NormalizePageRequest returns a sanitized copy of a PageRequest with the defaults applied by
+// WithPaginationScope: page at least 1, a missing or negative limit replaced by DefaultPageLimit,
+// a limit above maxLimit capped (DefaultMaxPageLimit when maxLimit <= 0), sorting by created_at
+// when the sort field is missing or not a plain column name, and a descending direction unless
+// ascending was requested.
+func NormalizePageRequest(p *commonv1.PageRequest, maxLimit int32) *commonv1.PageRequest {
+	if maxLimit <= 0 {
+		maxLimit = DefaultMaxPageLimit
+	}
+
+	normalized := &commonv1.PageRequest{
+		Page:      max(p.GetPage(), 1),
+		Limit:     p.GetLimit(),
+		Sort:      p.GetSort(),
+		Direction: p.GetDirection(),
+		Filter:    p.GetFilter(),
+	}
+
+	if normalized.Limit <= 0 {
+		normalized.Limit = DefaultPageLimit
+	}
+	normalized.Limit = min(normalized.Limit, maxLimit)
+
+	if !isSortField(normalized.Sort) {
+		normalized.Sort = "created_at"
+	}
+	if normalized.Direction != commonv1.SortDirection_SORT_DIRECTION_ASC {
+		normalized.Direction = commonv1.SortDirection_SORT_DIRECTION_DESC
+	}
+
+	return normalized
+}
+
+// PageLimitAll is the PageRequest limit requesting every row, see AllowUnpaginated
+const PageLimitAll int32 = -1
+
+// AllowUnpaginated marks a context as privileged so WithPaginationScope honours PageLimitAll.
+// Only set it for internal tools and trusted callers, never from client input.
+//
+// Example Usage:
+//
+//	db.WithContext(AllowUnpaginated(ctx)).Scopes(WithPaginationScope(&PageRequest{Limit: PageLimitAll})).Find(&records)
+func AllowUnpaginated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyAllowUnpaginated, true)
+}
+
+// isUnpaginatedAllowed reports whether the context was marked by AllowUnpaginated
+func isUnpaginatedAllowed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	allowed, _ := ctx.Value(ContextKeyAllowUnpaginated).(bool)
+	return allowed
+}
+
+// AllowFullScan marks a context as allowed to run Repository.DeleteWhere and UpdateWhere without
+// conditions, affecting every record of the tenant. Only set it for deliberate admin operations.
+//
+// Example Usage:
+//
+//	deleted, err := drafts.DeleteWhere(AllowFullScan(ctx), nil)
+func AllowFullScan(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyAllowFullScan, true)
+}
+
+// isFullScanAllowed reports whether the context was marked by AllowFullScan
+func isFullScanAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(ContextKeyAllowFullScan).(bool)
+	return allowed
+}
+
+// ValidatePageRequest checks that the requested page does not go past maxPage, guiding clients to
+// cursor pagination instead of crawling deep offsets. A maxPage of zero or less disables the check.
+func ValidatePageRequest(pagination *commonv1.PageRequest, maxPage int32) error {
+	if maxPage > 0 && pagination.GetPage() > maxPage {
+		return connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("page %d exceeds the maximum of %d, use cursor pagination to read further", pagination.GetPage(), maxPage))
+	}
+	return nil
+}
+
+// ValidatePageDepth checks that reaching the requested page skips at most maxOffset rows
+// (DefaultMaxPageOffset when zero, no cap when negative), with the page and limit normalized by
+// NormalizePageRequest. Deep offsets make the database read and discard every skipped row, so
+// clients are told to use cursor pagination instead.
+//
+// Example Usage:
+//
+//	if err := ValidatePageDepth(req.Msg.GetPage(), 0); err != nil {
+//	    return nil, err
+//	}
+func ValidatePageDepth(pagination *commonv1.PageRequest, maxOffset int32) error {
+	normalized := NormalizePageRequest(pagination, DefaultMaxPageLimit)
+	offset := int64(normalized.GetPage()-1) * int64(normalized.GetLimit())
+	return validatePageOffset(normalized.GetPage(), offset, maxOffset)
+}
+
+// validatePageOffset rejects offsets past maxOffset, applying its default. The offset is computed in
+// int64 by the callers so a large page cannot wrap around to a small or negative offset.
+func validatePageOffset(page int32, offset int64, maxOffset int32) error {
+	if maxOffset == 0 {
+		maxOffset = DefaultMaxPageOffset
+	}
+	if maxOffset > 0 && offset > int64(maxOffset) {
+		return connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("page %d skips more than %d rows, use cursor pagination to read further", page, maxOffset))
+	}
+	return nil
+}
+
+// pageRequestOf returns the PageRequest carried by a request message, either the message itself or
+// its first populated PageRequest field
+func pageRequestOf(msg any) *commonv1.PageRequest {
+	switch m := msg.(type) {
+	case *commonv1.PageRequest:
+		return m
+	case proto.Message:
+		pageRequestName := (*commonv1.PageRequest)(nil).ProtoReflect().Descriptor().FullName()
+		var found *commonv1.PageRequest
+		m.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if fd.IsList() || fd.IsMap() || fd.Message() == nil || fd.Message().FullName() != pageRequestName {
+				return true
+			}
+			found, _ = v.Message().Interface().(*commonv1.PageRequest)
+			return found == nil
+		})
+		return found
+	}
+	return nil
+}
+
 // WithTenantScope creates a GORM scope function that filters database queries by tenant ID.
 // It is used to implement multi-tenancy by ensuring that queries only return records
 // belonging to the specified tenant.
@@ -100,18 +305,215 @@ func WithPaginationScope(pagination *commonv1.PageRequest) func(db *gorm.DB) *go
 //
 //	db.Scopes(WithTenantScope(ctx)).Find(&records)
 func WithTenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return WithTenantScopeColumn(ctx, "")
+}
+
+// WithTenantScopeColumn works like WithTenantScope for models whose tenant column is not named
+// tenant_id (e.g. legacy tables using company_id).
+//
+// Parameters:
+//   - column: The tenant column to filter by, defaults to "tenant_id" when empty
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScopeColumn(ctx, "company_id")).Find(&records)
+func WithTenantScopeColumn(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	if column == "" {
+		column = "tenant_id"
+	}
 	return func(db *gorm.DB) *gorm.DB {
 		tenantId := ctx.Value(XTenantKey)
 		log.Printf("👮 [WithTenantScope]: TenantId: %s", tenantId)
-		return db.Where("tenant_id = ?", tenantId)
+		return db.Where(fmt.Sprintf("%s = ?", column), tenantId)
+	}
+}
+
+// joinClausePattern matches "[LEFT|INNER|...] JOIN <table> [[AS] <alias>] ON <condition>"
+var joinClausePattern = regexp.MustCompile(`(?is)^(.*?\bjoin\s+)(\S+)(?:\s+(?:as\s+)?(\w+))?\s+on\s+(.+)$`)
+
+// WithTenantJoin creates a GORM scope function that joins another table while restricting the
+// joined rows to the tenant in ctx, so multi-table queries cannot leak another tenant's data.
+//
+// Conventions:
+//   - The clause must have the form "[LEFT|INNER|...] JOIN <table> [[AS] <alias>] ON <condition>"
+//   - The joined table must have a tenant_id column; its alias is used when given
+//   - The ON condition is wrapped in parentheses, so conditions using OR stay correct
+//
+// The base table still needs a tenant filter; qualify its column with WithTenantScopeColumn since
+// tenant_id is ambiguous once joined. A clause that cannot be parsed adds an error to the query
+// instead of joining unfiltered.
+//
+// Example Usage:
+//
+//	db.Scopes(
+//	    WithTenantScopeColumn(ctx, "users.tenant_id"),
+//	    WithTenantJoin(ctx, "JOIN orders o ON o.user_id = users.id"),
+//	).Find(&users)
+func WithTenantJoin(ctx context.Context, joinClause string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		matches := joinClausePattern.FindStringSubmatch(strings.TrimSpace(joinClause))
+		if matches == nil {
+			_ = db.AddError(fmt.Errorf("unsupported join clause %q: expected JOIN <table> ON <condition>", joinClause))
+			return db
+		}
+
+		qualifier := matches[2]
+		if matches[3] != "" {
+			qualifier = matches[3]
+		}
+
+		join := fmt.Sprintf("%s%s ON (%s) AND %s.tenant_id = ?", matches[1], strings.Join(strings.Fields(matches[2]+" "+matches[3]), " "), matches[4], qualifier)
+		return db.Joins(join, ctx.Value(XTenantKey))
+	}
+}
+
+// WithDateRangeScope creates a GORM scope function that filters records to a date range.
+// Each bound is optional and skipped when nil; both bounds are inclusive.
+//
+// Parameters:
+//   - column: The column to filter on, defaults to "created_at" when empty
+//   - from: The lower bound (column >= from)
+//   - to: The upper bound (column <= to)
+//
+// Returns:
+//   - A GORM scope function that adds the range conditions, or adds ErrInvalidDateRange to the
+//     query when from is after to
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithDateRangeScope("", &from, &to)).Find(&records)
+func WithDateRangeScope(column string, from, to *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if column == "" {
+			column = "created_at"
+		}
+
+		if from != nil && to != nil && from.After(*to) {
+			_ = db.AddError(ErrInvalidDateRange)
+			return db
+		}
+
+		if from != nil {
+			db = db.Where(fmt.Sprintf("%s >= ?", column), *from)
+		}
+		if to != nil {
+			db = db.Where(fmt.Sprintf("%s <= ?", column), *to)
+		}
+
+		return db
+	}
+}
+
+// WithIncludeDeleted creates a GORM scope function that includes soft-deleted records.
+// Models embedding gorm.Model or a gorm.DeletedAt field are filtered with "deleted_at IS NULL" by
+// default; this scope disables that filter through db.Unscoped(). Note that Unscoped also makes
+// Delete permanent, so only use it for reads. Other scopes such as WithTenantScope still apply.
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithIncludeDeleted()).Find(&records)
+func WithIncludeDeleted() func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}
+}
+
+// WithOnlyDeleted creates a GORM scope function that returns only soft-deleted records.
+// It implies WithIncludeDeleted, as GORM would otherwise exclude the very rows being requested.
+//
+// Parameters:
+//   - column: The soft delete column, defaults to "deleted_at" when empty
+//
+// Example Usage:
+//
+//	db.Scopes(WithTenantScope(ctx), WithOnlyDeleted("")).Find(&records)
+func WithOnlyDeleted(column string) func(*gorm.DB) *gorm.DB {
+	if column == "" {
+		column = "deleted_at"
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Where(fmt.Sprintf("%s IS NOT NULL", column))
+	}
+}
+
+// WithContextScope creates a GORM scope function binding the query to ctx, for handles built
+// without WithContext. GORM passes the context to the driver, so the query is aborted as soon as
+// ctx is cancelled or its deadline passes (drivers such as pgx and go-sql-driver/mysql cancel the
+// running statement; pure-Go SQLite only reports the error once it finishes). Combined with
+// TimeBudgetInterceptor or a client deadline, work for abandoned requests stops instead of running
+// to completion. The helpers of this package (repositories, pagination, transactions) already
+// bind the context they are given.
+//
+// Example Usage:
+//
+//	db.Scopes(WithContextScope(ctx), WithTenantScope(ctx)).Find(&records)
+func WithContextScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db.Statement.Context = ctx
+		return db
+	}
+}
+
+// WithLockScope creates a GORM scope function that locks the selected rows until the transaction
+// ends (SELECT ... FOR UPDATE or FOR SHARE), preventing lost updates between concurrent
+// read-modify-write transactions. It composes with WithTenantScope and only has an effect inside a
+// transaction (see WithTransaction); SQLite has no row locks, so it is a no-op there.
+//
+// Parameters:
+//   - strength: "UPDATE" or "SHARE", case-insensitive; anything else fails the query
+//
+// Example Usage:
+//
+//	err := WithTransaction(ctx, db, func(tx *gorm.DB) error {
+//	    var account Account
+//	    if err := tx.Scopes(WithTenantScope(ctx), WithLockScope("UPDATE")).First(&account, "id = ?", id).Error; err != nil {
+//	        return err
+//	    }
+//	    account.Balance -= amount
+//	    return tx.Save(&account).Error
+//	})
+func WithLockScope(strength string) func(*gorm.DB) *gorm.DB {
+	strength = strings.ToUpper(strings.TrimSpace(strength))
+	return func(db *gorm.DB) *gorm.DB {
+		if strength != clause.LockingStrengthUpdate && strength != clause.LockingStrengthShare {
+			_ = db.AddError(fmt.Errorf("invalid lock strength %q", strength))
+			return db
+		}
+		if db.Dialector.Name() == "sqlite" {
+			return db
+		}
+		return db.Clauses(clause.Locking{Strength: strength})
 	}
 }
 
 const (
-	// ContextKeyUser is used to store the authenticated user's claims in context.
+	// ContextKeyUser is used to store the authenticated user's claims in context unless another key
+	// is configured with WithClaimsContextKey.
 	ContextKeyUser = "UserClaimsKey"
+	// ContextKeyAccessToken is used to store the verified bearer token of the caller in context.
+	ContextKeyAccessToken = "AccessTokenKey"
 	// XTenantKey is the metadata key for the company Id header
 	XTenantKey = "x-tenant-id"
+	// XTenantSignatureKey is the header carrying the HMAC signature of the tenant id
+	XTenantSignatureKey = "x-tenant-signature"
+	// XTimezoneKey is the header carrying the caller's IANA timezone name
+	XTimezoneKey = "x-timezone"
+	// ContextKeyTimezone is used to store the caller's *time.Location in context.
+	ContextKeyTimezone = "TimezoneKey"
+	// ContextKeyLocale is used to store the caller's locale in context.
+	ContextKeyLocale = "LocaleKey"
+	// XRequestIDKey is the header used to correlate a request across services
+	XRequestIDKey = "X-Request-ID"
+	// ContextKeyRequestID is used to store the request id in context.
+	ContextKeyRequestID = "RequestIDKey"
+	// XTotalBudgetKey is the header carrying the remaining time budget of a call in milliseconds
+	XTotalBudgetKey = "x-total-budget-ms"
+	// ContextKeyAllowUnpaginated marks a context allowed to request every row with PageLimitAll.
+	ContextKeyAllowUnpaginated = "AllowUnpaginatedKey"
+	// ContextKeyAllowFullScan marks a context allowed to bulk update or delete every row of a tenant.
+	ContextKeyAllowFullScan = "AllowFullScanKey"
+	// ContextKeyTransaction is used to store the active transaction in context.
+	ContextKeyTransaction = "TransactionKey"
 )
 
 // UserAuthClaims represents the JWT claims structure
@@ -121,6 +523,30 @@ func (u UserAuthClaims) String() string {
 	return string(jb)
 }
 
+// UnmarshalJSON captures the roles of every client listed in resource_access
+func (r *ResourceAccess) UnmarshalJSON(data []byte) error {
+	var clients map[string]AccountRoles
+	if err := json.Unmarshal(data, &clients); err != nil {
+		return err
+	}
+
+	r.Clients = clients
+	r.Account = clients["account"]
+	return nil
+}
+
+// MarshalJSON writes every client back in the resource_access shape
+func (r ResourceAccess) MarshalJSON() ([]byte, error) {
+	clients := make(map[string]AccountRoles, len(r.Clients)+1)
+	for client, roles := range r.Clients {
+		clients[client] = roles
+	}
+	if len(r.Account.Roles) > 0 {
+		clients["account"] = r.Account
+	}
+	return json.Marshal(clients)
+}
+
 //Context helper for authentication
 
 //Exceptions
@@ -129,20 +555,99 @@ var ErrMissingTenantHeader = connect.NewError(connect.CodeInvalidArgument, error
 var ErrFailedParsingTokenClaims = connect.NewError(connect.CodeInvalidArgument, errors.New("token claims could not be parsed"))
 var ErrInvalidToken = connect.NewError(connect.CodeUnauthenticated, errors.New(fmt.Sprintf("invalid token")))
 var ErrMissingOrInvalidToken = connect.NewError(connect.CodeUnauthenticated, errors.New(fmt.Sprintf("missing or invalid token")))
+var ErrInvalidDateRange = connect.NewError(connect.CodeInvalidArgument, errors.New("date range start must not be after its end"))
+var ErrInvalidAudience = connect.NewError(connect.CodeUnauthenticated, errors.New("token audience is not allowed"))
+var ErrInvalidTenantSignature = connect.NewError(connect.CodePermissionDenied, errors.New("x-tenant-signature is missing or does not match x-tenant-id"))
+var ErrTenantMismatch = connect.NewError(connect.CodePermissionDenied, errors.New("x-tenant-id does not match the tenant of the token"))
+var ErrMissingTenant = connect.NewError(connect.CodeInvalidArgument, errors.New("no tenant found in context"))
+var ErrMissingTokenSubject = connect.NewError(connect.CodeUnauthenticated, errors.New("token has no subject"))
+var ErrTimeBudgetExhausted = connect.NewError(connect.CodeDeadlineExceeded, errors.New("request time budget exhausted"))
+var ErrTokenRevoked = connect.NewError(connect.CodeUnauthenticated, errors.New("token has been revoked"))
+var ErrEmailNotVerified = connect.NewError(connect.CodePermissionDenied, errors.New("email address is not verified, verify it before using this endpoint"))
+var ErrInvalidCursor = connect.NewError(connect.CodeInvalidArgument, errors.New("invalid pagination cursor"))
+var ErrConcurrentModification = connect.NewError(connect.CodeAborted, errors.New("record was modified concurrently, reload it and retry"))
+var ErrMissingRequiredRole = connect.NewError(connect.CodePermissionDenied, errors.New("caller lacks a role required by this procedure"))
+var ErrUnknownTenant = connect.NewError(connect.CodePermissionDenied, errors.New("tenant does not exist or is not accessible"))
+var ErrInvalidServiceToken = connect.NewError(connect.CodeUnauthenticated, errors.New("invalid service token"))
+var ErrFullScanNotAllowed = connect.NewError(connect.CodeInvalidArgument, errors.New("bulk operation without conditions would affect every record of the tenant"))
+var ErrMissingUserClaims = connect.NewError(connect.CodeUnauthenticated, errors.New("no user claims found in context"))
+var ErrServerDraining = connect.NewError(connect.CodeUnavailable, errors.New("server is shutting down, retry on another instance"))
+var ErrCrossTenantWrite = connect.NewError(connect.CodePermissionDenied, errors.New("record belongs to another tenant than the request"))
+var ErrInvalidTenant = connect.NewError(connect.CodeInvalidArgument, errors.New("tenant id contains characters not allowed in a schema name"))
+
+func init() {
+	// Meta creates the metadata of an error lazily, so copying an error concurrently for the first
+	// time races on it. Create it up front for the errors shared by every request.
+	for _, err := range []*connect.Error{
+		ErrMissingTenantHeader,
+		ErrFailedParsingTokenClaims,
+		ErrInvalidToken,
+		ErrMissingOrInvalidToken,
+		ErrInvalidDateRange,
+		ErrInvalidAudience,
+		ErrInvalidTenantSignature,
+		ErrTenantMismatch,
+		ErrMissingTenant,
+		ErrMissingTokenSubject,
+		ErrTimeBudgetExhausted,
+		ErrTokenRevoked,
+		ErrEmailNotVerified,
+		ErrInvalidCursor,
+		ErrConcurrentModification,
+		ErrMissingRequiredRole,
+		ErrUnknownTenant,
+		ErrInvalidServiceToken,
+		ErrFullScanNotAllowed,
+		ErrMissingUserClaims,
+		ErrServerDraining,
+		ErrCrossTenantWrite,
+		ErrInvalidTenant,
+	} {
+		err.Meta()
+	}
+}
 
 //Helpers
 
 type contextHelper struct {
 	authenticator Authenticator
+	claimsKey     any
+}
+
+// ContextHelperOption customizes the helper returned by NewContextHelper
+type ContextHelperOption func(*contextHelper)
+
+// WithHelperClaimsContextKey reads and writes claims under the key configured on the middleware
+// with WithClaimsContextKey. Both must use the same name for GetUserClaims to see the claims.
+func WithHelperClaimsContextKey(name string) ContextHelperOption {
+	return func(helper *contextHelper) {
+		helper.claimsKey = claimsContextKey(name)
+	}
 }
 
 func (helper *contextHelper) GetAccessToken(request connect.AnyRequest) (string, error) {
 	return helper.authenticator.ExtractHeaderToken(request)
 }
 
+// GetAccessTokenFromContext returns the bearer token carried in the incoming gRPC metadata
+func (helper *contextHelper) GetAccessTokenFromContext(ctx context.Context) (string, error) {
+	return helper.authenticator.ExtractToken(ctx)
+}
+
+// GetUserClaims returns the caller's claims, or nil when unauthenticated
 func (helper *contextHelper) GetUserClaims(ctx context.Context) *UserAuthClaims {
-	userClaims := ctx.Value(ContextKeyUser).(*UserAuthClaims)
-	return userClaims
+	return claimsFromContextKey(ctx, helper.claimsKey)
+}
+
+// GetTenantFromContext returns the tenant id stored by UnaryTenantInterceptor, or ErrMissingTenant.
+// Unlike GetTenant it never falls back to incoming gRPC metadata, so prefer it in services served
+// only through Connect.
+func (helper *contextHelper) GetTenantFromContext(ctx context.Context) (string, error) {
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return "", ErrMissingTenant
+	}
+	return tenantID, nil
 }
 
 func (helper *contextHelper) GetTenant(ctx context.Context) (string, error) {
@@ -168,8 +673,130 @@ func (helper *contextHelper) GetTenant(ctx context.Context) (string, error) {
 	return "", errors.New("could not extract tenant id: x-tenant-id not found in context or metadata")
 }
 
-func NewContextHelper(authenticator Authenticator) ContextHelper {
-	return &contextHelper{
+// GetTimezone returns the caller's timezone set by UnaryTimezoneInterceptor, defaulting to UTC
+func (helper *contextHelper) GetTimezone(ctx context.Context) *time.Location {
+	if location, ok := ctx.Value(ContextKeyTimezone).(*time.Location); ok && location != nil {
+		return location
+	}
+	return time.UTC
+}
+
+// GetLocale returns the caller's locale set by UnaryTimezoneInterceptor, or an empty string
+func (helper *contextHelper) GetLocale(ctx context.Context) string {
+	locale, _ := ctx.Value(ContextKeyLocale).(string)
+	return locale
+}
+
+// GetRequestID returns the request id set by RequestIDUnaryInterceptor, or an empty string
+func (helper *contextHelper) GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(ContextKeyRequestID).(string)
+	return requestID
+}
+
+// GetClientRoles returns the caller's roles on the given OIDC client, or nil when unauthenticated
+func (helper *contextHelper) GetClientRoles(ctx context.Context, client string) []string {
+	claims := helper.GetUserClaims(ctx)
+	if claims == nil {
+		return nil
+	}
+	return claims.ResourceAccess.Clients[client].Roles
+}
+
+// HasRealmRole reports whether the caller holds the realm role. Matching is case-sensitive, as in
+// Keycloak, and false is returned when unauthenticated.
+func (helper *contextHelper) HasRealmRole(ctx context.Context, role string) bool {
+	claims := helper.GetUserClaims(ctx)
+	if claims == nil {
+		return false
+	}
+	return slices.Contains(claims.RealmAccess.Roles, role)
+}
+
+// HasResourceRole reports whether the caller holds the role on the given OIDC client. Matching is
+// case-sensitive, as in Keycloak, and false is returned when unauthenticated.
+func (helper *contextHelper) HasResourceRole(ctx context.Context, client, role string) bool {
+	return slices.Contains(helper.GetClientRoles(ctx, client), role)
+}
+
+// WithTenant returns a context carrying the tenant id under the same key UnaryTenantInterceptor
+// uses, for background jobs and tests that do not go through the interceptor
+func (helper *contextHelper) WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, XTenantKey, tenantID)
+}
+
+// WithUserClaims returns a context carrying the claims under the same key UnaryTokenInterceptor
+// uses, for background jobs and tests that do not go through the interceptor
+func (helper *contextHelper) WithUserClaims(ctx context.Context, claims *UserAuthClaims) context.Context {
+	return context.WithValue(ctx, helper.claimsKey, claims)
+}
+
+// GetRequestContext returns the tenant, claims and request id of the request in one call, for
+// handlers to validate them together on entry. It returns ErrMissingTenant without a tenant and
+// ErrMissingUserClaims without claims; a missing request id is not an error.
+//
+// Example Usage:
+//
+//	requestContext, err := helper.GetRequestContext(ctx)
+//	if err != nil {
+//	    return nil, err
+//	}
+//	logger.Info("creating invoice", zap.String("tenant", requestContext.TenantID), zap.String("request_id", requestContext.RequestID))
+func (helper *contextHelper) GetRequestContext(ctx context.Context) (*RequestContext, error) {
+	tenantID, err := helper.GetTenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := helper.GetUserClaims(ctx)
+	if claims == nil {
+		return nil, ErrMissingUserClaims
+	}
+	return &RequestContext{
+		TenantID:   tenantID,
+		UserClaims: claims,
+		RequestID:  helper.GetRequestID(ctx),
+	}, nil
+}
+
+// WithRequestContext returns a context carrying the tenant, claims and request id under the keys
+// the interceptors use, for background jobs and tests that do not go through them. Empty fields
+// are left unset.
+//
+// Example Usage:
+//
+//	ctx := helper.WithRequestContext(context.Background(), &RequestContext{TenantID: "acme", UserClaims: claims})
+func (helper *contextHelper) WithRequestContext(ctx context.Context, requestContext *RequestContext) context.Context {
+	if requestContext == nil {
+		return ctx
+	}
+	if requestContext.TenantID != "" {
+		ctx = helper.WithTenant(ctx, requestContext.TenantID)
+	}
+	if requestContext.UserClaims != nil {
+		ctx = helper.WithUserClaims(ctx, requestContext.UserClaims)
+	}
+	if requestContext.RequestID != "" {
+		ctx = context.WithValue(ctx, ContextKeyRequestID, requestContext.RequestID)
+	}
+	return ctx
+}
+
+// claimsContextKey is the type of claims keys configured with WithClaimsContextKey, so they
+// cannot collide with plain string keys such as ContextKeyUser
+type claimsContextKey string
+
+// claimsFromContextKey returns the claims stored under key, or nil
+func claimsFromContextKey(ctx context.Context, key any) *UserAuthClaims {
+	claims, _ := ctx.Value(key).(*UserAuthClaims)
+	return claims
+}
+
+func NewContextHelper(authenticator Authenticator, opts ...ContextHelperOption) ContextHelper {
+	helper := &contextHelper{
 		authenticator: authenticator,
+		claimsKey:     ContextKeyUser,
+	}
+	for _, opt := range opts {
+		opt(helper)
 	}
+	return helper
 }
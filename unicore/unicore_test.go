@@ -0,0 +1,548 @@
+package unicore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+func TestValidatePageRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    int32
+		maxPage int32
+		wantErr bool
+	}{
+		{name: "below the maximum", page: 999, maxPage: 1000},
+		{name: "at the maximum", page: 1000, maxPage: 1000},
+		{name: "beyond the maximum", page: 1001, maxPage: 1000, wantErr: true},
+		{name: "no maximum", page: 1 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePageRequest(&commonv1.PageRequest{Page: tt.page}, tt.maxPage)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			assertCode(t, err, connect.CodeInvalidArgument)
+		})
+	}
+}
+
+type legacyRow struct {
+	ID        uint
+	CompanyID string
+	Name      string
+}
+
+func TestWithTenantScopeColumn(t *testing.T) {
+	db := openTestDB(t, &legacyRow{}, &exportRow{})
+	db.Create(&[]legacyRow{{CompanyID: "acme", Name: "a"}, {CompanyID: "other", Name: "b"}, {CompanyID: "acme", Name: "c"}})
+	db.Create(&[]exportRow{{TenantID: "acme", Name: "a"}, {TenantID: "other", Name: "b"}})
+	ctx := withTenant(context.Background(), "acme")
+
+	var legacy []legacyRow
+	if err := db.Scopes(WithTenantScopeColumn(ctx, "company_id")).Order("id").Find(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(legacy) != 2 || legacy[0].Name != "a" || legacy[1].Name != "c" {
+		t.Fatalf("expected the two acme rows, got %+v", legacy)
+	}
+
+	// An empty column defaults to tenant_id, like WithTenantScope.
+	var rows []exportRow
+	if err := db.Scopes(WithTenantScopeColumn(ctx, "")).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].TenantID != "acme" {
+		t.Fatalf("expected the acme row, got %+v", rows)
+	}
+}
+
+type softDeleteRow struct {
+	ID        uint
+	TenantID  string
+	Name      string
+	DeletedAt gorm.DeletedAt
+}
+
+func seedSoftDeleteRows(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := openTestDB(t, &softDeleteRow{})
+	rows := []softDeleteRow{
+		{TenantID: "acme", Name: "live"},
+		{TenantID: "acme", Name: "deleted"},
+		{TenantID: "other", Name: "other-deleted"},
+	}
+	db.Create(&rows)
+	db.Delete(&rows[1])
+	db.Delete(&rows[2])
+	return db
+}
+
+func softDeleteNames(t *testing.T, query *gorm.DB) []string {
+	t.Helper()
+
+	var rows []softDeleteRow
+	if err := query.Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, row.Name)
+	}
+	return names
+}
+
+func TestWithIncludeDeleted(t *testing.T) {
+	db := seedSoftDeleteRows(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	if got := softDeleteNames(t, db.Scopes(WithTenantScope(ctx))); !slices.Equal(got, []string{"live"}) {
+		t.Fatalf("expected GORM to hide deleted rows by default, got %v", got)
+	}
+	if got := softDeleteNames(t, db.Scopes(WithTenantScope(ctx), WithIncludeDeleted())); !slices.Equal(got, []string{"live", "deleted"}) {
+		t.Fatalf("expected live and deleted acme rows, got %v", got)
+	}
+}
+
+func TestWithOnlyDeleted(t *testing.T) {
+	db := seedSoftDeleteRows(t)
+	ctx := withTenant(context.Background(), "acme")
+
+	if got := softDeleteNames(t, db.Scopes(WithTenantScope(ctx), WithOnlyDeleted(""))); !slices.Equal(got, []string{"deleted"}) {
+		t.Fatalf("expected the deleted acme row, got %v", got)
+	}
+	// The order of the scopes does not matter.
+	if got := softDeleteNames(t, db.Scopes(WithOnlyDeleted("deleted_at"), WithTenantScope(ctx))); !slices.Equal(got, []string{"deleted"}) {
+		t.Fatalf("expected the deleted acme row, got %v", got)
+	}
+}
+
+func TestWithPaginationScopeLimitAll(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", int(DefaultPageLimit)+5)
+
+	count := func(ctx context.Context, limit int32) int {
+		var rows []exportRow
+		if err := db.WithContext(ctx).Scopes(WithPaginationScope(&commonv1.PageRequest{Limit: limit})).Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+
+	if got := count(AllowUnpaginated(context.Background()), PageLimitAll); got != int(DefaultPageLimit)+5 {
+		t.Fatalf("expected every row for a privileged caller, got %d", got)
+	}
+	if got := count(context.Background(), PageLimitAll); got != int(DefaultPageLimit) {
+		t.Fatalf("expected the default limit without AllowUnpaginated, got %d", got)
+	}
+	if got := count(context.Background(), 0); got != int(DefaultPageLimit) {
+		t.Fatalf("expected the default limit for a zero limit, got %d", got)
+	}
+	if got := count(AllowUnpaginated(context.Background()), 0); got != int(DefaultPageLimit) {
+		t.Fatalf("expected a zero limit to keep the default even when privileged, got %d", got)
+	}
+}
+
+type joinCustomer struct {
+	ID       uint
+	TenantID string
+	Name     string
+}
+
+type joinPurchase struct {
+	ID             uint
+	TenantID       string
+	JoinCustomerID uint
+	Sku            string
+}
+
+func TestWithTenantJoin(t *testing.T) {
+	db := openTestDB(t, &joinCustomer{}, &joinPurchase{})
+	db.Create(&joinCustomer{ID: 1, TenantID: "acme", Name: "alice"})
+	// A row of another tenant pointing at an acme customer must not be joined.
+	db.Create(&[]joinPurchase{
+		{TenantID: "acme", JoinCustomerID: 1, Sku: "book"},
+		{TenantID: "other", JoinCustomerID: 1, Sku: "secret"},
+	})
+	ctx := withTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name   string
+		clause string
+	}{
+		{name: "table", clause: "JOIN join_purchases ON join_purchases.join_customer_id = join_customers.id"},
+		{name: "alias", clause: "LEFT JOIN join_purchases AS p ON p.join_customer_id = join_customers.id"},
+		{name: "or condition", clause: "join join_purchases p on p.join_customer_id = join_customers.id OR p.sku = 'secret'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var skus []string
+			err := db.Table("join_customers").
+				Scopes(WithTenantScopeColumn(ctx, "join_customers.tenant_id"), WithTenantJoin(ctx, tt.clause)).
+				Order("sku").Pluck("sku", &skus).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(skus, []string{"book"}) {
+				t.Fatalf("expected only the acme purchase, got %v", skus)
+			}
+		})
+	}
+}
+
+func TestWithTenantJoinRejectsUnparsableClause(t *testing.T) {
+	db := openTestDB(t, &joinCustomer{}, &joinPurchase{})
+
+	var customers []joinCustomer
+	err := db.Scopes(WithTenantJoin(withTenant(context.Background(), "acme"), "join_purchases USING (id)")).Find(&customers).Error
+	if err == nil {
+		t.Fatal("expected an error for a clause without JOIN ... ON")
+	}
+}
+
+// keycloakPayload is the payload of a Keycloak access token with several resource clients
+const keycloakPayload = `{
+	"exp": 1760000000,
+	"iat": 1759999700,
+	"jti": "4f6c1d2e-0000-4000-8000-000000000000",
+	"iss": "https://auth.example.com/realms/unidrop",
+	"aud": ["orders-service", "account"],
+	"sub": "a1b2c3",
+	"typ": "Bearer",
+	"azp": "web-app",
+	"realm_access": {"roles": ["offline_access", "uma_authorization"]},
+	"resource_access": {
+		"orders-service": {"roles": ["orders:read", "orders:write"]},
+		"billing-service": {"roles": ["invoices:read"]},
+		"account": {"roles": ["manage-account", "view-profile"]}
+	},
+	"scope": "openid email profile",
+	"email_verified": true,
+	"preferred_username": "alice"
+}`
+
+func TestResourceAccessUnmarshalsEveryClient(t *testing.T) {
+	var claims UserAuthClaims
+	if err := json.Unmarshal([]byte(keycloakPayload), &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(claims.ResourceAccess.Account.Roles, []string{"manage-account", "view-profile"}) {
+		t.Fatalf("expected the account roles to stay available, got %v", claims.ResourceAccess.Account.Roles)
+	}
+	if got := claims.ResourceAccess.Clients["orders-service"].Roles; !slices.Equal(got, []string{"orders:read", "orders:write"}) {
+		t.Fatalf("unexpected orders-service roles %v", got)
+	}
+	if len(claims.ResourceAccess.Clients) != 3 {
+		t.Fatalf("expected 3 clients, got %v", claims.ResourceAccess.Clients)
+	}
+
+	data, err := json.Marshal(claims.ResourceAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip ResourceAccess
+	if err := json.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, claims.ResourceAccess) {
+		t.Fatalf("round trip changed the roles: %+v", roundTrip)
+	}
+}
+
+func TestGetClientRoles(t *testing.T) {
+	var claims UserAuthClaims
+	if err := json.Unmarshal([]byte(keycloakPayload), &claims); err != nil {
+		t.Fatal(err)
+	}
+	helper := NewContextHelper(nil)
+	ctx := helper.WithUserClaims(context.Background(), &claims)
+
+	if got := helper.GetClientRoles(ctx, "billing-service"); !slices.Equal(got, []string{"invoices:read"}) {
+		t.Fatalf("unexpected billing-service roles %v", got)
+	}
+	if got := helper.GetClientRoles(ctx, "unknown"); got != nil {
+		t.Fatalf("expected no roles for an unknown client, got %v", got)
+	}
+	if !helper.HasResourceRole(ctx, "orders-service", "orders:write") || helper.HasResourceRole(ctx, "orders-service", "invoices:read") {
+		t.Fatal("HasResourceRole does not match the client roles")
+	}
+	if got := helper.GetClientRoles(context.Background(), "orders-service"); got != nil {
+		t.Fatalf("expected no roles without claims, got %v", got)
+	}
+}
+
+func TestNormalizePageRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      *commonv1.PageRequest
+		maxLimit  int32
+		wantPage  int32
+		wantLimit int32
+		wantSort  string
+	}{
+		{name: "nil", page: nil, wantPage: 1, wantLimit: DefaultPageLimit, wantSort: "created_at"},
+		{name: "zero limit", page: &commonv1.PageRequest{Page: 2}, wantPage: 2, wantLimit: DefaultPageLimit, wantSort: "created_at"},
+		{name: "negative limit and page", page: &commonv1.PageRequest{Page: -3, Limit: -10}, wantPage: 1, wantLimit: DefaultPageLimit, wantSort: "created_at"},
+		{name: "oversized limit", page: &commonv1.PageRequest{Limit: 1000000}, wantPage: 1, wantLimit: DefaultMaxPageLimit, wantSort: "created_at"},
+		{name: "custom maximum", page: &commonv1.PageRequest{Limit: 1000}, maxLimit: 500, wantPage: 1, wantLimit: 500, wantSort: "created_at"},
+		{name: "within limits", page: &commonv1.PageRequest{Page: 3, Limit: 50, Sort: "orders.name"}, wantPage: 3, wantLimit: 50, wantSort: "orders.name"},
+		{name: "injected sort", page: &commonv1.PageRequest{Sort: "name; DROP TABLE users"}, wantPage: 1, wantLimit: DefaultPageLimit, wantSort: "created_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized := NormalizePageRequest(tt.page, tt.maxLimit)
+			if normalized.GetPage() != tt.wantPage || normalized.GetLimit() != tt.wantLimit || normalized.GetSort() != tt.wantSort {
+				t.Fatalf("got page %d limit %d sort %q, want %d %d %q", normalized.GetPage(), normalized.GetLimit(),
+					normalized.GetSort(), tt.wantPage, tt.wantLimit, tt.wantSort)
+			}
+			if normalized.GetDirection() != commonv1.SortDirection_SORT_DIRECTION_DESC {
+				t.Fatalf("expected a descending default direction, got %v", normalized.GetDirection())
+			}
+			if tt.page != nil && normalized == tt.page {
+				t.Fatal("expected a copy of the request")
+			}
+		})
+	}
+}
+
+func TestWithPaginationScopeCapsLimit(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", int(DefaultMaxPageLimit)+10)
+
+	var rows []exportRow
+	if err := db.Scopes(WithPaginationScope(&commonv1.PageRequest{Limit: 1000000})).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != int(DefaultMaxPageLimit) {
+		t.Fatalf("expected the limit to be capped at %d, got %d rows", DefaultMaxPageLimit, len(rows))
+	}
+
+	rows = nil
+	if err := db.Scopes(WithPaginationScopeMaxLimit(&commonv1.PageRequest{Limit: 1000}, 5)).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected the custom cap of 5, got %d rows", len(rows))
+	}
+}
+
+func TestValidatePageDepth(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      *commonv1.PageRequest
+		maxOffset int32
+		wantErr   bool
+	}{
+		{name: "first page", page: nil},
+		{name: "at the default cap", page: &commonv1.PageRequest{Page: 101, Limit: 100}},
+		{name: "past the default cap", page: &commonv1.PageRequest{Page: 102, Limit: 100}, wantErr: true},
+		{name: "default limit", page: &commonv1.PageRequest{Page: 501}},
+		{name: "default limit past the cap", page: &commonv1.PageRequest{Page: 502}, wantErr: true},
+		{name: "capped limit", page: &commonv1.PageRequest{Page: 101, Limit: 1000}},
+		{name: "at a custom cap", page: &commonv1.PageRequest{Page: 11, Limit: 10}, maxOffset: 100},
+		{name: "past a custom cap", page: &commonv1.PageRequest{Page: 12, Limit: 10}, maxOffset: 100, wantErr: true},
+		{name: "no cap", page: &commonv1.PageRequest{Page: 1000000, Limit: 100}, maxOffset: -1},
+		// (MaxInt32-1)*100 wraps to a negative offset in int32 arithmetic.
+		{name: "huge page", page: &commonv1.PageRequest{Page: math.MaxInt32, Limit: 100}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePageDepth(tt.page, tt.maxOffset)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			assertCode(t, err, connect.CodeInvalidArgument)
+		})
+	}
+}
+
+func TestWithPaginationScopeMaxDepth(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	seedExportRows(t, db, "acme", 30)
+
+	find := func(scope func(*gorm.DB) *gorm.DB) ([]exportRow, error) {
+		var rows []exportRow
+		err := db.Scopes(scope).Find(&rows).Error
+		return rows, err
+	}
+
+	rows, err := find(WithPaginationScopeMaxDepth(&commonv1.PageRequest{Page: 3, Limit: 10}, 0, 20))
+	if err != nil {
+		t.Fatalf("page just under the cap rejected: %v", err)
+	}
+	if len(rows) != 10 {
+		t.Fatalf("expected the last page of 10 rows, got %d", len(rows))
+	}
+
+	_, err = find(WithPaginationScopeMaxDepth(&commonv1.PageRequest{Page: 4, Limit: 10}, 0, 20))
+	assertCode(t, err, connect.CodeInvalidArgument)
+
+	_, err = find(WithPaginationScope(&commonv1.PageRequest{Page: math.MaxInt32, Limit: 100}))
+	assertCode(t, err, connect.CodeInvalidArgument)
+
+	rows, err = find(WithPaginationScopeMaxDepth(&commonv1.PageRequest{Page: 4, Limit: 10}, 0, -1))
+	if err != nil || len(rows) != 0 {
+		t.Fatalf("expected an uncapped empty page, got %d rows, %v", len(rows), err)
+	}
+}
+
+// renamedDialector reports another dialect name, letting tests render the SQL of clauses that are
+// skipped on SQLite
+type renamedDialector struct {
+	gorm.Dialector
+	name string
+}
+
+func (dialector renamedDialector) Name() string {
+	return dialector.name
+}
+
+func TestWithLockScopeRendersLockingClause(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	postgres, err := gorm.Open(renamedDialector{Dialector: db.Dialector, name: "postgres"}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The SQLite dialector drops locking clauses while building; render them like other dialects.
+	delete(postgres.ClauseBuilders, "FOR")
+	ctx := withTenant(context.Background(), "acme")
+
+	for strength, want := range map[string]string{"update": "FOR UPDATE", " SHARE ": "FOR SHARE"} {
+		sql := postgres.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Scopes(WithTenantScope(ctx), WithLockScope(strength)).First(&exportRow{}, 1)
+		})
+		if !strings.Contains(sql, want) || !strings.Contains(sql, "tenant_id") {
+			t.Fatalf("expected a tenant scoped %s query, got %s", want, sql)
+		}
+	}
+
+	sqlite := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Scopes(WithLockScope("UPDATE")).First(&exportRow{}, 1)
+	})
+	if strings.Contains(sqlite, "FOR UPDATE") {
+		t.Fatalf("expected no locking clause on SQLite, got %s", sqlite)
+	}
+}
+
+func TestWithLockScopeRejectsUnknownStrength(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+
+	var rows []exportRow
+	if err := db.Scopes(WithLockScope("EXCLUSIVE")).Find(&rows).Error; err == nil {
+		t.Fatal("expected an unknown lock strength to fail the query")
+	}
+}
+
+type lockCounter struct {
+	ID       uint
+	TenantID string
+	Value    int
+}
+
+func TestWithLockScopeSerializesReadModifyWrite(t *testing.T) {
+	db := openTestDB(t, &lockCounter{})
+	db.Create(&lockCounter{ID: 1, TenantID: "acme"})
+	ctx := withTenant(context.Background(), "acme")
+
+	// Each transaction reads the counter, waits so the other can run, then writes it back plus
+	// one. Without serialization both would read 0 and one increment would be lost. SQLite, where
+	// the scope is a no-op, serializes the transactions itself; the row lock taken on other
+	// databases is checked by TestWithLockScopeRendersLockingClause.
+	increment := func() error {
+		return WithTransaction(ctx, db, func(tx *gorm.DB) error {
+			var counter lockCounter
+			if err := tx.Scopes(WithLockScope("UPDATE")).First(&counter, 1).Error; err != nil {
+				return err
+			}
+			time.Sleep(20 * time.Millisecond)
+			return tx.Model(&counter).Update("value", counter.Value+1).Error
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- increment()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var counter lockCounter
+	db.First(&counter, 1)
+	if counter.Value != 2 {
+		t.Fatalf("expected both increments to apply, got %d", counter.Value)
+	}
+}
+
+func TestWithContextScopeAbortsSlowQuery(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	// Pure-Go SQLite cannot interrupt a running statement, so the slow query is simulated by a
+	// callback waiting on the statement context like drivers such as pgx do.
+	err := db.Callback().Query().Before("gorm:query").Register("test:slow_query", func(tx *gorm.DB) {
+		select {
+		case <-tx.Statement.Context.Done():
+			_ = tx.AddError(tx.Statement.Context.Err())
+		case <-time.After(5 * time.Second):
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var rows []exportRow
+	err = db.Scopes(WithContextScope(ctx)).Find(&rows).Error
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the query to fail with the context deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the query to stop at the deadline, ran for %v", elapsed)
+	}
+}
+
+func TestWithContextScopeCancelledContext(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var rows []exportRow
+	if err := db.Scopes(WithContextScope(ctx)).Find(&rows).Error; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package unicoretest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"connectrpc.com/connect"
+	"github.com/coreos/go-oidc"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/unidropofficial/unicore-go/unicore"
+	"google.golang.org/grpc"
+)
+
+// fakeIssuer is the issuer of the tokens minted by FakeAuthenticator
+const fakeIssuer = "https://unicore.test"
+
+// FakeAuthenticator implements unicore.Authenticator without an identity provider. Every request
+// carrying any bearer token authenticates as the configured claims, so handlers protected by
+// UnaryTokenInterceptor can be tested end to end. Requests without an Authorization header are
+// still rejected, as with the real authenticator.
+type FakeAuthenticator struct {
+	mu       sync.RWMutex
+	claims   *unicore.UserAuthClaims
+	secret   []byte
+	verifier *oidc.IDTokenVerifier
+}
+
+// SetClaims replaces the claims returned for subsequent requests
+func (authenticator *FakeAuthenticator) SetClaims(claims *unicore.UserAuthClaims) {
+	authenticator.mu.Lock()
+	defer authenticator.mu.Unlock()
+	authenticator.claims = claims
+}
+
+// ExtractHeaderToken checks the Authorization header like the real authenticator, then returns a
+// token carrying the configured claims
+func (authenticator *FakeAuthenticator) ExtractHeaderToken(request connect.AnyRequest) (string, error) {
+	if _, err := unicore.ParseBearerToken(request.Header().Get("Authorization")); err != nil {
+		return "", err
+	}
+	return authenticator.sign()
+}
+
+// ExtractToken returns a token carrying the configured claims
+func (authenticator *FakeAuthenticator) ExtractToken(ctx context.Context) (string, error) {
+	return authenticator.sign()
+}
+
+// GetVerifier returns a verifier accepting the tokens minted by this authenticator
+func (authenticator *FakeAuthenticator) GetVerifier() *oidc.IDTokenVerifier {
+	return authenticator.verifier
+}
+
+// VerifyAudience accepts every audience
+func (authenticator *FakeAuthenticator) VerifyAudience(*oidc.IDToken) error {
+	return nil
+}
+
+// ValidateTokenMiddleware stores the configured claims in the context and calls the handler
+func (authenticator *FakeAuthenticator) ValidateTokenMiddleware(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	authenticator.mu.RLock()
+	claims := authenticator.claims
+	authenticator.mu.RUnlock()
+	return handler(ContextWithClaims(ctx, claims), req)
+}
+
+// sign mints an HS256 token carrying the configured claims
+func (authenticator *FakeAuthenticator) sign() (string, error) {
+	authenticator.mu.RLock()
+	claims := *authenticator.claims
+	authenticator.mu.RUnlock()
+
+	claims.Iss = fakeIssuer
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(authenticator.secret)
+}
+
+// VerifySignature implements oidc.KeySet for the tokens minted by sign
+func (authenticator *FakeAuthenticator) VerifySignature(_ context.Context, token string) ([]byte, error) {
+	_, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return authenticator.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token, ".")
+	return base64.RawURLEncoding.DecodeString(parts[1])
+}
+
+// NewFakeAuthenticator returns a FakeAuthenticator authenticating every request as claims
+//
+// Example Usage:
+//
+//	authenticator := unicoretest.NewFakeAuthenticator(&unicore.UserAuthClaims{Id: "user-1"})
+//	middleware := unicore.NewMiddleware(authenticator, zap.NewNop(), unicore.NewContextHelper(authenticator))
+func NewFakeAuthenticator(claims *unicore.UserAuthClaims) *FakeAuthenticator {
+	if claims == nil {
+		claims = &unicore.UserAuthClaims{}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("unicoretest: failed to generate signing secret: %v", err))
+	}
+
+	authenticator := &FakeAuthenticator{
+		claims: claims,
+		secret: secret,
+	}
+	authenticator.verifier = oidc.NewVerifier(fakeIssuer, authenticator, &oidc.Config{
+		SkipClientIDCheck:    true,
+		SkipExpiryCheck:      true,
+		SupportedSigningAlgs: []string{jwt.SigningMethodHS256.Alg()},
+	})
+	return authenticator
+}
+
+// ContextWithClaims returns a context carrying claims as the token interceptors store them
+func ContextWithClaims(ctx context.Context, claims *unicore.UserAuthClaims) context.Context {
+	return context.WithValue(ctx, unicore.ContextKeyUser, claims)
+}
+
+// ContextWithTenant returns a context carrying the tenant as the tenant interceptor stores it
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, unicore.XTenantKey, tenantID)
+}
+
+// NewAuthenticatedRequest returns a connect request with a bearer token and, when tenantID is not
+// empty, the x-tenant-id header set
+func NewAuthenticatedRequest[T any](msg *T, tenantID string) *connect.Request[T] {
+	request := connect.NewRequest(msg)
+	request.Header().Set("Authorization", "Bearer unicoretest")
+	if tenantID != "" {
+		request.Header().Set(unicore.XTenantKey, tenantID)
+	}
+	return request
+}
+
+var _ unicore.Authenticator = (*FakeAuthenticator)(nil)
@@ -0,0 +1,89 @@
+package unicoretest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/unidropofficial/unicore-go/unicore"
+	"github.com/unidropofficial/unicore-go/unicore/unicoretest"
+)
+
+const testProcedure = "/test.v1.TestService/Call"
+
+// newProtectedClient serves a handler behind UnaryTokenInterceptor of a middleware using
+// authenticator. The handler answers with the subject of the claims in its context.
+func newProtectedClient(t *testing.T, authenticator *unicoretest.FakeAuthenticator, opts ...unicore.MiddlewareOption) *connect.Client[emptypb.Empty, emptypb.Empty] {
+	t.Helper()
+
+	helper := unicore.NewContextHelper(authenticator)
+	middleware := unicore.NewMiddleware(authenticator, zap.NewNop(), helper, opts...)
+	handler := func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		resp := connect.NewResponse(&emptypb.Empty{})
+		if claims := helper.GetUserClaims(ctx); claims != nil {
+			resp.Header().Set("X-Subject", claims.Id)
+		}
+		return resp, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(testProcedure, connect.NewUnaryHandler(testProcedure, handler, connect.WithInterceptors(middleware.UnaryTokenInterceptor())))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+testProcedure)
+}
+
+func TestFakeAuthenticatorWithUnaryTokenInterceptor(t *testing.T) {
+	authenticator := unicoretest.NewFakeAuthenticator(&unicore.UserAuthClaims{Id: "user-1"})
+	client := newProtectedClient(t, authenticator)
+
+	resp, err := client.CallUnary(context.Background(), unicoretest.NewAuthenticatedRequest(&emptypb.Empty{}, "acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header().Get("X-Subject"); got != "user-1" {
+		t.Fatalf("expected the handler to see the configured claims, got subject %q", got)
+	}
+
+	authenticator.SetClaims(&unicore.UserAuthClaims{Id: "user-2"})
+	resp, err = client.CallUnary(context.Background(), unicoretest.NewAuthenticatedRequest(&emptypb.Empty{}, "acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header().Get("X-Subject"); got != "user-2" {
+		t.Fatalf("expected SetClaims to apply to later requests, got subject %q", got)
+	}
+
+	_, err = client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Fatalf("expected a request without a token to be rejected, got %v", err)
+	}
+}
+
+func TestFakeClockExpiresRevokedTokens(t *testing.T) {
+	clock := unicoretest.NewFakeClock(time.Now())
+	exp := clock.Now().Add(time.Hour)
+	authenticator := unicoretest.NewFakeAuthenticator(&unicore.UserAuthClaims{Id: "user-1", Jti: "token-1", Exp: exp.Unix()})
+	blacklist := unicore.NewMemoryTokenBlacklist(unicore.WithBlacklistClock(clock))
+	client := newProtectedClient(t, authenticator, unicore.WithTokenBlacklist(blacklist))
+
+	if err := blacklist.Revoke(context.Background(), "token-1", exp); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.CallUnary(context.Background(), unicoretest.NewAuthenticatedRequest(&emptypb.Empty{}, "acme"))
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Fatalf("expected the revoked token to be rejected, got %v", err)
+	}
+
+	// Once the clock passes exp the token has expired anyway, so the blacklist forgets it.
+	clock.Advance(time.Hour + time.Second)
+	if blacklist.IsRevoked(context.Background(), "token-1") {
+		t.Fatal("expected the revocation to expire with the token")
+	}
+}
@@ -0,0 +1,49 @@
+package unicoretest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/unidropofficial/unicore-go/unicore"
+)
+
+// FakeClock implements unicore.Clock with a time that only moves when told to, so token expiry,
+// blacklist and cache TTLs can be tested without sleeping.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the current fake time
+func (clock *FakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+// Advance moves the fake time forward by d
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+// Set moves the fake time to t
+func (clock *FakeClock) Set(t time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = t
+}
+
+// NewFakeClock returns a FakeClock starting at now
+//
+// Example Usage:
+//
+//	clock := unicoretest.NewFakeClock(time.Now())
+//	blacklist := unicore.NewMemoryTokenBlacklist(unicore.WithBlacklistClock(clock))
+//	clock.Advance(time.Hour)
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+var _ unicore.Clock = (*FakeClock)(nil)
@@ -0,0 +1,25 @@
+package unicoretest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/unidropofficial/unicore-go/unicore/unicoretest"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := unicoretest.NewFakeClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Fatalf("expected the clock to start at %v, got %v", start, clock.Now())
+	}
+	clock.Advance(90 * time.Minute)
+	if want := start.Add(90 * time.Minute); !clock.Now().Equal(want) {
+		t.Fatalf("expected %v after advancing, got %v", want, clock.Now())
+	}
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Fatalf("expected Set to move the clock back to %v, got %v", start, clock.Now())
+	}
+}
@@ -0,0 +1,128 @@
+package unicoretest
+
+import (
+	"net/http"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"github.com/unidropofficial/unicore-go/unicore"
+)
+
+// FakeMiddleware implements unicore.Middleware with interceptors that pass every request through
+// unchanged, for tests that exercise handlers without authentication or tenancy checks.
+type FakeMiddleware struct{}
+
+// passthrough returns an interceptor that calls the next handler unchanged
+func passthrough() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return next
+	}
+}
+
+func (FakeMiddleware) CorsMiddleware(h http.Handler) http.Handler { return h }
+
+func (FakeMiddleware) LoggingUnaryInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) HealthChecker(srvName string) *grpchealth.StaticChecker {
+	return grpchealth.NewStaticChecker(srvName)
+}
+
+func (FakeMiddleware) UnaryTokenInterceptor(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) UnaryTokenInterceptorFromContext(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) UnaryTenantInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) UnaryTimezoneInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) RequestIDUnaryInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) MaxPageInterceptor(int32) connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) SignedTenantInterceptor([]byte) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) TenantConsistencyInterceptor(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) TimeBudgetInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) DeprecationInterceptor(map[string]string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) ResponseHeaderFilterInterceptor([]string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) AuditInterceptor(unicore.AuditSink, ...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) RegisterReflection(*http.ServeMux, ...string) {}
+
+func (FakeMiddleware) MaxRequestSizeInterceptor(int64, ...unicore.RequestSizeOption) connect.Interceptor {
+	return passthrough()
+}
+
+func (FakeMiddleware) RequireVerifiedEmailInterceptor(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) LoggingStreamInterceptor() connect.Interceptor { return passthrough() }
+
+func (FakeMiddleware) RequireHeadersInterceptor(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) MTLSInterceptor(...string) connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) ConnectErrorInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) PublicRoutes() []string { return nil }
+
+func (FakeMiddleware) TenantValidationInterceptor(unicore.TenantRegistry) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) RecoveryInterceptor() connect.UnaryInterceptorFunc { return passthrough() }
+
+func (FakeMiddleware) APIVersionInterceptor(string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) ServiceTokenInterceptor(unicore.ServiceTokenAuthenticator) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) ValidationUnaryInterceptor(...unicore.ValidationOption) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) TenantFromHostInterceptor(string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) SingleFlightInterceptor(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) SharedSingleFlightInterceptor(...string) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) FeatureFlagInterceptor(unicore.FlagStore) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+func (FakeMiddleware) DrainingInterceptor(*unicore.ShutdownState) connect.UnaryInterceptorFunc {
+	return passthrough()
+}
+
+var _ unicore.Middleware = FakeMiddleware{}
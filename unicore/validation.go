@@ -0,0 +1,138 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"buf.build/go/protovalidate"
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+// MessageValidator checks a request message against its constraints, returning the violated
+// fields. The error is reserved for failures of the validation itself, e.g. a constraint that does
+// not compile, and is returned to the client as CodeInternal.
+type MessageValidator func(msg proto.Message) ([]*errdetails.BadRequest_FieldViolation, error)
+
+type validationOptions struct {
+	validate MessageValidator
+}
+
+// ValidationOption customizes ValidationUnaryInterceptor
+type ValidationOption func(*validationOptions)
+
+// WithMessageValidator replaces the protovalidate validator run by ValidationUnaryInterceptor, e.g.
+// with one created with custom protovalidate options:
+//
+//	validator, err := protovalidate.New(protovalidate.WithMessages(&catalogv1.CreateProductRequest{}))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	middleware.ValidationUnaryInterceptor(WithMessageValidator(ProtovalidateMessageValidator(validator)))
+func WithMessageValidator(validate MessageValidator) ValidationOption {
+	return func(options *validationOptions) {
+		options.validate = validate
+	}
+}
+
+// defaultProtoValidator builds the protovalidate validator shared by every ValidationUnaryInterceptor
+// without WithMessageValidator. It is built once, on first use; protovalidate compiles and caches
+// the rules of each message type as it meets them.
+var defaultProtoValidator = sync.OnceValues(func() (protovalidate.Validator, error) {
+	return protovalidate.New()
+})
+
+// ProtovalidateMessageValidator adapts a protovalidate validator to a MessageValidator, reporting
+// every violation with its field path and message. Other errors, such as rules that do not
+// compile, are returned as the error.
+func ProtovalidateMessageValidator(validator protovalidate.Validator) MessageValidator {
+	return func(msg proto.Message) ([]*errdetails.BadRequest_FieldViolation, error) {
+		return protovalidateViolations(validator.Validate(msg))
+	}
+}
+
+// validateWithDefault runs the default protovalidate validator, failing every request when it could
+// not be built
+func validateWithDefault(msg proto.Message) ([]*errdetails.BadRequest_FieldViolation, error) {
+	validator, err := defaultProtoValidator()
+	if err != nil {
+		return nil, fmt.Errorf("creating protovalidate validator: %w", err)
+	}
+	return protovalidateViolations(validator.Validate(msg))
+}
+
+// protovalidateViolations converts the error of a protovalidate validation into field violations
+func protovalidateViolations(err error) ([]*errdetails.BadRequest_FieldViolation, error) {
+	if err == nil {
+		return nil, nil
+	}
+	var validationErr *protovalidate.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(validationErr.Violations))
+	for _, violation := range validationErr.Violations {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       protovalidate.FieldPathString(violation.Proto.GetField()),
+			Description: violation.Proto.GetMessage(),
+		})
+	}
+	return violations, nil
+}
+
+// ValidationUnaryInterceptor validates every request message against its buf.validate rules with
+// protovalidate before it reaches the handler. Invalid requests are rejected with
+// CodeInvalidArgument carrying a BadRequest detail that lists every field violation; messages
+// without rules and values that are not protobuf messages are passed through. A validation that
+// fails itself, including a default validator that could not be built, is returned as
+// CodeInternal. WithMessageValidator replaces protovalidate.
+//
+// Example Usage:
+//
+//	connect.WithInterceptors(middleware.ValidationUnaryInterceptor())
+func (middleware *grpcAuthMiddleware) ValidationUnaryInterceptor(opts ...ValidationOption) connect.UnaryInterceptorFunc {
+	options := validationOptions{
+		validate: validateWithDefault,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	validate := options.validate
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			msg, ok := req.Any().(proto.Message)
+			if !ok {
+				return next(ctx, req)
+			}
+
+			violations, err := validate(msg)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("validating request: %w", err))
+			}
+			if len(violations) > 0 {
+				return nil, newViolationsError(violations)
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// newViolationsError returns a CodeInvalidArgument error carrying a BadRequest detail with every
+// field violation
+func newViolationsError(violations []*errdetails.BadRequest_FieldViolation) *connect.Error {
+	reasons := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", violation.GetField(), violation.GetDescription()))
+	}
+
+	connectErr := connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid request: %s", strings.Join(reasons, "; ")))
+	addErrorDetail(connectErr, &errdetails.BadRequest{FieldViolations: violations})
+	return connectErr
+}
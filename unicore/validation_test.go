@@ -0,0 +1,196 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	commonv1 "buf.build/gen/go/unidrop/common/protocolbuffers/go/unidrop/common/v1"
+	"buf.build/go/protovalidate"
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// pageConstraints is a custom MessageValidator: a PageRequest needs a positive page and a limit of
+// at most 100. Other messages have no constraints.
+func pageConstraints(msg proto.Message) ([]*errdetails.BadRequest_FieldViolation, error) {
+	page, ok := msg.(*commonv1.PageRequest)
+	if !ok {
+		return nil, nil
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if page.GetPage() < 1 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "page", Description: "value must be greater than 0"})
+	}
+	if page.GetLimit() > 100 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "limit", Description: "value must be less than or equal to 100"})
+	}
+	return violations, nil
+}
+
+func okPageHandler(context.Context, *connect.Request[commonv1.PageRequest]) (*connect.Response[emptypb.Empty], error) {
+	return connect.NewResponse(&emptypb.Empty{}), nil
+}
+
+func TestValidationUnaryInterceptorRejectsViolations(t *testing.T) {
+	middleware := newTestMiddleware()
+	client := newTestClient(t, okPageHandler, middleware.ValidationUnaryInterceptor(WithMessageValidator(pageConstraints)))
+
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&commonv1.PageRequest{Page: 1, Limit: 100})); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&commonv1.PageRequest{Page: 0, Limit: 500}))
+	assertCode(t, err, connect.CodeInvalidArgument)
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("expected a connect error, got %v", err)
+	}
+	var badRequest *errdetails.BadRequest
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found, ok := value.(*errdetails.BadRequest); ok {
+			badRequest = found
+		}
+	}
+	if badRequest == nil {
+		t.Fatal("expected a BadRequest detail")
+	}
+	fields := map[string]bool{}
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields[violation.GetField()] = true
+	}
+	if len(fields) != 2 || !fields["page"] || !fields["limit"] {
+		t.Fatalf("expected violations for page and limit, got %v", badRequest.GetFieldViolations())
+	}
+}
+
+func TestValidationUnaryInterceptorSkipsUnconstrainedMessages(t *testing.T) {
+	middleware := newTestMiddleware()
+	client := newTestClient(t, func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}, middleware.ValidationUnaryInterceptor(WithMessageValidator(pageConstraints)))
+
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{})); err != nil {
+		t.Fatalf("message without constraints rejected: %v", err)
+	}
+}
+
+func TestValidationUnaryInterceptorPassesNonProtoMessages(t *testing.T) {
+	middleware := newTestMiddleware()
+	called := false
+	interceptor := middleware.ValidationUnaryInterceptor(WithMessageValidator(func(proto.Message) ([]*errdetails.BadRequest_FieldViolation, error) {
+		t.Fatal("validator called for a non-protobuf message")
+		return nil, nil
+	}))
+
+	next := interceptor(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		called = true
+		return nil, nil
+	})
+	if _, err := next(context.Background(), connect.NewRequest(&struct{ Name string }{})); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("expected the handler to be called")
+	}
+}
+
+func TestValidationUnaryInterceptorValidatorError(t *testing.T) {
+	middleware := newTestMiddleware()
+	client := newTestClient(t, okPageHandler, middleware.ValidationUnaryInterceptor(WithMessageValidator(func(proto.Message) ([]*errdetails.BadRequest_FieldViolation, error) {
+		return nil, errors.New("constraint does not compile")
+	})))
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&commonv1.PageRequest{Page: 1}))
+	assertCode(t, err, connect.CodeInternal)
+}
+
+// newConstrainedPage returns a message with a single int32 field page whose buf.validate rule
+// requires it to be greater than 0
+func newConstrainedPage(t *testing.T, page int32) *dynamicpb.Message {
+	t.Helper()
+
+	fieldOptions := &descriptorpb.FieldOptions{}
+	proto.SetExtension(fieldOptions, validate.E_Field, &validate.FieldRules{
+		Type: &validate.FieldRules_Int32{Int32: &validate.Int32Rules{GreaterThan: &validate.Int32Rules_Gt{Gt: 0}}},
+	})
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("unicore/test/constrained_page.proto"),
+		Package:    proto.String("unicore.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"buf/validate/validate.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("ConstrainedPage"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("page"),
+				JsonName: proto.String("page"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+				Options:  fieldOptions,
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptor := file.Messages().Get(0)
+	msg := dynamicpb.NewMessage(descriptor)
+	msg.Set(descriptor.Fields().ByName("page"), protoreflect.ValueOfInt32(page))
+	return msg
+}
+
+func TestValidationUnaryInterceptorDefaultsToProtovalidate(t *testing.T) {
+	next := newTestMiddleware().ValidationUnaryInterceptor()(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return nil, nil
+	})
+
+	if _, err := next(context.Background(), connect.NewRequest(newConstrainedPage(t, 1))); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+	if _, err := next(context.Background(), connect.NewRequest(&emptypb.Empty{})); err != nil {
+		t.Fatalf("message without rules rejected: %v", err)
+	}
+
+	_, err := next(context.Background(), connect.NewRequest(newConstrainedPage(t, 0)))
+	assertCode(t, err, connect.CodeInvalidArgument)
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || len(connectErr.Details()) != 1 {
+		t.Fatalf("expected a BadRequest detail, got %v", err)
+	}
+	value, err := connectErr.Details()[0].Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	badRequest, ok := value.(*errdetails.BadRequest)
+	if !ok || len(badRequest.GetFieldViolations()) != 1 || badRequest.GetFieldViolations()[0].GetField() != "page" {
+		t.Fatalf("expected a violation of page, got %v", value)
+	}
+}
+
+func TestValidationUnaryInterceptorDefaultValidatorError(t *testing.T) {
+	original := defaultProtoValidator
+	defaultProtoValidator = func() (protovalidate.Validator, error) {
+		return nil, errors.New("rules do not compile")
+	}
+	t.Cleanup(func() { defaultProtoValidator = original })
+
+	client := newTestClient(t, okPageHandler, newTestMiddleware().ValidationUnaryInterceptor())
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&commonv1.PageRequest{Page: 1}))
+	assertCode(t, err, connect.CodeInternal)
+}
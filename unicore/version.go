@@ -0,0 +1,80 @@
+package unicore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"connectrpc.com/connect"
+	"gorm.io/gorm"
+)
+
+// Versioned is implemented by models using optimistic concurrency. Models that do not implement it
+// are versioned through an exported integer Version field instead.
+type Versioned interface {
+	GetVersion() int
+	SetVersion(version int)
+}
+
+// setVersion sets the version of a record through Versioned or its Version field
+func setVersion(record any, version int) error {
+	if versioned, ok := record.(Versioned); ok {
+		versioned.SetVersion(version)
+		return nil
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(record))
+	if value.Kind() == reflect.Struct {
+		if field := value.FieldByName("Version"); field.IsValid() && field.CanSet() && field.CanInt() {
+			field.SetInt(int64(version))
+			return nil
+		}
+	}
+
+	return connect.NewError(connect.CodeInternal, fmt.Errorf("model %T has no version field", record))
+}
+
+// UpdateWithVersion saves every field of the record only if its stored version still equals
+// expectedVersion, incrementing the version in the same statement. When another writer updated
+// the record first, or it does not exist within the tenant of ctx, no row matches and
+// ErrConcurrentModification is returned with the record's version left at expectedVersion.
+//
+// Like Repository.Update, the tenant id is re-stamped from ctx so a record cannot be moved to another
+// tenant. The tenant_id, created_at and created_by columns are never written, so a partially
+// filled record cannot reset them. The model needs a "version" column, exposed through Versioned
+// or an integer Version field.
+//
+// Example Usage:
+//
+//	product.Price = req.Msg.GetPrice()
+//	if err := UpdateWithVersion(ctx, db, product, int(req.Msg.GetVersion())); err != nil {
+//	    return nil, err
+//	}
+func UpdateWithVersion[T any](ctx context.Context, db *gorm.DB, record *T, expectedVersion int) error {
+	tenantID := tenantFromContext(ctx)
+	if tenantID == "" {
+		return ErrMissingTenant
+	}
+	if err := stampTenant(record, tenantID); err != nil {
+		return err
+	}
+	if err := setVersion(record, expectedVersion+1); err != nil {
+		return err
+	}
+
+	result := db.WithContext(ctx).Scopes(WithTenantScope(ctx)).
+		Model(record).
+		Where("version = ?", expectedVersion).
+		Select("*").
+		Omit("tenant_id", "created_at", "created_by").
+		Updates(record)
+	if result.Error != nil {
+		_ = setVersion(record, expectedVersion)
+		return MapGormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		_ = setVersion(record, expectedVersion)
+		return ErrConcurrentModification
+	}
+	return nil
+}
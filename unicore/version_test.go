@@ -0,0 +1,201 @@
+package unicore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+type versionedProduct struct {
+	ID       string `gorm:"primaryKey"`
+	TenantID string
+	Name     string
+	Version  int
+}
+
+// auditedVersionedProduct also has creation columns, which updates must not reset
+type auditedVersionedProduct struct {
+	ID        string `gorm:"primaryKey"`
+	TenantID  string
+	Name      string
+	CreatedAt time.Time
+	CreatedBy string
+	Version   int
+}
+
+// interfaceVersioned keeps its version in a differently named field exposed through Versioned
+type interfaceVersioned struct {
+	ID       string `gorm:"primaryKey"`
+	TenantID string
+	Name     string
+	Revision int `gorm:"column:version"`
+}
+
+func (record *interfaceVersioned) GetVersion() int        { return record.Revision }
+func (record *interfaceVersioned) SetVersion(version int) { record.Revision = version }
+
+func TestUpdateWithVersion(t *testing.T) {
+	db := openTestDB(t, &versionedProduct{})
+	db.Create(&versionedProduct{ID: "p1", TenantID: "acme", Name: "old", Version: 1})
+	ctx := withTenant(context.Background(), "acme")
+
+	product := versionedProduct{ID: "p1", TenantID: "acme", Name: "new", Version: 1}
+	if err := UpdateWithVersion(ctx, db, &product, 1); err != nil {
+		t.Fatal(err)
+	}
+	if product.Version != 2 {
+		t.Fatalf("expected the record version to be incremented, got %d", product.Version)
+	}
+
+	var stored versionedProduct
+	db.First(&stored, "id = ?", "p1")
+	if stored.Name != "new" || stored.Version != 2 {
+		t.Fatalf("unexpected stored record %+v", stored)
+	}
+}
+
+func TestUpdateWithVersionRejectsStaleUpdate(t *testing.T) {
+	db := openTestDB(t, &versionedProduct{})
+	db.Create(&versionedProduct{ID: "p1", TenantID: "acme", Name: "old", Version: 1})
+	ctx := withTenant(context.Background(), "acme")
+
+	// Both writers loaded version 1; the second one must not overwrite the first.
+	first := versionedProduct{ID: "p1", TenantID: "acme", Name: "first", Version: 1}
+	second := versionedProduct{ID: "p1", TenantID: "acme", Name: "second", Version: 1}
+	if err := UpdateWithVersion(ctx, db, &first, 1); err != nil {
+		t.Fatal(err)
+	}
+	err := UpdateWithVersion(ctx, db, &second, 1)
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	assertCode(t, err, connect.CodeAborted)
+	if second.Version != 1 {
+		t.Fatalf("expected the rejected record to keep version 1, got %d", second.Version)
+	}
+
+	var stored versionedProduct
+	db.First(&stored, "id = ?", "p1")
+	if stored.Name != "first" || stored.Version != 2 {
+		t.Fatalf("expected the first update to win, got %+v", stored)
+	}
+}
+
+func TestUpdateWithVersionConcurrentWriters(t *testing.T) {
+	db := openTestDB(t, &versionedProduct{})
+	db.Create(&versionedProduct{ID: "p1", TenantID: "acme", Version: 1})
+	ctx := withTenant(context.Background(), "acme")
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			product := versionedProduct{ID: "p1", TenantID: "acme", Name: fmt.Sprint("writer-", i), Version: 1}
+			errs <- UpdateWithVersion(ctx, db, &product, 1)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrConcurrentModification):
+			t.Fatal(err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one writer to succeed, got %d", succeeded)
+	}
+}
+
+func TestUpdateWithVersionScopesToTenant(t *testing.T) {
+	db := openTestDB(t, &versionedProduct{})
+	db.Create(&versionedProduct{ID: "p1", TenantID: "acme", Name: "old", Version: 1})
+
+	product := versionedProduct{ID: "p1", TenantID: "acme", Name: "hijacked", Version: 1}
+	if err := UpdateWithVersion(withTenant(context.Background(), "other"), db, &product, 1); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected another tenant's update to match no row, got %v", err)
+	}
+
+	var stored versionedProduct
+	db.First(&stored, "id = ?", "p1")
+	if stored.Name != "old" {
+		t.Fatalf("expected the record to be untouched, got %+v", stored)
+	}
+}
+
+func TestUpdateWithVersionUsesVersionedInterface(t *testing.T) {
+	db := openTestDB(t, &interfaceVersioned{})
+	db.Create(&interfaceVersioned{ID: "r1", TenantID: "acme", Revision: 3})
+
+	record := interfaceVersioned{ID: "r1", TenantID: "acme", Name: "new", Revision: 3}
+	if err := UpdateWithVersion(withTenant(context.Background(), "acme"), db, &record, 3); err != nil {
+		t.Fatal(err)
+	}
+	if record.GetVersion() != 4 {
+		t.Fatalf("expected version 4, got %d", record.GetVersion())
+	}
+}
+
+func TestUpdateWithVersionRequiresVersionField(t *testing.T) {
+	db := openTestDB(t, &exportRow{})
+
+	err := UpdateWithVersion(withTenant(context.Background(), "acme"), db, &exportRow{ID: 1}, 1)
+	assertCode(t, err, connect.CodeInternal)
+}
+
+func TestUpdateWithVersionKeepsTenant(t *testing.T) {
+	db := openTestDB(t, &versionedProduct{})
+	db.Create(&versionedProduct{ID: "p1", TenantID: "acme", Name: "old", Version: 1})
+
+	product := versionedProduct{ID: "p1", TenantID: "victim", Name: "new", Version: 1}
+	if err := UpdateWithVersion(withTenant(context.Background(), "acme"), db, &product, 1); err != nil {
+		t.Fatal(err)
+	}
+	if product.TenantID != "acme" {
+		t.Fatalf("expected the record to be re-stamped with the request tenant, got %q", product.TenantID)
+	}
+
+	var stored versionedProduct
+	db.First(&stored, "id = ?", "p1")
+	if stored.TenantID != "acme" || stored.Name != "new" {
+		t.Fatalf("expected the row to stay in acme, got %+v", stored)
+	}
+}
+
+func TestUpdateWithVersionKeepsCreationColumns(t *testing.T) {
+	db := openTestDB(t, &auditedVersionedProduct{})
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	db.Create(&auditedVersionedProduct{ID: "p1", TenantID: "acme", Name: "old", CreatedAt: createdAt, CreatedBy: "alice", Version: 1})
+
+	// A record built from the request only carries the fields being changed.
+	product := auditedVersionedProduct{ID: "p1", Name: "new"}
+	if err := UpdateWithVersion(withTenant(context.Background(), "acme"), db, &product, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored auditedVersionedProduct
+	db.First(&stored, "id = ?", "p1")
+	if !stored.CreatedAt.Equal(createdAt) || stored.CreatedBy != "alice" || stored.Name != "new" || stored.Version != 2 {
+		t.Fatalf("expected the creation columns to be kept, got %+v", stored)
+	}
+}
+
+func TestUpdateWithVersionRequiresTenant(t *testing.T) {
+	db := openTestDB(t, &versionedProduct{})
+
+	if err := UpdateWithVersion(context.Background(), db, &versionedProduct{ID: "p1"}, 1); !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("expected ErrMissingTenant, got %v", err)
+	}
+}